@@ -0,0 +1,102 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// 生成临时文件名的最大尝试次数
+const maxTempAttempts = 100
+
+// WriteFile 原子地将data写入path
+// 数据先写入同一目录下的临时文件并fsync，再重命名为path，中途出错时path保持原来的内容
+// path已存在时保留其权限，否则使用perm(受umask影响)；所在的目录不存在时会被创建
+// path为符号链接时写入链接指向的文件
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	exists := err == nil
+	if exists {
+		perm = info.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	f, err := createTemp(dir, filepath.Base(path), perm)
+	if err != nil {
+		return err
+	}
+	tempPath := f.Name()
+	if err := writeSync(f, data); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	// 创建时的权限受umask影响，已存在的文件需要保持原来的权限
+	if exists {
+		if err := os.Chmod(tempPath, perm); err != nil {
+			os.Remove(tempPath)
+			return err
+		}
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// createTemp 在dir中创建只有当前进程使用的临时文件
+func createTemp(dir, base string, perm os.FileMode) (*os.File, error) {
+	prefix := filepath.Join(dir, "."+base+".tmp"+strconv.Itoa(os.Getpid())+"-")
+	for i := 0; ; i++ {
+		name := prefix + strconv.FormatInt(time.Now().UnixNano(), 36)
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) && i < maxTempAttempts {
+			continue
+		}
+		return f, err
+	}
+}
+
+// writeSync 写入data并在关闭前将数据同步到磁盘
+func writeSync(f *os.File, data []byte) error {
+	_, err := f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// syncDir 同步目录，确保重命名在断电后仍然有效
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	// 部分文件系统不支持同步目录
+	err = d.Sync()
+	if pathErr, ok := err.(*os.PathError); ok && pathErr.Err == syscall.EINVAL {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package atomicfile
+
+import (
+	"testing"
+
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schannel-qt5-atomicfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 目录不存在时被创建
+	path := filepath.Join(dir, "a", "b", "config.json")
+	if err := WriteFile(path, []byte("a long long content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("新文件的权限错误: %v\n", info.Mode())
+	}
+
+	// 较短的内容不会残留旧数据，已有的权限被保留
+	if err := os.Chmod(path, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(path, []byte("short"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil || string(data) != "short" {
+		t.Errorf("want: short, have: %s, %v\n", data, err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+		t.Errorf("原来的权限没有被保留: %v\n", info.Mode())
+	}
+
+	// 写入符号链接指向的文件
+	link := filepath.Join(dir, "link.json")
+	if err := os.Symlink(path, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(link, []byte("linked"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("符号链接被替换: %v\n", err)
+	}
+	if data, _ := ioutil.ReadFile(path); string(data) != "linked" {
+		t.Errorf("链接指向的文件没有被写入: %s\n", data)
+	}
+
+	// 不应该留下临时文件
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("目录中有多余的文件: %d\n", len(files))
+	}
+}
+
+func TestWriteFileFailed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schannel-qt5-atomicfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 目标是目录时写入失败，不会留下临时文件
+	path := filepath.Join(dir, "config.json")
+	if err := os.Mkdir(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(path, []byte("data"), 0644); err == nil {
+		t.Errorf("目标为目录时应该返回错误\n")
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("写入失败后留下了临时文件: %d\n", len(files))
+	}
+}
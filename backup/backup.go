@@ -0,0 +1,214 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// 归档中各个文件的名称
+const (
+	// ConfigFile schannel-qt5.json
+	ConfigFile = "schannel-qt5.json"
+	// ClientConfigFile ssr客户端配置
+	ClientConfigFile = "ssrclient.json"
+	// NodeConfigFile 节点配置
+	NodeConfigFile = "node.json"
+	// DatabaseFile 保存账号和流量记录的数据库
+	DatabaseFile = "schannel-users.db"
+)
+
+const (
+	// 归档文件开头的标识
+	magic = "SCHANNEL-QT5-BACKUP\n"
+	// 归档格式的版本
+	formatVersion = 1
+	saltSize      = 16
+	keySize       = 32
+
+	// scrypt参数
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	// 归档的最大大小，防止读取错误的文件时耗尽内存
+	maxArchiveSize = 512 << 20
+)
+
+var (
+	// ErrFormat 不是schannel-qt5的备份文件
+	ErrFormat = errors.New("not a schannel-qt5 backup")
+	// ErrVersion 不支持的备份格式版本
+	ErrVersion = errors.New("unsupported backup version")
+	// ErrPassphrase 密码错误或者文件已损坏
+	ErrPassphrase = errors.New("wrong passphrase or corrupted backup")
+	// ErrEmptyPassphrase 密码不能为空
+	ErrEmptyPassphrase = errors.New("passphrase is empty")
+)
+
+// deriveKey 使用scrypt从passphrase生成AES-256密钥
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+
+	return scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keySize)
+}
+
+// newGCM 根据passphrase和salt生成AES-GCM
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Export 将files打包、压缩并用passphrase加密后写入w，key为归档中的文件名
+// 格式为：magic | 版本 | scrypt salt | GCM nonce | 加密的tar.gz，头部作为GCM的附加数据
+func Export(w io.Writer, files map[string][]byte, passphrase string) error {
+	data, err := packFiles(files)
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	header := append([]byte(magic), formatVersion)
+	header = append(header, salt...)
+	header = append(header, nonce...)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err = w.Write(gcm.Seal(nil, nonce, data, header))
+	return err
+}
+
+// Open 从r读取归档并用passphrase解密，返回文件名到内容的map
+func Open(r io.Reader, passphrase string) (map[string][]byte, error) {
+	content, err := ioutil.ReadAll(io.LimitReader(r, maxArchiveSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxArchiveSize || !bytes.HasPrefix(content, []byte(magic)) {
+		return nil, ErrFormat
+	}
+	rest := content[len(magic):]
+	if len(rest) < 1+saltSize {
+		return nil, ErrFormat
+	}
+	if rest[0] != formatVersion {
+		return nil, ErrVersion
+	}
+
+	salt := rest[1 : 1+saltSize]
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	headerSize := len(magic) + 1 + saltSize + gcm.NonceSize()
+	if len(content) < headerSize {
+		return nil, ErrFormat
+	}
+	header := content[:headerSize]
+	nonce := header[headerSize-gcm.NonceSize():]
+	data, err := gcm.Open(nil, nonce, content[headerSize:], header)
+	if err != nil {
+		return nil, ErrPassphrase
+	}
+
+	return unpackFiles(data)
+}
+
+// packFiles 将files打包为tar.gz，文件按名称排序
+func packFiles(files map[string][]byte) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, name := range names {
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    int64(len(files[name])),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// unpackFiles 解压tar.gz中的普通文件
+func unpackFiles(data []byte) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrFormat
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, ErrFormat
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, ErrFormat
+		}
+		files[header.Name] = content
+	}
+
+	return files, nil
+}
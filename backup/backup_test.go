@@ -0,0 +1,52 @@
+package backup
+
+import (
+	"testing"
+
+	"bytes"
+	"reflect"
+)
+
+func TestExportOpen(t *testing.T) {
+	files := map[string][]byte{
+		ConfigFile:     []byte(`{"proxy_url": ""}`),
+		NodeConfigFile: []byte(`{"node_name": "a"}`),
+		DatabaseFile:   {0, 1, 2, 3},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := Export(buf, files, "passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	archive := buf.Bytes()
+	if bytes.Contains(archive, []byte("node_name")) {
+		t.Errorf("归档中含有明文\n")
+	}
+
+	res, err := Open(bytes.NewReader(archive), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, files) {
+		t.Errorf("want: %v, have: %v\n", files, res)
+	}
+
+	if _, err := Open(bytes.NewReader(archive), "wrong"); err != ErrPassphrase {
+		t.Errorf("错误的密码应该返回ErrPassphrase: %v\n", err)
+	}
+	// 修改头部或内容都无法解密
+	for _, i := range []int{len(magic) + 2, len(archive) - 1} {
+		tampered := append([]byte{}, archive...)
+		tampered[i] ^= 1
+		if _, err := Open(bytes.NewReader(tampered), "passphrase"); err != ErrPassphrase {
+			t.Errorf("被修改的第%d字节没有被发现: %v\n", i, err)
+		}
+	}
+
+	if _, err := Open(bytes.NewReader([]byte(`{"proxy_url": ""}`)), "passphrase"); err != ErrFormat {
+		t.Errorf("不是备份文件时应该返回ErrFormat: %v\n", err)
+	}
+	if err := Export(new(bytes.Buffer), files, ""); err != ErrEmptyPassphrase {
+		t.Errorf("空密码应该返回ErrEmptyPassphrase: %v\n", err)
+	}
+}
@@ -0,0 +1,176 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+
+	"schannel-qt5/atomicfile"
+)
+
+// Mode 导入时处理本机已有数据的方式
+type Mode int
+
+const (
+	// Merge 保留本机已有的设置、节点、账号和流量记录，只添加缺少的部分
+	Merge Mode = iota
+	// Replace 使用备份中的文件覆盖本机的文件
+	Replace
+)
+
+// ErrMode 不支持的导入方式
+var ErrMode = errors.New("import mode must be merge or replace")
+
+// ParseMode 解析导入方式，s为merge或replace
+func ParseMode(s string) (Mode, error) {
+	switch s {
+	case "merge":
+		return Merge, nil
+	case "replace":
+		return Replace, nil
+	}
+
+	return Merge, ErrMode
+}
+
+// 配置文件中客户端配置和节点配置路径的key，导入时改写为本机的路径
+const (
+	clientConfigPathKey = "ssr_client_config_path"
+	nodeConfigPathKey   = "ssr_node_config_path"
+)
+
+// Paths 本机上各个文件的路径，为空的项不会被导出和导入
+type Paths struct {
+	Config       string
+	ClientConfig string
+	NodeConfig   string
+	Database     string
+}
+
+// path 返回归档中的文件name在本机上的路径
+func (p Paths) path(name string) string {
+	switch name {
+	case ConfigFile:
+		return p.Config
+	case ClientConfigFile:
+		return p.ClientConfig
+	case NodeConfigFile:
+		return p.NodeConfig
+	case DatabaseFile:
+		return p.Database
+	}
+
+	return ""
+}
+
+// 导入时的顺序，配置文件最后写入，此时其引用的文件已经存在
+var importOrder = []string{ClientConfigFile, NodeConfigFile, DatabaseFile, ConfigFile}
+
+// ReadFiles 读取paths中需要导出的文件，不存在的文件会被跳过
+func ReadFiles(paths Paths) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for _, name := range importOrder {
+		path := paths.path(name)
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		var data []byte
+		var err error
+		if name == DatabaseFile {
+			data, err = ReadDatabase(path)
+		} else {
+			data, err = ioutil.ReadFile(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		files[name] = data
+	}
+
+	return files, nil
+}
+
+// Import 将归档中的文件按照mode写入paths，返回被写入的文件路径
+// 备份的配置文件中客户端配置和节点配置的路径会被改写为paths中的路径
+func Import(files map[string][]byte, paths Paths, mode Mode) ([]string, error) {
+	written := make([]string, 0, len(files))
+	for _, name := range importOrder {
+		data, ok := files[name]
+		path := paths.path(name)
+		if !ok || path == "" {
+			continue
+		}
+
+		var err error
+		if name == ConfigFile {
+			if data, err = rewritePaths(data, paths); err != nil {
+				return written, err
+			}
+		}
+
+		_, err = os.Stat(path)
+		if err != nil && !os.IsNotExist(err) {
+			return written, err
+		}
+		if exists := err == nil; mode == Merge && exists {
+			switch name {
+			case NodeConfigFile:
+				// 保留本机选择的节点
+				continue
+			case DatabaseFile:
+				if err := MergeDatabase(path, data); err != nil {
+					return written, err
+				}
+				written = append(written, path)
+				continue
+			default:
+				old, err := ioutil.ReadFile(path)
+				if err != nil {
+					return written, err
+				}
+				if data, err = MergeJSON(old, data); err != nil {
+					return written, err
+				}
+			}
+		}
+
+		if err := atomicfile.WriteFile(path, data, 0664); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+
+	return written, nil
+}
+
+// rewritePaths 将配置文件中客户端配置和节点配置的路径改写为paths中的路径
+func rewritePaths(config []byte, paths Paths) ([]byte, error) {
+	content := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(config, &content); err != nil {
+		return nil, err
+	}
+
+	rewrites := map[string]string{
+		clientConfigPathKey: paths.ClientConfig,
+		nodeConfigPathKey:   paths.NodeConfig,
+	}
+	for key, path := range rewrites {
+		if path == "" {
+			continue
+		}
+		value, err := json.Marshal(path)
+		if err != nil {
+			return nil, err
+		}
+		content[key] = value
+	}
+
+	return json.MarshalIndent(content, "", "\t")
+}
@@ -0,0 +1,73 @@
+package backup
+
+import (
+	"testing"
+
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func TestImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schannel-qt5-backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	paths := Paths{
+		Config:       filepath.Join(dir, "config", ConfigFile),
+		ClientConfig: filepath.Join(dir, "config", ClientConfigFile),
+		NodeConfig:   filepath.Join(dir, "data", NodeConfigFile),
+	}
+	files := map[string][]byte{
+		ConfigFile:       []byte(`{"proxy_url": "http://proxy:3128", "ssr_client_config_path": "/home/old/c.json", "ssr_node_config_path": "/home/old/n.json"}`),
+		ClientConfigFile: []byte(`{"local_port": "1080"}`),
+		NodeConfigFile:   []byte(`{"node_name": "imported"}`),
+	}
+
+	// 本机没有文件时直接写入，路径被改写为本机的路径
+	written, err := Import(files, paths, Merge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 3 {
+		t.Errorf("写入的文件错误: %v\n", written)
+	}
+	config := make(map[string]string)
+	data, _ := ioutil.ReadFile(paths.Config)
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config[clientConfigPathKey] != paths.ClientConfig || config[nodeConfigPathKey] != paths.NodeConfig {
+		t.Errorf("配置中的路径没有被改写: %s\n", data)
+	}
+
+	// 合并时保留本机的设置和节点
+	ioutil.WriteFile(paths.ClientConfig, []byte(`{"local_port": "1090"}`), 0664)
+	ioutil.WriteFile(paths.NodeConfig, []byte(`{"node_name": "local"}`), 0664)
+	files[ClientConfigFile] = []byte(`{"local_port": "1080", "fast_open": true}`)
+	if _, err := Import(files, paths, Merge); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(paths.NodeConfig); string(data) != `{"node_name": "local"}` {
+		t.Errorf("合并时本机的节点被覆盖: %s\n", data)
+	}
+	client := make(map[string]interface{})
+	data, _ = ioutil.ReadFile(paths.ClientConfig)
+	if err := json.Unmarshal(data, &client); err != nil {
+		t.Fatal(err)
+	}
+	if client["local_port"] != "1090" || client["fast_open"] != true {
+		t.Errorf("客户端配置合并错误: %s\n", data)
+	}
+
+	// 替换时使用备份中的内容
+	if _, err := Import(files, paths, Replace); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(paths.NodeConfig); string(data) != `{"node_name": "imported"}` {
+		t.Errorf("替换时节点没有被覆盖: %s\n", data)
+	}
+}
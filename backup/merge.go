@@ -0,0 +1,151 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// MergeJSON 将json object src合并到dst，dst中已有的key保持不变，两边都是object的值递归合并
+func MergeJSON(dst, src []byte) ([]byte, error) {
+	dstMap := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(dst, &dstMap); err != nil {
+		return nil, err
+	}
+	srcMap := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(src, &srcMap); err != nil {
+		return nil, err
+	}
+
+	for key, value := range srcMap {
+		old, ok := dstMap[key]
+		if !ok {
+			dstMap[key] = value
+			continue
+		}
+		// 不是object时MergeJSON返回错误，保持dst的值
+		if merged, err := MergeJSON(old, value); err == nil {
+			dstMap[key] = merged
+		}
+	}
+
+	return json.MarshalIndent(dstMap, "", "\t")
+}
+
+// ReadDatabase 返回sqlite数据库path的一致的快照，其他进程可以同时写入数据库
+func ReadDatabase(path string) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "schannel-qt5-backup")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	snapshot := filepath.Join(dir, DatabaseFile)
+	if _, err := db.Exec("VACUUM INTO ?", snapshot); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(snapshot)
+}
+
+// MergeDatabase 将数据库内容src中的账号和流量记录合并到数据库path
+// 已有的账号保持不变，同一用户、服务和日期的流量记录只保留path中的
+func MergeDatabase(path string, src []byte) error {
+	dir, err := ioutil.TempDir("", "schannel-qt5-backup")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	srcPath := filepath.Join(dir, DatabaseFile)
+	if err := ioutil.WriteFile(srcPath, src, 0600); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// ATTACH只对当前连接有效，且不能在事务中执行
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS imported", srcPath); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "DETACH DATABASE imported")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// 表名和列名与models中beego orm生成的一致
+	statements := []string{
+		`INSERT OR IGNORE INTO user (name, passwd) SELECT name, passwd FROM imported.user`,
+		`INSERT INTO used_amount (service, total, upload, download, date, user_id)
+			SELECT i.service, i.total, i.upload, i.download, i.date, i.user_id FROM imported.used_amount AS i
+			WHERE NOT EXISTS (SELECT 1 FROM used_amount AS u
+				WHERE u.service = i.service AND u.date = i.date AND u.user_id = i.user_id)`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ClearStoredPasswords 将数据库内容src中密码为marker的账号改为不记住密码，返回修改后的内容和账号数
+// 这些账号的密码保存在导出时本机的CredentialStore中，没有包含在备份里
+func ClearStoredPasswords(src []byte, marker string) ([]byte, int, error) {
+	dir, err := ioutil.TempDir("", "schannel-qt5-backup")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, DatabaseFile)
+	if err := ioutil.WriteFile(path, src, 0600); err != nil {
+		return nil, 0, err
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, 0, err
+	}
+	res, err := db.Exec(`UPDATE user SET passwd = '' WHERE passwd = ?`, marker)
+	if err != nil {
+		db.Close()
+		return nil, 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		db.Close()
+		return nil, 0, err
+	}
+	// 关闭后修改才完整地写入文件
+	if err := db.Close(); err != nil {
+		return nil, 0, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, int(n), nil
+}
@@ -0,0 +1,139 @@
+package backup
+
+import (
+	"testing"
+
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+func TestMergeJSON(t *testing.T) {
+	dst := []byte(`{"proxy_url": "", "pac": {"port": "1090"}, "check_endpoints": ["https://a"]}`)
+	src := []byte(`{"proxy_url": "http://proxy:3128", "pac": {"port": "1091", "addr": "::1"}, "check_endpoints": ["https://b"], "http_proxy_port": "8118"}`)
+
+	data, err := MergeJSON(dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res interface{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"proxy_url":       "",
+		"pac":             map[string]interface{}{"port": "1090", "addr": "::1"},
+		"check_endpoints": []interface{}{"https://a"},
+		"http_proxy_port": "8118",
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("want: %v, have: %v\n", want, res)
+	}
+
+	if _, err := MergeJSON([]byte("[]"), src); err == nil {
+		t.Errorf("不是object时应该返回错误\n")
+	}
+}
+
+// createDatabase 创建与models相同结构的数据库并插入数据
+func createDatabase(t *testing.T, path string, statements ...string) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	schema := []string{
+		`CREATE TABLE user (name varchar(100) NOT NULL PRIMARY KEY, passwd varchar(100))`,
+		`CREATE TABLE used_amount (id integer NOT NULL PRIMARY KEY AUTOINCREMENT, service varchar(50) NOT NULL DEFAULT '',
+			total integer NOT NULL DEFAULT 0, upload integer NOT NULL DEFAULT 0, download integer NOT NULL DEFAULT 0,
+			date date NOT NULL, user_id varchar(100) NOT NULL)`,
+	}
+	for _, statement := range append(schema, statements...) {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMergeDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schannel-qt5-backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	local := filepath.Join(dir, "local.db")
+	createDatabase(t, local,
+		`INSERT INTO user VALUES ('a', 'local')`,
+		`INSERT INTO used_amount (service, total, upload, download, date, user_id) VALUES ('s', 10, 1, 9, '2026-01-01', 'a')`,
+	)
+	imported := filepath.Join(dir, "imported.db")
+	createDatabase(t, imported,
+		`INSERT INTO user VALUES ('a', 'imported'), ('b', 'imported')`,
+		`INSERT INTO used_amount (service, total, upload, download, date, user_id) VALUES
+			('s', 20, 2, 18, '2026-01-01', 'a'), ('s', 30, 3, 27, '2026-01-02', 'a'), ('s', 5, 1, 4, '2026-01-01', 'b')`,
+	)
+
+	data, err := ReadDatabase(imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := MergeDatabase(local, data); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite3", local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var passwd string
+	if err := db.QueryRow(`SELECT passwd FROM user WHERE name = 'a'`).Scan(&passwd); err != nil || passwd != "local" {
+		t.Errorf("本机的账号被覆盖: %s, %v\n", passwd, err)
+	}
+	var users, amounts, total int
+	db.QueryRow(`SELECT count(*) FROM user`).Scan(&users)
+	db.QueryRow(`SELECT count(*), sum(total) FROM used_amount`).Scan(&amounts, &total)
+	if users != 2 || amounts != 3 || total != 45 {
+		t.Errorf("合并结果错误: users: %d, amounts: %d, total: %d\n", users, amounts, total)
+	}
+}
+
+func TestClearStoredPasswords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schannel-qt5-backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "imported.db")
+	createDatabase(t, path, `INSERT INTO user VALUES ('a', '*marker*'), ('b', 'legacy'), ('c', '')`)
+	data, err := ReadDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, n, err := ClearStoredPasswords(data, "*marker*")
+	if err != nil || n != 1 {
+		t.Fatalf("清除标记出错: %d, %v\n", n, err)
+	}
+
+	cleared := filepath.Join(dir, "cleared.db")
+	if err := ioutil.WriteFile(cleared, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", cleared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for name, want := range map[string]string{"a": "", "b": "legacy"} {
+		var passwd string
+		if err := db.QueryRow(`SELECT passwd FROM user WHERE name = ?`, name).Scan(&passwd); err != nil || passwd != want {
+			t.Errorf("%s的密码错误: want: %q, have: %q, %v\n", name, want, passwd, err)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"schannel-qt5/atomicfile"
+	"schannel-qt5/backup"
+	"schannel-qt5/config"
+	"schannel-qt5/models"
+)
+
+// errPassphraseMismatch 两次输入的密码不一致
+var errPassphraseMismatch = errors.New("passphrases don't match")
+
+// 读取密码使用的标准输入，多次读取时共用缓冲
+var stdin = bufio.NewReader(os.Stdin)
+
+// backupFlags 导出和导入备份的命令行参数
+type backupFlags struct {
+	exportPath string
+	importPath string
+	mode       string
+}
+
+// register 在fs中注册导出和导入的参数
+func (b *backupFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&b.exportPath, "export", "", "export the config, client config, node config and user database into an encrypted `file` and exit")
+	fs.StringVar(&b.importPath, "import", "", "import an encrypted `file` created by --export and exit")
+	fs.StringVar(&b.mode, "import-mode", "merge", "merge: keep local settings, node and records and only add missing ones; replace: overwrite local files")
+}
+
+// requested 是否指定了导出或导入
+func (b *backupFlags) requested() bool {
+	return b.exportPath != "" || b.importPath != ""
+}
+
+// run 执行导出或导入，没有指定时返回false
+func (b *backupFlags) run() (bool, error) {
+	switch {
+	case b.exportPath != "" && b.importPath != "":
+		return true, errors.New("--export and --import can't be used together")
+	case b.exportPath != "":
+		return true, exportBackup(b.exportPath)
+	case b.importPath != "":
+		return true, importBackup(b.importPath, b.mode)
+	}
+
+	return false, nil
+}
+
+// backupPaths 返回当前配置方案需要备份的文件在本机上的路径
+// 配置文件不存在时客户端配置和节点配置使用首次运行的默认路径
+func backupPaths() (backup.Paths, error) {
+	paths := backup.Paths{}
+	var err error
+	if paths.Config, err = config.ConfigPath(); err != nil {
+		return paths, err
+	}
+	if paths.Database, err = models.GetDBPath(); err != nil {
+		return paths, err
+	}
+
+	exists, err := config.ConfigExists()
+	if err != nil {
+		return paths, err
+	}
+	if !exists {
+		if paths.ClientConfig, err = config.DefaultSSRClientConfigPath(); err != nil {
+			return paths, err
+		}
+		paths.NodeConfig, err = config.DefaultSSRNodeConfigPath()
+		return paths, err
+	}
+
+	// 使用配置文件中的路径，不受命令行和环境变量的覆盖影响
+	conf := &config.UserConfig{}
+	if err := conf.LoadConfig(); err != nil {
+		return paths, err
+	}
+	if paths.ClientConfig, err = conf.SSRClientConfigPath.AbsPath(); err != nil {
+		return paths, err
+	}
+	paths.NodeConfig, err = conf.SSRNodeConfigPath.AbsPath()
+	return paths, err
+}
+
+// exportBackup 将当前的配置和用户数据加密导出到path
+func exportBackup(path string) error {
+	paths, err := backupPaths()
+	if err != nil {
+		return err
+	}
+	files, err := backup.ReadFiles(paths)
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := readPassphrase("passphrase: ")
+	if err != nil {
+		return err
+	}
+	if isTerminal(os.Stdin) {
+		confirm, err := readPassphrase("confirm passphrase: ")
+		if err != nil {
+			return err
+		}
+		if confirm != passphrase {
+			return errPassphraseMismatch
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := backup.Export(buf, files, passphrase); err != nil {
+		return err
+	}
+	// 备份中含有账号信息，只有当前用户可读写
+	if err := atomicfile.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(os.Stderr, prefix+"exported "+name)
+	}
+	return nil
+}
+
+// importBackup 解密path处的备份并按照modeName导入
+func importBackup(path, modeName string) error {
+	mode, err := backup.ParseMode(modeName)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	passphrase, err := readPassphrase("passphrase: ")
+	if err != nil {
+		return err
+	}
+	files, err := backup.Open(f, passphrase)
+	if err != nil {
+		return err
+	}
+	paths, err := backupPaths()
+	if err != nil {
+		return err
+	}
+
+	// 记住的密码保存在导出时本机的CredentialStore中，导入的账号改为不记住密码
+	if data, ok := files[backup.DatabaseFile]; ok {
+		cleared, n, err := backup.ClearStoredPasswords(data, models.StoredPasswd)
+		if err != nil {
+			return err
+		}
+		files[backup.DatabaseFile] = cleared
+		if n != 0 {
+			fmt.Fprintf(os.Stderr, "%swarning: remembered passwords of %d accounts are not included in the backup, enter them again when logging in\n", prefix, n)
+		}
+	}
+
+	written, err := backup.Import(files, paths, mode)
+	for _, path := range written {
+		fmt.Fprintln(os.Stderr, prefix+"imported "+path)
+	}
+	return err
+}
+
+// getTermios 读取终端fd的设置，fd不是终端时返回false
+func getTermios(fd uintptr, termios *syscall.Termios) bool {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCGETS, uintptr(unsafe.Pointer(termios)))
+	return errno == 0
+}
+
+// setTermios 修改终端fd的设置
+func setTermios(fd uintptr, termios *syscall.Termios) {
+	syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCSETS, uintptr(unsafe.Pointer(termios)))
+}
+
+// isTerminal f是否为终端
+func isTerminal(f *os.File) bool {
+	var termios syscall.Termios
+	return getTermios(f.Fd(), &termios)
+}
+
+// readPassphrase 读取一行密码，标准输入为终端时显示prompt且不回显输入
+func readPassphrase(prompt string) (string, error) {
+	fd := os.Stdin.Fd()
+	var old syscall.Termios
+	if getTermios(fd, &old) {
+		fmt.Fprint(os.Stderr, prompt)
+		noEcho := old
+		noEcho.Lflag &^= syscall.ECHO
+		setTermios(fd, &noEcho)
+		defer func() {
+			setTermios(fd, &old)
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+
+	line, err := stdin.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
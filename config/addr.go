@@ -0,0 +1,86 @@
+package config
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrEmptyHost 地址为空
+	ErrEmptyHost = errors.New("host is empty")
+	// ErrInvalidHost 既不是ip地址也不是合法的主机名
+	ErrInvalidHost = errors.New("not a valid ip address or hostname")
+	// ErrInvalidPort 端口不是1-65535之间的数字
+	ErrInvalidPort = errors.New("port over range")
+)
+
+// ParseBindAddr 检查本地监听地址，返回去除方括号后的地址
+// 地址可以是IPv4、IPv6(可以使用"[::1]"的形式)或者主机名
+func ParseBindAddr(addr string) (string, error) {
+	if strings.HasPrefix(addr, "[") && strings.HasSuffix(addr, "]") {
+		addr = addr[1 : len(addr)-1]
+		if ip := net.ParseIP(addr); ip == nil || ip.To4() != nil {
+			return "", ErrInvalidHost
+		}
+	}
+
+	if err := CheckHost(addr); err != nil {
+		return "", err
+	}
+	return addr, nil
+}
+
+// CheckHost 检查host是否为ip地址或者合法的主机名
+func CheckHost(host string) error {
+	if host == "" {
+		return ErrEmptyHost
+	}
+	if net.ParseIP(host) != nil || IsHostname(host) {
+		return nil
+	}
+
+	return ErrInvalidHost
+}
+
+// CheckPort 检查端口是否为1-65535之间的数字
+func CheckPort(port string) error {
+	p, err := strconv.Atoi(port)
+	if err != nil || p <= 0 || p > 65535 {
+		return ErrInvalidPort
+	}
+
+	return nil
+}
+
+// IsHostname 判断是否为合法的主机名，允许不含"."的名称，例如localhost
+// 最后一段全部为数字时不是主机名，避免把错误的IPv4地址当作主机名
+func IsHostname(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if len(s) == 0 || len(s) > 253 {
+		return false
+	}
+
+	labels := strings.Split(s, ".")
+	if _, err := strconv.Atoi(labels[len(labels)-1]); err == nil {
+		return false
+	}
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			switch {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+			default:
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -4,24 +4,44 @@ import (
 	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
+
+	"schannel-qt5/atomicfile"
+	"schannel-qt5/urls"
+	"schannel-qt5/xdg"
 )
 
 const (
-	// 默认配置文件路径
-	configPath = ".local/share/schannel-qt5.json"
+	// 配置文件名，位于$XDG_CONFIG_HOME/schannel-qt5下
+	configFileName = "schannel-qt5.json"
+	// 旧版本使用的配置文件路径，相对于$HOME，读取时会被移动到新位置
+	legacyConfigPath = ".local/share/schannel-qt5.json"
+	// DefaultSSRClientType 未设置ssr_client_type时使用的客户端
+	DefaultSSRClientType = "python"
+	// 内置http代理默认监听的地址
+	defaultHTTPProxyAddr = "127.0.0.1"
+
+	// 首次运行时默认的节点配置和客户端配置文件名
+	defaultNodeConfigName   = "node.json"
+	defaultClientConfigName = "ssrclient.json"
 )
 
 var (
 	// ErrHOME 无法查找$HOME
-	ErrHOME = errors.New("can't find $HOME in your environments")
+	ErrHOME = xdg.ErrHOME
 	// ErrNotAbs 路径无法解析为绝对路径
 	ErrNotAbs = errors.New("path is not an abs path")
+	// ErrClientType 没有注册对应的ssr客户端
+	ErrClientType = errors.New("ssr client type not registered")
 )
 
 // UserConfig 用户配置
 type UserConfig struct {
+	// 配置文件格式的版本，读取时旧版本会被自动升级
+	Version int `json:"version"`
+
 	// client config
 	Proxy   JSONProxy     `json:"proxy_url"`
 	LogFile JSONEmptyPath `json:"log_file"`
@@ -32,40 +52,206 @@ type UserConfig struct {
 
 	// ssr client bin path
 	SSRBin JSONPath `json:"ssr_bin"`
+	// ssr客户端的类型，为注册的Launcher名称
+	SSRClientType string `json:"ssr_client_type,omitempty"`
+	// 启动客户端时本地端口被占用则自动选择下一个空闲端口
+	SSRAutoPickPort bool `json:"ssr_auto_pick_port,omitempty"`
+	// 各个客户端使用的权限提升方式，key为客户端类型
+	SSRElevators map[string]ElevatorConfig `json:"ssr_elevators,omitempty"`
+
+	// 检查代理时访问的URL，为空时使用urls.ProxyTestPath
+	CheckEndpoints []string `json:"check_endpoints,omitempty"`
+	// 内置http代理监听的地址和端口，端口为空时不启用(default addr: 127.0.0.1)
+	HTTPProxyAddr string `json:"http_proxy_addr,omitempty"`
+	HTTPProxyPort string `json:"http_proxy_port,omitempty"`
+
+	// 返回请求方ip的URL，用于显示代理出口，为空时使用urls.ExitIPPath，"-"表示不检查
+	ExitIPEndpoint string `json:"exit_ip_endpoint,omitempty"`
+
+	// 本地PAC服务和规则列表
+	PAC PACConfig `json:"pac"`
+	// 客户端启动时设置的系统代理
+	SystemProxy SystemProxyConfig `json:"system_proxy"`
+	// 保存账号密码的方式，为空时自动选择
+	CredentialStore string `json:"credential_store,omitempty"`
 
 	// ssr client config的实体数据
 	SSRClientConfig ClientConfig `json:"-"`
+	// LoadConfig升级旧版本配置文件时变化的配置项
+	MigratedKeys []KeyChange `json:"-"`
+	// LoadConfig时覆盖配置文件的命令行参数和环境变量
+	Overrides Overrides `json:"-"`
+	// 被覆盖的配置项，保存时没有被修改的项写回配置文件中原来的值
+	overridden []overridden
+}
+
+// ClientType 返回ssr客户端类型，未设置时返回DefaultSSRClientType
+func (u *UserConfig) ClientType() string {
+	if u.SSRClientType == "" {
+		return DefaultSSRClientType
+	}
+
+	return u.SSRClientType
+}
+
+// ExitIPURL 返回检查代理出口ip使用的URL，不检查时返回空字符串
+func (u *UserConfig) ExitIPURL() string {
+	switch u.ExitIPEndpoint {
+	case "":
+		return urls.ExitIPPath
+	case "-":
+		return ""
+	}
+
+	return u.ExitIPEndpoint
+}
+
+// HTTPProxyEnabled 是否启用内置http代理
+func (u *UserConfig) HTTPProxyEnabled() bool {
+	return u.HTTPProxyPort != ""
+}
+
+// HTTPProxyHostPort 返回内置http代理监听的host:port
+func (u *UserConfig) HTTPProxyHostPort() string {
+	addr := u.HTTPProxyAddr
+	if addr == "" {
+		addr = defaultHTTPProxyAddr
+	}
+
+	return net.JoinHostPort(addr, u.HTTPProxyPort)
 }
 
-// ConfigPath 返回`～`被替换为$HOME的config path
+// Elevator 返回客户端类型为name时使用的权限提升方式，未设置时使用pkexec
+func (u *UserConfig) Elevator(name string) ElevatorConfig {
+	if e, ok := u.SSRElevators[name]; ok && e.Type != "" {
+		return e
+	}
+
+	return ElevatorConfig{Type: ElevatorPkexec}
+}
+
+// SetElevator 设置客户端类型为name时使用的权限提升方式
+func (u *UserConfig) SetElevator(name string, e ElevatorConfig) error {
+	if err := e.Valid(); err != nil {
+		return err
+	}
+
+	if u.SSRElevators == nil {
+		u.SSRElevators = make(map[string]ElevatorConfig)
+	}
+	u.SSRElevators[name] = e
+	return nil
+}
+
+// 通过SetConfigPath指定的配置文件路径
+var configPathOverride string
+
+// SetConfigPath 使用path代替默认的配置文件路径，为空时恢复默认路径
+// 配置方案位于path所在的目录，当前使用的方案会被重新读取
+func SetConfigPath(path string) error {
+	if path != "" && !filepath.IsAbs(path) {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		path = abs
+	}
+
+	configPathOverride = path
+	activeProfile = ""
+	return nil
+}
+
+// ConfigPath 返回当前配置方案的配置文件路径，见ProfilePath
 func ConfigPath() (string, error) {
-	home, err := os.UserHomeDir()
+	name, err := ActiveProfile()
 	if err != nil {
-		return "", ErrHOME
+		return "", err
 	}
 
-	return filepath.Join(home, configPath), nil
+	return ProfilePath(name)
 }
 
-// StoreConfig 将配置存储进ConfigPath路径的文件
-func (u *UserConfig) StoreConfig() error {
-	storePath, err := ConfigPath()
+// baseConfigPath 返回默认配置方案的配置文件路径，默认为$XDG_CONFIG_HOME/schannel-qt5/schannel-qt5.json
+// 使用默认路径时旧位置的配置文件会先被移动到新位置
+func baseConfigPath() (string, error) {
+	if configPathOverride != "" {
+		return configPathOverride, nil
+	}
+
+	path, err := xdg.ConfigFile(configFileName)
 	if err != nil {
-		return err
+		return "", err
+	}
+	legacy, err := xdg.HomeFile(legacyConfigPath)
+	if err != nil {
+		return "", err
 	}
+	if _, err := xdg.Migrate(legacy, path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// DefaultSSRNodeConfigPath 返回首次运行时默认的节点配置路径
+func DefaultSSRNodeConfigPath() (string, error) {
+	return xdg.DataFile(defaultNodeConfigName)
+}
 
-	f, err := os.OpenFile(storePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0664)
+// DefaultSSRClientConfigPath 返回首次运行时默认的客户端配置路径
+func DefaultSSRClientConfigPath() (string, error) {
+	return xdg.ConfigFile(defaultClientConfigName)
+}
+
+// ConfigExists 配置文件是否存在，不存在时需要进行首次运行设置
+func ConfigExists() (bool, error) {
+	path, err := ConfigPath()
 	if err != nil {
-		return err
+		return false, err
 	}
-	defer f.Close()
 
-	data, err := json.MarshalIndent(u, "", "\t")
+	_, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// BackupConfig 将当前配置方案的配置文件复制为<path>.bak，返回备份的路径
+// 用于在设置向导覆盖无法读取的配置文件之前保留原文件
+func BackupConfig() (string, error) {
+	path, err := ConfigPath()
+	if err != nil {
+		return "", err
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	backup := path + ".bak"
+	if err := atomicfile.WriteFile(backup, content, 0664); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+// StoreConfig 将配置原子地存储进ConfigPath路径的文件，目录不存在时会被创建
+func (u *UserConfig) StoreConfig() error {
+	storePath, err := ConfigPath()
 	if err != nil {
 		return err
 	}
 
-	if _, err = f.Write(data); err != nil {
+	u.Version = CurrentConfigVersion
+	data, err := u.marshalConfig()
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(storePath, data, 0664); err != nil {
 		return err
 	}
 
@@ -73,6 +259,9 @@ func (u *UserConfig) StoreConfig() error {
 	if err != nil {
 		return err
 	}
+	if err := os.MkdirAll(filepath.Dir(clientConfigPath), 0755); err != nil {
+		return err
+	}
 	if err := u.SSRClientConfig.Store(clientConfigPath); err != nil {
 		return err
 	}
@@ -81,6 +270,7 @@ func (u *UserConfig) StoreConfig() error {
 }
 
 // LoadConfig 从ConfigPath给出的配置文件路径读出配置
+// 旧版本的配置文件会先备份再升级，变化的配置项记录在MigratedKeys
 func (u *UserConfig) LoadConfig() error {
 	loadPath, err := ConfigPath()
 	if err != nil {
@@ -98,9 +288,24 @@ func (u *UserConfig) LoadConfig() error {
 		return err
 	}
 
+	data, u.MigratedKeys, err = migrateConfigFile(loadPath, data)
+	if err != nil {
+		return err
+	}
 	if err = json.Unmarshal(data, u); err != nil {
 		return err
 	}
+	if err := u.applyOverrides(data); err != nil {
+		return err
+	}
+
+	// 未设置ClientConfig时根据客户端类型生成
+	if u.SSRClientConfig == nil {
+		u.SSRClientConfig = newClientConfig(u.ClientType())
+		if u.SSRClientConfig == nil {
+			return ErrClientType
+		}
+	}
 
 	clientConfigPath, err := u.SSRClientConfigPath.AbsPath()
 	if err != nil {
@@ -4,26 +4,35 @@ import (
 	"testing"
 
 	"encoding/json"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
 func TestConfigPath(t *testing.T) {
 	testData := []*struct {
 		// 设置环境变量HOME的值
 		home string
-		res  string
+		// 设置环境变量XDG_CONFIG_HOME的值
+		configHome string
+		res        string
 	}{
 		{
 			home: "/home/test",
-			res:  "/home/test/" + configPath,
+			res:  "/home/test/.config/schannel-qt5/" + configFileName,
 		},
 		{
 			home: "/home/test/",
-			res:  "/home/test/" + configPath,
+			res:  "/home/test/.config/schannel-qt5/" + configFileName,
 		},
 		{
 			home: "/home/用户1/",
-			res:  "/home/用户1/" + configPath,
+			res:  "/home/用户1/.config/schannel-qt5/" + configFileName,
+		},
+		{
+			home:       "/home/test",
+			configHome: "/tmp/config",
+			res:        "/tmp/config/schannel-qt5/" + configFileName,
 		},
 	}
 
@@ -33,6 +42,7 @@ func TestConfigPath(t *testing.T) {
 		if err != nil {
 			t.Fatalf("无法设置$HOME: %v\n", err)
 		}
+		os.Setenv("XDG_CONFIG_HOME", v.configHome)
 		res, err := ConfigPath()
 		if err != nil {
 			t.Fatalf("获取Config Path错误：%v\n", err)
@@ -44,6 +54,25 @@ func TestConfigPath(t *testing.T) {
 	}
 }
 
+func TestSetConfigPath(t *testing.T) {
+	defer SetConfigPath("")
+
+	if err := SetConfigPath("/tmp/test/schannel-qt5.json"); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := ConfigPath(); err != nil || path != "/tmp/test/schannel-qt5.json" {
+		t.Errorf("指定的配置文件路径没有生效: %s, %v\n", path, err)
+	}
+
+	// 相对路径转换为绝对路径
+	if err := SetConfigPath("test.json"); err != nil {
+		t.Fatal(err)
+	}
+	if path, _ := ConfigPath(); !filepath.IsAbs(path) {
+		t.Errorf("路径没有被转换为绝对路径: %s\n", path)
+	}
+}
+
 func TestMarshalUserConf(t *testing.T) {
 	u := new(UserConfig)
 	u.SSRNodeConfigPath.Data = "/tmp/testing/t.json"
@@ -67,3 +96,122 @@ func TestUnmarshalUserConf(t *testing.T) {
 	}
 	t.Log(*u)
 }
+
+func TestUserConfigElevator(t *testing.T) {
+	u := new(UserConfig)
+	if e := u.Elevator("python"); e.Type != ElevatorPkexec {
+		t.Errorf("wrong default elevator: %v\n", e)
+	}
+
+	if err := u.SetElevator("python", ElevatorConfig{Type: "su"}); err != ErrElevatorType {
+		t.Errorf("set unknown elevator didn't fail\n")
+	}
+	if err := u.SetElevator("python", ElevatorConfig{Type: ElevatorCustom}); err != ErrElevatorCommand {
+		t.Errorf("set custom elevator without command didn't fail\n")
+	}
+
+	custom := ElevatorConfig{Type: ElevatorCustom, Command: []string{"doas"}}
+	if err := u.SetElevator("libev", custom); err != nil {
+		t.Fatal(err)
+	}
+	if e := u.Elevator("libev"); e.Type != ElevatorCustom || e.Command[0] != "doas" {
+		t.Errorf("wrong elevator: %v\n", e)
+	}
+	if e := u.Elevator("python"); e.Type != ElevatorPkexec {
+		t.Errorf("elevator of other client changed: %v\n", e)
+	}
+}
+
+func TestPACConfig(t *testing.T) {
+	p := PACConfig{}
+	if p.Enabled() {
+		t.Errorf("没有设置端口时不应启用PAC服务\n")
+	}
+
+	p.Port = "1090"
+	if url := p.URL(); url != "http://127.0.0.1:1090/proxy.pac" {
+		t.Errorf("PAC URL错误: %s\n", url)
+	}
+	p.Addr = "::1"
+	if url := p.URL(); url != "http://[::1]:1090/proxy.pac" {
+		t.Errorf("PAC URL错误: %s\n", url)
+	}
+
+	os.Setenv("HOME", "/home/test")
+	if path, err := p.UserRuleAbsPath(); err != nil || path != "/home/test/.local/share/schannel-qt5-user-rules.txt" {
+		t.Errorf("默认用户规则路径错误: %s, %v\n", path, err)
+	}
+	p.UserRulePath.Data = "/tmp/rules.txt"
+	if path, _ := p.UserRuleAbsPath(); path != "/tmp/rules.txt" {
+		t.Errorf("用户规则路径错误: %s\n", path)
+	}
+}
+
+func TestSystemProxyConfig(t *testing.T) {
+	s := SystemProxyConfig{}
+	if s.Enabled() || s.ProxyMode() != SystemProxySOCKS || s.Valid() != nil {
+		t.Errorf("默认配置错误: %+v\n", s)
+	}
+
+	s.Backend = "windows"
+	if err := s.Valid(); err != ErrSystemProxyBackend {
+		t.Errorf("不支持的设置方式没有返回错误: %v\n", err)
+	}
+	s.Backend = SystemProxyGnome
+	s.Mode = "ftp"
+	if err := s.Valid(); err != ErrSystemProxyMode {
+		t.Errorf("不支持的代理类型没有返回错误: %v\n", err)
+	}
+	s.Mode = SystemProxyPAC
+	if !s.Enabled() || s.Valid() != nil {
+		t.Errorf("配置应该可用: %+v\n", s)
+	}
+}
+
+func TestCredentialStoreType(t *testing.T) {
+	u := &UserConfig{}
+	if store, err := u.CredentialStoreType(); err != nil || store != CredentialStoreAuto {
+		t.Errorf("默认应该自动选择: %q, %v\n", store, err)
+	}
+	u.CredentialStore = CredentialStoreFile
+	if store, err := u.CredentialStoreType(); err != nil || store != CredentialStoreFile {
+		t.Errorf("want: %q, have: %q, %v\n", CredentialStoreFile, store, err)
+	}
+	u.CredentialStore = "plain"
+	if _, err := u.CredentialStoreType(); err != ErrCredentialStore {
+		t.Errorf("不支持的保存方式没有返回错误: %v\n", err)
+	}
+}
+
+func TestConfigExists(t *testing.T) {
+	home, err := ioutil.TempDir("", "schannel-qt5-home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", "")
+
+	if exists, err := ConfigExists(); err != nil || exists {
+		t.Errorf("配置文件不存在时应该返回false: %v, %v\n", exists, err)
+	}
+
+	// 旧位置的配置文件会被移动到新位置
+	legacy := filepath.Join(home, legacyConfigPath)
+	if err := os.MkdirAll(filepath.Dir(legacy), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(legacy, []byte("{}"), 0664); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := ConfigExists(); err != nil || !exists {
+		t.Errorf("配置文件存在时应该返回true: %v, %v\n", exists, err)
+	}
+	path, _ := ConfigPath()
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("旧的配置文件没有被移动到%s: %v\n", path, err)
+	}
+}
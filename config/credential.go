@@ -0,0 +1,36 @@
+package config
+
+import (
+	"errors"
+)
+
+// 保存账号密码的方式
+const (
+	// CredentialStoreAuto Secret Service可用时使用Secret Service，否则使用加密文件
+	CredentialStoreAuto = ""
+	// CredentialStoreSecretService 使用freedesktop Secret Service，例如gnome-keyring和KWallet
+	CredentialStoreSecretService = "secret-service"
+	// CredentialStoreFile 使用主密码加密的文件
+	CredentialStoreFile = "file"
+)
+
+// ErrCredentialStore 不支持的密码保存方式
+var ErrCredentialStore = errors.New("unknown credential store")
+
+// CredentialStores 所有可用的密码保存方式，空字符串表示自动选择
+var CredentialStores = []string{
+	CredentialStoreAuto,
+	CredentialStoreSecretService,
+	CredentialStoreFile,
+}
+
+// CredentialStoreType 返回密码保存方式，不支持的值返回ErrCredentialStore
+func (u *UserConfig) CredentialStoreType() (string, error) {
+	for _, store := range CredentialStores {
+		if u.CredentialStore == store {
+			return store, nil
+		}
+	}
+
+	return "", ErrCredentialStore
+}
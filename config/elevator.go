@@ -0,0 +1,55 @@
+package config
+
+import (
+	"errors"
+)
+
+// 权限提升方式
+const (
+	// ElevatorNone 不提升权限
+	ElevatorNone = "none"
+	// ElevatorPkexec 使用pkexec在图形界面中请求权限
+	ElevatorPkexec = "pkexec"
+	// ElevatorSudo 使用sudo -A，密码由$SUDO_ASKPASS指定的程序获取
+	ElevatorSudo = "sudo"
+	// ElevatorCustom 使用自定义的命令
+	ElevatorCustom = "custom"
+)
+
+var (
+	// ErrElevatorType 不支持的权限提升方式
+	ErrElevatorType = errors.New("unknown elevator type")
+	// ErrElevatorCommand 自定义权限提升方式没有设置命令
+	ErrElevatorCommand = errors.New("custom elevator needs a command")
+)
+
+// ElevatorTypes 所有可用的权限提升方式
+var ElevatorTypes = []string{
+	ElevatorNone,
+	ElevatorPkexec,
+	ElevatorSudo,
+	ElevatorCustom,
+}
+
+// ElevatorConfig 客户端需要root权限时的权限提升方式
+type ElevatorConfig struct {
+	// none, pkexec, sudo或者custom
+	Type string `json:"type"`
+	// custom使用的命令和参数，需要运行的客户端命令会追加在最后
+	Command []string `json:"command,omitempty"`
+}
+
+// Valid 检查配置是否可用
+func (e ElevatorConfig) Valid() error {
+	switch e.Type {
+	case ElevatorNone, ElevatorPkexec, ElevatorSudo:
+		return nil
+	case ElevatorCustom:
+		if len(e.Command) == 0 || e.Command[0] == "" {
+			return ErrElevatorCommand
+		}
+		return nil
+	}
+
+	return ErrElevatorType
+}
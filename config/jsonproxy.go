@@ -2,15 +2,15 @@ package config
 
 import (
 	"errors"
-	"regexp"
+	"net/url"
 	"strings"
 )
 
 var (
 	// ErrNotURL 不是合法的URL
 	ErrNotURL = errors.New("not an valid URL")
-
-	matcher = regexp.MustCompile(`^(http(s)?|socks5)://([\w\-]+\.)+[\w\-]+(:\d+)?(/[\w\- ./?%&=]*)?$`)
+	// ErrProxyScheme 不支持的代理协议
+	ErrProxyScheme = errors.New("proxy scheme should be http, https or socks5")
 )
 
 // JSONProxy 验证给定字符串是否是合法的URL
@@ -20,7 +20,36 @@ type JSONProxy struct {
 
 // IsURL 如果p的值是合法的URL，则返回true
 func (p *JSONProxy) IsURL() bool {
-	return matcher.MatchString(p.Data)
+	return p.Check() == nil
+}
+
+// Check 验证代理URL，返回具体的错误
+// 主机可以是IPv4、"[IPv6]"或者主机名，允许使用user:password认证
+func (p *JSONProxy) Check() error {
+	u, err := url.Parse(p.Data)
+	if err != nil || u.Opaque != "" {
+		return ErrNotURL
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return ErrProxyScheme
+	}
+
+	host := u.Hostname()
+	// IPv6地址需要使用方括号
+	if strings.Contains(host, ":") && !strings.HasPrefix(u.Host, "[") {
+		return ErrInvalidHost
+	}
+	if err := CheckHost(host); err != nil {
+		return err
+	}
+
+	if port := u.Port(); port != "" || strings.HasSuffix(u.Host, ":") {
+		return CheckPort(port)
+	}
+	return nil
 }
 
 func (p JSONProxy) String() string {
@@ -35,16 +64,18 @@ func (p *JSONProxy) UnmarshalJSON(b []byte) error {
 	p.Data = data
 
 	// 允许""表示不使用proxy
-	if !p.IsURL() && p.Data != "" {
-		return ErrNotURL
+	if p.Data == "" {
+		return nil
 	}
 
-	return nil
+	return p.Check()
 }
 
 func (p *JSONProxy) MarshalJSON() ([]byte, error) {
-	if !p.IsURL() && p.Data != "" {
-		return nil, ErrNotURL
+	if p.Data != "" {
+		if err := p.Check(); err != nil {
+			return nil, err
+		}
 	}
 
 	return []byte("\"" + p.Data + "\""), nil
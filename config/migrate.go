@@ -0,0 +1,145 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"schannel-qt5/atomicfile"
+)
+
+const (
+	// CurrentConfigVersion 当前配置文件格式的版本，没有version字段的配置文件为版本0
+	CurrentConfigVersion = 1
+	// 配置文件中记录版本的key
+	versionKey = "version"
+)
+
+var (
+	// ErrConfigVersion 配置文件的版本比程序支持的更新
+	ErrConfigVersion = errors.New("config version is newer than supported")
+	// ErrConfigFormat 配置文件的内容不是json object
+	ErrConfigFormat = errors.New("config is not a json object")
+)
+
+// KeyChange 迁移时一个配置项的变化，Old或New为nil表示该项不存在
+type KeyChange struct {
+	Key string
+	Old interface{}
+	New interface{}
+}
+
+// String 返回易于阅读的变化描述
+func (k KeyChange) String() string {
+	return fmt.Sprintf("%s: %s -> %s", k.Key, formatValue(k.Old), formatValue(k.New))
+}
+
+// formatValue 将配置项的值转换为json形式，nil表示未设置
+func formatValue(v interface{}) string {
+	if v == nil {
+		return "<unset>"
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// migration 将配置从版本i升级到i+1，返回变化的配置项
+type migration func(data map[string]interface{}) []KeyChange
+
+// migrations[i]将版本i的配置升级到版本i+1
+var migrations = []migration{
+	migrateV0ToV1,
+}
+
+// migrateV0ToV1 版本0依赖未设置时的默认客户端类型，
+// 写入当时的默认值，避免以后修改默认值时改变已有配置的行为
+func migrateV0ToV1(data map[string]interface{}) []KeyChange {
+	return setDefault(data, "ssr_client_type", DefaultSSRClientType)
+}
+
+// setDefault key不存在或为空时设置为value
+func setDefault(data map[string]interface{}, key string, value interface{}) []KeyChange {
+	if old, ok := data[key]; ok && old != nil && old != "" {
+		return nil
+	}
+
+	old := data[key]
+	data[key] = value
+	return []KeyChange{{Key: key, Old: old, New: value}}
+}
+
+// configVersion 返回配置的版本，没有version字段时为0
+func configVersion(data map[string]interface{}) (int, error) {
+	v, ok := data[versionKey]
+	if !ok || v == nil {
+		return 0, nil
+	}
+
+	f, ok := v.(float64)
+	if !ok || f < 0 || f != float64(int(f)) {
+		return 0, fmt.Errorf("invalid config version: %v", v)
+	}
+	return int(f), nil
+}
+
+// MigrateConfig 将配置文件内容逐步升级到CurrentConfigVersion
+// 返回升级后的内容、原来的版本和变化的配置项，已经是最新版本时返回原内容
+func MigrateConfig(content []byte) ([]byte, int, []KeyChange, error) {
+	data := make(map[string]interface{})
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, 0, nil, err
+	}
+	if data == nil {
+		return nil, 0, nil, ErrConfigFormat
+	}
+
+	version, err := configVersion(data)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if version > CurrentConfigVersion {
+		return nil, version, nil, ErrConfigVersion
+	} else if version == CurrentConfigVersion {
+		return content, version, nil, nil
+	}
+
+	changes := make([]KeyChange, 0)
+	for i := version; i < CurrentConfigVersion; i++ {
+		changes = append(changes, migrations[i](data)...)
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	changes = append(changes, KeyChange{Key: versionKey, Old: data[versionKey], New: CurrentConfigVersion})
+	data[versionKey] = CurrentConfigVersion
+
+	migrated, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return nil, version, nil, err
+	}
+	return migrated, version, changes, nil
+}
+
+// migrateConfigFile 升级path处的配置文件，升级前将原文件备份为path.v<版本>.bak
+// 返回升级后的内容和变化的配置项
+func migrateConfigFile(path string, content []byte) ([]byte, []KeyChange, error) {
+	migrated, version, changes, err := MigrateConfig(content)
+	if err != nil || len(changes) == 0 {
+		return migrated, nil, err
+	}
+
+	backup := fmt.Sprintf("%s.v%d.bak", path, version)
+	if err := atomicfile.WriteFile(backup, content, 0664); err != nil {
+		return nil, nil, err
+	}
+	if err := atomicfile.WriteFile(path, migrated, 0664); err != nil {
+		return nil, nil, err
+	}
+
+	return migrated, changes, nil
+}
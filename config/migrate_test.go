@@ -0,0 +1,99 @@
+package config
+
+import (
+	"testing"
+
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	v0 := `{"proxy_url":"","ssr_bin":"/tmp/a.out"}`
+	migrated, version, changes, err := MigrateConfig([]byte(v0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 0 {
+		t.Errorf("没有version字段时应该为版本0: %d\n", version)
+	}
+
+	want := []string{
+		`ssr_client_type: <unset> -> "python"`,
+		`version: <unset> -> 1`,
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("变化的配置项错误: %v\n", changes)
+	}
+	for i := range want {
+		if changes[i].String() != want[i] {
+			t.Errorf("want: %s, have: %s\n", want[i], changes[i])
+		}
+	}
+
+	u := new(UserConfig)
+	if err := json.Unmarshal(migrated, u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Version != CurrentConfigVersion || u.SSRClientType != DefaultSSRClientType || u.SSRBin.Data != "/tmp/a.out" {
+		t.Errorf("升级后的配置错误: %s\n", migrated)
+	}
+
+	// 已经是最新版本时不做修改
+	same, _, changes, err := MigrateConfig(migrated)
+	if err != nil || len(changes) != 0 || string(same) != string(migrated) {
+		t.Errorf("最新版本的配置不应该被修改: %v, %v\n", changes, err)
+	}
+
+	// 已有的设置不会被覆盖
+	_, _, changes, err = MigrateConfig([]byte(`{"ssr_client_type":"libev"}`))
+	if err != nil || len(changes) != 1 || changes[0].Key != versionKey {
+		t.Errorf("已有的客户端类型被修改: %v, %v\n", changes, err)
+	}
+
+	invalid := []string{`{"version":99}`, `{"version":"1"}`, `{"version":1.5}`, `[]`, `null`}
+	for _, v := range invalid {
+		if _, _, _, err := MigrateConfig([]byte(v)); err == nil {
+			t.Errorf("%s 应该返回错误\n", v)
+		}
+	}
+}
+
+func TestMigrateConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schannel-qt5-migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "schannel-qt5.json")
+	v0 := []byte(`{"ssr_bin":"/tmp/a.out"}`)
+	if err := ioutil.WriteFile(path, v0, 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, changes, err := migrateConfigFile(path, v0)
+	if err != nil || len(changes) == 0 {
+		t.Fatalf("升级失败: %v, %v\n", changes, err)
+	}
+	backup, err := ioutil.ReadFile(path + ".v0.bak")
+	if err != nil || string(backup) != string(v0) {
+		t.Errorf("备份文件错误: %s, %v\n", backup, err)
+	}
+	stored, err := ioutil.ReadFile(path)
+	if err != nil || string(stored) != string(migrated) {
+		t.Errorf("升级后的配置没有写入文件: %s, %v\n", stored, err)
+	}
+
+	// 再次读取时不会重复备份
+	if err := os.Remove(path + ".v0.bak"); err != nil {
+		t.Fatal(err)
+	}
+	if _, changes, err := migrateConfigFile(path, stored); err != nil || len(changes) != 0 {
+		t.Errorf("重复升级: %v, %v\n", changes, err)
+	}
+	if _, err := os.Stat(path + ".v0.bak"); !os.IsNotExist(err) {
+		t.Errorf("最新版本的配置不应该备份\n")
+	}
+}
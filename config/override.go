@@ -0,0 +1,386 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix 覆盖配置项的环境变量的前缀
+const EnvPrefix = "SCHANNEL_"
+
+var (
+	// ErrUnknownOption 没有对应的配置项
+	ErrUnknownOption = errors.New("unknown config option")
+	// ErrOptionValue 配置项的值类型错误
+	ErrOptionValue = errors.New("invalid config option value")
+)
+
+// optionKind 配置项的值在命令行和环境变量中的写法
+type optionKind int
+
+const (
+	// optionString 原样使用的字符串
+	optionString optionKind = iota
+	// optionBool true或false
+	optionBool
+	// optionNumber 整数
+	optionNumber
+	// optionList 逗号分隔的列表
+	optionList
+	// optionJSON 无法用简单形式表示的值，需要写成json
+	optionJSON
+)
+
+// Option 可以被命令行参数和环境变量覆盖的配置项
+type Option struct {
+	// Name 命令行参数名，由json key中的"_"和"."替换为"-"得到，例如pac-addr
+	Name string
+	// Env 环境变量名，例如SCHANNEL_PAC_ADDR
+	Env string
+	// Key 配置文件中的json key，嵌套的key使用"."连接，例如pac.addr
+	Key string
+
+	kind optionKind
+}
+
+// Usage 返回命令行参数的说明
+func (o Option) Usage() string {
+	switch o.kind {
+	case optionBool:
+		return fmt.Sprintf("override %s (true or false)", o.Key)
+	case optionList:
+		return fmt.Sprintf("override %s (comma separated list)", o.Key)
+	case optionJSON:
+		return fmt.Sprintf("override %s (json)", o.Key)
+	}
+
+	return "override " + o.Key
+}
+
+// toJSON 将命令行或环境变量中的值转换为json
+func (o Option) toJSON(value string) (json.RawMessage, error) {
+	var v interface{}
+	switch o.kind {
+	case optionString:
+		v = value
+	case optionBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, ErrOptionValue
+		}
+		v = b
+	case optionNumber:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, ErrOptionValue
+		}
+		v = i
+	case optionList:
+		list := make([]string, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v = list
+	case optionJSON:
+		if !json.Valid([]byte(value)) {
+			return nil, ErrOptionValue
+		}
+		return json.RawMessage(value), nil
+	}
+
+	return json.Marshal(v)
+}
+
+// Options 所有可以被覆盖的配置项，由UserConfig的字段生成，按Name排序
+var Options = userConfigOptions()
+
+// userConfigOptions 根据UserConfig的json tag生成配置项，version不能被覆盖
+func userConfigOptions() []Option {
+	options := structOptions(reflect.TypeOf(UserConfig{}), nil)
+	sort.Slice(options, func(i, j int) bool {
+		return options[i].Name < options[j].Name
+	})
+
+	return options
+}
+
+// structOptions 生成结构体t中各个字段的配置项，prefix为外层的json key
+func structOptions(t reflect.Type, prefix []string) []Option {
+	unmarshaler := reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	options := make([]Option, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := strings.Split(field.Tag.Get("json"), ",")[0]
+		if key == "" || key == "-" || key == versionKey {
+			continue
+		}
+		keys := append(append([]string{}, prefix...), key)
+
+		kind := optionJSON
+		switch ft := field.Type; {
+		case reflect.PtrTo(ft).Implements(unmarshaler), ft.Kind() == reflect.String:
+			kind = optionString
+		case ft.Kind() == reflect.Struct:
+			options = append(options, structOptions(ft, keys)...)
+			continue
+		case ft.Kind() == reflect.Bool:
+			kind = optionBool
+		case ft.Kind() == reflect.Int:
+			kind = optionNumber
+		case ft.Kind() == reflect.Slice && (ft.Elem().Kind() == reflect.String || reflect.PtrTo(ft.Elem()).Implements(unmarshaler)):
+			kind = optionList
+		}
+
+		name := strings.Replace(strings.Join(keys, "-"), "_", "-", -1)
+		options = append(options, Option{
+			Name: name,
+			Env:  EnvPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1)),
+			Key:  strings.Join(keys, "."),
+			kind: kind,
+		})
+	}
+
+	return options
+}
+
+// lookupOption 根据Name查找配置项
+func lookupOption(name string) (Option, bool) {
+	for _, o := range Options {
+		if o.Name == name {
+			return o, true
+		}
+	}
+
+	return Option{}, false
+}
+
+// Overrides 覆盖配置文件的配置项，key为Option.Name
+type Overrides map[string]string
+
+// EnvOverrides 从环境变量中读取以SCHANNEL_开头的配置项，environ的格式与os.Environ相同
+func EnvOverrides(environ []string) Overrides {
+	o := make(Overrides)
+	for _, env := range environ {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		for _, option := range Options {
+			if option.Env == kv[0] {
+				o[option.Name] = kv[1]
+				break
+			}
+		}
+	}
+
+	return o
+}
+
+// Merge 合并other中的配置项，other优先
+func (o Overrides) Merge(other Overrides) {
+	for name, value := range other {
+		o[name] = value
+	}
+}
+
+// RegisterFlags 为每个配置项在fs中注册命令行参数，解析时写入o
+func (o Overrides) RegisterFlags(fs *flag.FlagSet) {
+	for _, option := range Options {
+		fs.Var(&flagValue{option: option, overrides: o}, option.Name, option.Usage())
+	}
+}
+
+// flagValue 实现flag.Value，将命令行参数记录到Overrides
+type flagValue struct {
+	option    Option
+	overrides Overrides
+}
+
+func (f *flagValue) String() string {
+	if f == nil || f.overrides == nil {
+		return ""
+	}
+
+	return f.overrides[f.option.Name]
+}
+
+func (f *flagValue) Set(value string) error {
+	if _, err := f.option.toJSON(value); err != nil {
+		return err
+	}
+
+	f.overrides[f.option.Name] = value
+	return nil
+}
+
+// IsBoolFlag bool类型的配置项可以省略值
+func (f *flagValue) IsBoolFlag() bool {
+	return f.option.kind == optionBool
+}
+
+// overridden 被覆盖的配置项在配置文件中的值和覆盖后的值
+type overridden struct {
+	keys []string
+	// 配置文件中的值，为nil时表示文件中没有这一项
+	file json.RawMessage
+	// 覆盖后的值
+	value json.RawMessage
+}
+
+// applyOverrides 将u.Overrides覆盖到u，并记录配置文件content中原来的值
+func (u *UserConfig) applyOverrides(content []byte) error {
+	u.overridden = nil
+	if len(u.Overrides) == 0 {
+		return nil
+	}
+
+	fileData := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(content, &fileData); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(u.Overrides))
+	for name := range u.Overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		option, ok := lookupOption(name)
+		if !ok {
+			return fmt.Errorf("%v: %s", ErrUnknownOption, name)
+		}
+		value, err := option.toJSON(u.Overrides[name])
+		if err != nil {
+			return fmt.Errorf("%v: %s", err, name)
+		}
+
+		keys := strings.Split(option.Key, ".")
+		file, _ := getKey(fileData, keys)
+
+		fragment := value
+		for i := len(keys) - 1; i >= 0; i-- {
+			key, _ := json.Marshal(keys[i])
+			fragment = json.RawMessage(fmt.Sprintf("{%s:%s}", key, fragment))
+		}
+		if err := json.Unmarshal(fragment, u); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		u.overridden = append(u.overridden, overridden{keys: keys, file: file, value: value})
+	}
+
+	return nil
+}
+
+// marshalConfig 生成保存到配置文件的内容
+// 被覆盖的配置项在界面中没有被修改时保存配置文件中原来的值
+func (u *UserConfig) marshalConfig() ([]byte, error) {
+	if len(u.overridden) == 0 {
+		return json.MarshalIndent(u, "", "\t")
+	}
+
+	data, err := u.toMap()
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range u.overridden {
+		// omitempty的字段为空值时不会出现
+		current, ok := getKey(data, o.keys)
+		if ok && !jsonEqual(current, o.value) || !ok && !isEmptyJSON(o.value) {
+			continue
+		}
+		setKey(data, o.keys, o.file)
+	}
+
+	return json.MarshalIndent(data, "", "\t")
+}
+
+// toMap 将u序列化为map，值保持为json
+func (u *UserConfig) toMap() (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]json.RawMessage)
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+// getKey 返回嵌套的key对应的值
+func getKey(m map[string]json.RawMessage, keys []string) (json.RawMessage, bool) {
+	value, ok := m[keys[0]]
+	if !ok || len(keys) == 1 {
+		return value, ok
+	}
+
+	child := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(value, &child); err != nil {
+		return nil, false
+	}
+	return getKey(child, keys[1:])
+}
+
+// setKey 设置嵌套的key对应的值，value为nil时删除
+func setKey(m map[string]json.RawMessage, keys []string, value json.RawMessage) {
+	if len(keys) == 1 {
+		if value == nil {
+			delete(m, keys[0])
+		} else {
+			m[keys[0]] = value
+		}
+		return
+	}
+
+	child := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(m[keys[0]], &child); err != nil {
+		return
+	}
+	setKey(child, keys[1:], value)
+	if data, err := json.Marshal(child); err == nil {
+		m[keys[0]] = data
+	}
+}
+
+// isEmptyJSON 是否为omitempty会忽略的空值
+func isEmptyJSON(value json.RawMessage) bool {
+	var v interface{}
+	if err := json.Unmarshal(value, &v); err != nil {
+		return false
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// jsonEqual 两个json值是否相同
+func jsonEqual(a, b json.RawMessage) bool {
+	var bufA, bufB bytes.Buffer
+	if json.Compact(&bufA, a) != nil || json.Compact(&bufB, b) != nil {
+		return false
+	}
+
+	return bytes.Equal(bufA.Bytes(), bufB.Bytes())
+}
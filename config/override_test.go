@@ -0,0 +1,125 @@
+package config
+
+import (
+	"testing"
+
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"reflect"
+)
+
+func TestOptions(t *testing.T) {
+	want := map[string]Option{
+		"proxy-url":                 {Name: "proxy-url", Env: "SCHANNEL_PROXY_URL", Key: "proxy_url", kind: optionString},
+		"ssr-auto-pick-port":        {Name: "ssr-auto-pick-port", Env: "SCHANNEL_SSR_AUTO_PICK_PORT", Key: "ssr_auto_pick_port", kind: optionBool},
+		"ssr-elevators":             {Name: "ssr-elevators", Env: "SCHANNEL_SSR_ELEVATORS", Key: "ssr_elevators", kind: optionJSON},
+		"pac-addr":                  {Name: "pac-addr", Env: "SCHANNEL_PAC_ADDR", Key: "pac.addr", kind: optionString},
+		"pac-rule-files":            {Name: "pac-rule-files", Env: "SCHANNEL_PAC_RULE_FILES", Key: "pac.rule_files", kind: optionList},
+		"system-proxy-ignore-hosts": {Name: "system-proxy-ignore-hosts", Env: "SCHANNEL_SYSTEM_PROXY_IGNORE_HOSTS", Key: "system_proxy.ignore_hosts", kind: optionList},
+	}
+	for name, option := range want {
+		if have, ok := lookupOption(name); !ok || !reflect.DeepEqual(have, option) {
+			t.Errorf("wrong option:\n\twant: %+v\n\thave: %+v\n", option, have)
+		}
+	}
+	for _, name := range []string{"version", "ssr-client-config", "pac"} {
+		if _, ok := lookupOption(name); ok {
+			t.Errorf("%s 不应该可以被覆盖\n", name)
+		}
+	}
+}
+
+func TestOverridesPrecedence(t *testing.T) {
+	environ := []string{
+		"SCHANNEL_HTTP_PROXY_PORT=8118",
+		"SCHANNEL_PAC_PORT=1090",
+		"SCHANNEL_UNKNOWN=1",
+		"HOME=/home/test",
+	}
+	o := EnvOverrides(environ)
+	if !reflect.DeepEqual(o, Overrides{"http-proxy-port": "8118", "pac-port": "1090"}) {
+		t.Errorf("wrong env overrides: %v\n", o)
+	}
+
+	flags := make(Overrides)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	flags.RegisterFlags(fs)
+	if err := fs.Parse([]string{"--pac-port=1091", "--ssr-auto-pick-port", "file"}); err != nil {
+		t.Fatal(err)
+	}
+	if fs.NArg() != 1 {
+		t.Errorf("剩余的参数错误: %v\n", fs.Args())
+	}
+	o.Merge(flags)
+	want := Overrides{"http-proxy-port": "8118", "pac-port": "1091", "ssr-auto-pick-port": "true"}
+	if !reflect.DeepEqual(o, want) {
+		t.Errorf("命令行参数应该优先:\n\twant: %v\n\thave: %v\n", want, o)
+	}
+
+	if err := fs.Parse([]string{"--ssr-auto-pick-port=maybe"}); err == nil {
+		t.Errorf("错误的bool值没有返回错误\n")
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	content := []byte(`{
+		"proxy_url": "",
+		"ssr_bin": "/tmp/a.out",
+		"ssr_node_config_path": "/tmp/node.json",
+		"ssr_client_config_path": "/tmp/client.json",
+		"log_file": "",
+		"pac": {"addr": "127.0.0.1", "port": "1090", "gfwlist_path": "", "user_rule_path": ""},
+		"system_proxy": {"env_file": ""}
+	}`)
+	u := new(UserConfig)
+	if err := json.Unmarshal(content, u); err != nil {
+		t.Fatal(err)
+	}
+	u.Overrides = Overrides{
+		"proxy-url":                 "socks5://127.0.0.1:1080",
+		"pac-port":                  "1091",
+		"system-proxy-ignore-hosts": "localhost, 10.0.0.0/8",
+		"ssr-auto-pick-port":        "true",
+	}
+	if err := u.applyOverrides(content); err != nil {
+		t.Fatal(err)
+	}
+	if u.Proxy.String() != "socks5://127.0.0.1:1080" || u.PAC.Port != "1091" || u.PAC.Addr != "127.0.0.1" || !u.SSRAutoPickPort {
+		t.Errorf("覆盖后的配置错误: %+v\n", u)
+	}
+	if !reflect.DeepEqual(u.SystemProxy.IgnoreHosts, []string{"localhost", "10.0.0.0/8"}) {
+		t.Errorf("列表覆盖错误: %v\n", u.SystemProxy.IgnoreHosts)
+	}
+
+	// 没有在界面中修改的覆盖项保存原来的值，修改过的保存新值
+	u.PAC.Port = "1092"
+	data, err := u.marshalConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := new(UserConfig)
+	if err := json.Unmarshal(data, stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Proxy.String() != "" || stored.SSRAutoPickPort || len(stored.SystemProxy.IgnoreHosts) != 0 {
+		t.Errorf("覆盖的值被保存: %s\n", data)
+	}
+	if stored.PAC.Port != "1092" || stored.PAC.Addr != "127.0.0.1" {
+		t.Errorf("修改的值没有被保存: %s\n", data)
+	}
+
+	invalid := []Overrides{
+		{"unknown": "1"},
+		{"ssr-auto-pick-port": "yes please"},
+		{"ssr-bin": "relative/path"},
+		{"ssr-elevators": "{"},
+	}
+	for _, o := range invalid {
+		u := &UserConfig{Overrides: o}
+		if err := u.applyOverrides(content); err == nil {
+			t.Errorf("%v 应该返回错误\n", o)
+		}
+	}
+}
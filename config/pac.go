@@ -0,0 +1,59 @@
+package config
+
+import (
+	"net"
+)
+
+const (
+	// 本地PAC服务默认监听的地址
+	defaultPACAddr = "127.0.0.1"
+	// 未设置用户规则文件时使用的路径
+	defaultUserRulePath = "~/.local/share/schannel-qt5-user-rules.txt"
+	// PACFileName PAC文件在本地http服务中的路径
+	PACFileName = "/proxy.pac"
+)
+
+// PACConfig 本地PAC服务的配置
+type PACConfig struct {
+	// 监听的地址和端口，端口为空时不启用(default addr: 127.0.0.1)
+	Addr string `json:"addr,omitempty"`
+	Port string `json:"port,omitempty"`
+	// GFWList格式(base64编码)的规则文件
+	GFWListPath JSONEmptyPath `json:"gfwlist_path"`
+	// 更新GFWList规则文件使用的URL，为空时不能更新
+	GFWListURL string `json:"gfwlist_url,omitempty"`
+	// 每行一个域名或CIDR的规则文件
+	RuleFiles []JSONPath `json:"rule_files,omitempty"`
+	// 用户规则文件，格式和RuleFiles相同，可以在界面中编辑
+	UserRulePath JSONEmptyPath `json:"user_rule_path"`
+}
+
+// Enabled 是否启用本地PAC服务
+func (p *PACConfig) Enabled() bool {
+	return p.Port != ""
+}
+
+// HostPort 返回PAC服务监听的host:port
+func (p *PACConfig) HostPort() string {
+	addr := p.Addr
+	if addr == "" {
+		addr = defaultPACAddr
+	}
+
+	return net.JoinHostPort(addr, p.Port)
+}
+
+// URL 返回PAC文件的URL，用于设置系统或浏览器的自动代理配置
+func (p *PACConfig) URL() string {
+	return "http://" + p.HostPort() + PACFileName
+}
+
+// UserRuleAbsPath 返回用户规则文件的绝对路径，未设置时使用默认路径
+func (p *PACConfig) UserRuleAbsPath() (string, error) {
+	if p.UserRulePath.IsEmpty() {
+		path := JSONPath{Data: defaultUserRulePath}
+		return path.AbsPath()
+	}
+
+	return p.UserRulePath.AbsPath()
+}
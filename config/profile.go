@@ -0,0 +1,304 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"schannel-qt5/atomicfile"
+)
+
+const (
+	// DefaultProfile 默认配置方案，使用schannel-qt5.json
+	DefaultProfile = "default"
+	// 其他配置方案所在的目录，与schannel-qt5.json位于同一目录下
+	profileDirName = "profiles"
+	// 记录上次选择的配置方案名称的文件，位于profileDirName中
+	activeProfileFileName = "active"
+	// 配置方案文件的扩展名
+	profileExt = ".json"
+	// CopyProfile为新方案创建的客户端配置的扩展名，方案名称中不能有"."因此不会与方案文件冲突
+	profileClientConfigExt = ".ssrclient.json"
+	// 配置方案名称的最大长度
+	maxProfileNameLen = 64
+	// 配置文件中客户端配置路径的key
+	clientConfigPathKey = "ssr_client_config_path"
+)
+
+var (
+	// ErrProfileName 配置方案名称只能包含字母、数字、"-"和"_"
+	ErrProfileName = errors.New("invalid profile name")
+	// ErrProfileExists 配置方案已经存在
+	ErrProfileExists = errors.New("profile already exists")
+	// ErrProfileNotFound 配置方案不存在
+	ErrProfileNotFound = errors.New("profile not found")
+	// ErrProfileInUse 默认方案和正在使用的方案不能被删除
+	ErrProfileInUse = errors.New("can't delete the default or active profile")
+)
+
+// 当前使用的配置方案，为空时从activeProfileFileName读取
+var activeProfile string
+
+// CheckProfileName 检查配置方案名称，名称会被用作文件名
+func CheckProfileName(name string) error {
+	if name == "" || len(name) > maxProfileNameLen {
+		return ErrProfileName
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return ErrProfileName
+		}
+	}
+
+	return nil
+}
+
+// profileDir 返回其他配置方案所在的目录
+func profileDir() (string, error) {
+	base, err := baseConfigPath()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(filepath.Dir(base), profileDirName), nil
+}
+
+// ProfilePath 返回配置方案name的配置文件路径
+// DefaultProfile为schannel-qt5.json，其他方案为同一目录下的profiles/<name>.json
+func ProfilePath(name string) (string, error) {
+	if name == DefaultProfile {
+		return baseConfigPath()
+	}
+	if err := CheckProfileName(name); err != nil {
+		return "", err
+	}
+
+	dir, err := profileDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+profileExt), nil
+}
+
+// ProfileExists 配置方案name的配置文件是否存在
+func ProfileExists(name string) (bool, error) {
+	path, err := ProfilePath(name)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Profiles 返回所有配置方案的名称，DefaultProfile在最前，其余按名称排序
+func Profiles() ([]string, error) {
+	dir, err := profileDir()
+	if err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		name := strings.TrimSuffix(info.Name(), profileExt)
+		if info.IsDir() || name == info.Name() || name == DefaultProfile || CheckProfileName(name) != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return append([]string{DefaultProfile}, names...), nil
+}
+
+// ActiveProfile 返回当前使用的配置方案，默认为上次通过SwitchProfile选择的方案
+// 记录的方案已经不存在时使用DefaultProfile
+func ActiveProfile() (string, error) {
+	if activeProfile != "" {
+		return activeProfile, nil
+	}
+
+	dir, err := profileDir()
+	if err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, activeProfileFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	activeProfile = DefaultProfile
+	if name := strings.TrimSpace(string(data)); name != "" && name != DefaultProfile {
+		if exists, _ := ProfileExists(name); exists {
+			activeProfile = name
+		}
+	}
+	return activeProfile, nil
+}
+
+// UseProfile 本次运行使用配置方案name，不会被记录
+func UseProfile(name string) error {
+	exists, err := ProfileExists(name)
+	if err != nil {
+		return err
+	} else if !exists {
+		return ErrProfileNotFound
+	}
+
+	activeProfile = name
+	return nil
+}
+
+// CreateProfile 本次运行使用新的配置方案name，配置文件由之后的StoreConfig创建
+// 方案已经存在时返回ErrProfileExists
+func CreateProfile(name string) error {
+	exists, err := ProfileExists(name)
+	if err != nil {
+		return err
+	} else if exists {
+		return ErrProfileExists
+	}
+
+	activeProfile = name
+	return nil
+}
+
+// SwitchProfile 使用配置方案name并记录，下次运行时默认使用该方案
+// 切换后需要重新调用LoadConfig
+func SwitchProfile(name string) error {
+	if err := UseProfile(name); err != nil {
+		return err
+	}
+
+	dir, err := profileDir()
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(filepath.Join(dir, activeProfileFileName), []byte(name+"\n"), 0664)
+}
+
+// CopyProfile 以配置方案from已保存的配置创建新方案name
+// 新方案使用单独的客户端配置，内容从from的客户端配置复制，其余配置项保持不变
+func CopyProfile(from, name string) error {
+	if name == DefaultProfile {
+		return ErrProfileExists
+	}
+	path, err := ProfilePath(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return ErrProfileExists
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	fromPath, err := ProfilePath(from)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(fromPath)
+	if os.IsNotExist(err) {
+		return ErrProfileNotFound
+	} else if err != nil {
+		return err
+	}
+
+	content := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &content); err != nil {
+		return err
+	}
+	// 只解析客户端配置路径，缺少这一项时AbsPath返回ErrNotAbs
+	paths := struct {
+		SSRClientConfigPath JSONPath `json:"ssr_client_config_path"`
+	}{}
+	if err := json.Unmarshal(data, &paths); err != nil {
+		return err
+	}
+	src, err := paths.SSRClientConfigPath.AbsPath()
+	if err != nil {
+		return err
+	}
+	clientData, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	dst := profileClientConfigPath(path)
+	if content[clientConfigPathKey], err = json.Marshal(dst); err != nil {
+		return err
+	}
+	if data, err = json.MarshalIndent(content, "", "\t"); err != nil {
+		return err
+	}
+
+	// 先写入客户端配置，避免方案存在而客户端配置不存在
+	if err := atomicfile.WriteFile(dst, clientData, 0664); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0664)
+}
+
+// DeleteProfile 删除配置方案name和CopyProfile为其创建的客户端配置
+func DeleteProfile(name string) error {
+	active, err := ActiveProfile()
+	if err != nil {
+		return err
+	}
+	if name == DefaultProfile || name == active {
+		return ErrProfileInUse
+	}
+
+	path, err := ProfilePath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); os.IsNotExist(err) {
+		return ErrProfileNotFound
+	} else if err != nil {
+		return err
+	}
+
+	// 客户端配置路径可能已经被修改，只删除CopyProfile创建的文件
+	err = os.Remove(profileClientConfigPath(path))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// profileClientConfigPath 返回CopyProfile为方案文件path创建的客户端配置路径
+func profileClientConfigPath(path string) string {
+	return strings.TrimSuffix(path, profileExt) + profileClientConfigExt
+}
+
+// LoadProfile 切换到配置方案name并读取其配置，读取失败时继续使用原来的方案
+func (u *UserConfig) LoadProfile(name string) error {
+	old, err := ActiveProfile()
+	if err != nil {
+		return err
+	}
+	if err := UseProfile(name); err != nil {
+		return err
+	}
+	if err := u.LoadConfig(); err != nil {
+		activeProfile = old
+		return err
+	}
+
+	return SwitchProfile(name)
+}
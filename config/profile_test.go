@@ -0,0 +1,156 @@
+package config
+
+import (
+	"testing"
+
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+func TestCheckProfileName(t *testing.T) {
+	testData := []*struct {
+		name  string
+		valid bool
+	}{
+		{"office", true},
+		{"home_2", true},
+		{"公司-wifi", true},
+		{"", false},
+		{"../office", false},
+		{"office.json", false},
+		{"a b", false},
+	}
+
+	for _, v := range testData {
+		if err := CheckProfileName(v.name); (err == nil) != v.valid {
+			t.Errorf("%q want valid: %v, have: %v\n", v.name, v.valid, err)
+		}
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schannel-qt5-profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer SetConfigPath("")
+	if err := SetConfigPath(filepath.Join(dir, configFileName)); err != nil {
+		t.Fatal(err)
+	}
+
+	clientPath := filepath.Join(dir, "ssrclient.json")
+	if err := ioutil.WriteFile(clientPath, []byte(`{"local_port": "1080"}`), 0664); err != nil {
+		t.Fatal(err)
+	}
+	content := `{"proxy_url": "http://proxy:3128", "ssr_client_config_path": "` + clientPath + `"}`
+	if err := ioutil.WriteFile(filepath.Join(dir, configFileName), []byte(content), 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	if name, err := ActiveProfile(); err != nil || name != DefaultProfile {
+		t.Errorf("默认方案错误: %s, %v\n", name, err)
+	}
+	if err := CopyProfile(DefaultProfile, "office"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyProfile(DefaultProfile, "office"); err != ErrProfileExists {
+		t.Errorf("重复创建方案应该返回ErrProfileExists: %v\n", err)
+	}
+	if err := CopyProfile("home", "office2"); err != ErrProfileNotFound {
+		t.Errorf("从不存在的方案复制应该返回ErrProfileNotFound: %v\n", err)
+	}
+	if names, err := Profiles(); err != nil || !reflect.DeepEqual(names, []string{DefaultProfile, "office"}) {
+		t.Errorf("方案列表错误: %v, %v\n", names, err)
+	}
+
+	// 新方案使用复制的客户端配置
+	officePath, _ := ProfilePath("office")
+	data, err := ioutil.ReadFile(officePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	office := make(map[string]string)
+	if err := json.Unmarshal(data, &office); err != nil {
+		t.Fatal(err)
+	}
+	officeClientPath := profileClientConfigPath(officePath)
+	if office["proxy_url"] != "http://proxy:3128" || office[clientConfigPathKey] != officeClientPath {
+		t.Errorf("复制的方案错误: %s\n", data)
+	}
+	if data, err := ioutil.ReadFile(officeClientPath); err != nil || string(data) != `{"local_port": "1080"}` {
+		t.Errorf("客户端配置没有被复制: %s, %v\n", data, err)
+	}
+
+	// 切换后的方案在重新读取时仍然生效
+	if err := SwitchProfile("office"); err != nil {
+		t.Fatal(err)
+	}
+	activeProfile = ""
+	if path, err := ConfigPath(); err != nil || path != officePath {
+		t.Errorf("切换方案后的配置文件路径错误: %s, %v\n", path, err)
+	}
+	if err := DeleteProfile("office"); err != ErrProfileInUse {
+		t.Errorf("正在使用的方案不能被删除: %v\n", err)
+	}
+	if err := SwitchProfile("home"); err != ErrProfileNotFound {
+		t.Errorf("切换到不存在的方案应该返回ErrProfileNotFound: %v\n", err)
+	}
+
+	if err := UseProfile(DefaultProfile); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteProfile("office"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(officeClientPath); !os.IsNotExist(err) {
+		t.Errorf("方案的客户端配置没有被删除: %v\n", err)
+	}
+	// 无法读取的方案不会被切换
+	brokenPath, _ := ProfilePath("broken")
+	if err := ioutil.WriteFile(brokenPath, []byte("{"), 0664); err != nil {
+		t.Fatal(err)
+	}
+	if err := new(UserConfig).LoadProfile("broken"); err == nil {
+		t.Errorf("读取错误的方案应该返回错误\n")
+	}
+	if name, _ := ActiveProfile(); name != DefaultProfile {
+		t.Errorf("读取失败后应该继续使用原来的方案: %s\n", name)
+	}
+
+	// 记录的方案被删除后使用默认方案
+	activeProfile = ""
+	if name, err := ActiveProfile(); err != nil || name != DefaultProfile {
+		t.Errorf("记录的方案不存在时应该使用默认方案: %s, %v\n", name, err)
+	}
+	// 新建的方案在保存配置前使用不存在的配置文件
+	if err := CreateProfile("broken"); err != ErrProfileExists {
+		t.Errorf("已经存在的方案不能被新建: %v\n", err)
+	}
+	if err := CreateProfile("home"); err != nil {
+		t.Fatal(err)
+	}
+	homePath, _ := ProfilePath("home")
+	if path, err := ConfigPath(); err != nil || path != homePath {
+		t.Errorf("新建方案后的配置文件路径错误: %s, %v\n", path, err)
+	}
+	if exists, err := ConfigExists(); err != nil || exists {
+		t.Errorf("新建的方案不应该有配置文件: %v, %v\n", exists, err)
+	}
+
+	// 备份无法读取的配置文件
+	if err := UseProfile("broken"); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := BackupConfig()
+	if err != nil || backup != brokenPath+".bak" {
+		t.Fatalf("备份配置文件出错: %s, %v\n", backup, err)
+	}
+	if data, err := ioutil.ReadFile(backup); err != nil || string(data) != "{" {
+		t.Errorf("备份的内容错误: %s, %v\n", data, err)
+	}
+	activeProfile = ""
+}
@@ -1,5 +1,15 @@
 package config
 
+import (
+	"errors"
+)
+
+// MaxTimeout 连接超时时间的最大值，单位为秒
+const MaxTimeout = 3600
+
+// ErrTimeout 超时时间不在1-MaxTimeout之间
+var ErrTimeout = errors.New("timeout over range")
+
 // ClientConfigGetter 获取ClientConfig
 type ClientConfigGetter interface {
 	// LocalPort 获取本地监听端口
@@ -10,6 +20,14 @@ type ClientConfigGetter interface {
 	FastOpen() bool
 	// PidFilePath pidfile存放路径
 	PidFilePath() string
+	// UDPRelay 是否开启udp转发
+	UDPRelay() bool
+	// Timeout 连接超时时间，单位为秒
+	Timeout() int
+	// LogFilePath 客户端日志文件路径，为空时不写入日志文件
+	LogFilePath() string
+	// Verbose 是否输出详细日志
+	Verbose() bool
 }
 
 // ClientConfigSetter 设置ClientConfig
@@ -22,6 +40,14 @@ type ClientConfigSetter interface {
 	SetFastOpen(isFOP bool)
 	// SetPidFilePath 设置pidfile存放路径
 	SetPidFilePath(path string) error
+	// SetUDPRelay 设置是否开启udp转发
+	SetUDPRelay(udp bool)
+	// SetTimeout 设置连接超时时间
+	SetTimeout(seconds int) error
+	// SetLogFilePath 设置客户端日志文件路径
+	SetLogFilePath(path string) error
+	// SetVerbose 设置是否输出详细日志
+	SetVerbose(verbose bool)
 }
 
 // ssr client配置接口
@@ -36,3 +62,40 @@ type ClientConfig interface {
 
 // ClientConfigMaker 产生新的config对象，所有选项使用初始默认值
 type ClientConfigMaker func() ClientConfig
+
+// 根据客户端类型生成ClientConfig，由ssr包在初始化时设置
+var clientConfigLookup func(name string) ClientConfig
+
+// SetClientConfigLookup 设置根据客户端类型生成ClientConfig的函数
+func SetClientConfigLookup(lookup func(name string) ClientConfig) {
+	clientConfigLookup = lookup
+}
+
+// newClientConfig 返回name类型客户端使用的默认ClientConfig，不存在时返回nil
+func newClientConfig(name string) ClientConfig {
+	if clientConfigLookup == nil {
+		return nil
+	}
+
+	return clientConfigLookup(name)
+}
+
+// CheckTimeout 检查连接超时时间是否在1-MaxTimeout秒之间
+func CheckTimeout(seconds int) error {
+	if seconds < 1 || seconds > MaxTimeout {
+		return ErrTimeout
+	}
+
+	return nil
+}
+
+// CheckLogFilePath 检查日志文件路径是否为绝对路径，允许为空
+func CheckLogFilePath(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	jpath := JSONPath{Data: path}
+	_, err := jpath.AbsPath()
+	return err
+}
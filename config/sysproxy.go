@@ -0,0 +1,107 @@
+package config
+
+import (
+	"errors"
+)
+
+// 设置系统代理的方式
+const (
+	// SystemProxyNone 不设置系统代理
+	SystemProxyNone = ""
+	// SystemProxyAuto 根据$XDG_CURRENT_DESKTOP选择
+	SystemProxyAuto = "auto"
+	// SystemProxyGnome 使用gsettings
+	SystemProxyGnome = "gnome"
+	// SystemProxyKDE 使用kwriteconfig5
+	SystemProxyKDE = "kde"
+	// SystemProxyEnv 写入可以被shell source的环境变量文件
+	SystemProxyEnv = "env"
+)
+
+// 系统代理使用的代理类型
+const (
+	// SystemProxySOCKS 使用ssr客户端的socks5端口
+	SystemProxySOCKS = "socks"
+	// SystemProxyHTTP 使用内置http代理
+	SystemProxyHTTP = "http"
+	// SystemProxyPAC 使用本地PAC服务
+	SystemProxyPAC = "pac"
+)
+
+// 未设置env_file时使用的路径
+const defaultProxyEnvFile = "~/.config/schannel-qt5-proxy.env"
+
+var (
+	// ErrSystemProxyBackend 不支持的系统代理设置方式
+	ErrSystemProxyBackend = errors.New("unknown system proxy backend")
+	// ErrSystemProxyMode 不支持的系统代理类型
+	ErrSystemProxyMode = errors.New("unknown system proxy mode")
+)
+
+// SystemProxyBackends 所有可用的系统代理设置方式，空字符串表示不设置
+var SystemProxyBackends = []string{
+	SystemProxyNone,
+	SystemProxyAuto,
+	SystemProxyGnome,
+	SystemProxyKDE,
+	SystemProxyEnv,
+}
+
+// SystemProxyModes 所有可用的系统代理类型
+var SystemProxyModes = []string{
+	SystemProxySOCKS,
+	SystemProxyHTTP,
+	SystemProxyPAC,
+}
+
+// SystemProxyConfig 客户端启动时设置的系统代理，客户端停止或程序退出时恢复
+type SystemProxyConfig struct {
+	// 设置方式，为空时不设置系统代理
+	Backend string `json:"backend,omitempty"`
+	// 代理类型(default: socks)
+	Mode string `json:"mode,omitempty"`
+	// 不使用代理的主机
+	IgnoreHosts []string `json:"ignore_hosts,omitempty"`
+	// env方式写入的文件(default: ~/.config/schannel-qt5-proxy.env)
+	EnvFile JSONEmptyPath `json:"env_file"`
+}
+
+// Enabled 是否设置系统代理
+func (s SystemProxyConfig) Enabled() bool {
+	return s.Backend != SystemProxyNone
+}
+
+// ProxyMode 返回代理类型，未设置时使用socks
+func (s SystemProxyConfig) ProxyMode() string {
+	if s.Mode == "" {
+		return SystemProxySOCKS
+	}
+
+	return s.Mode
+}
+
+// EnvFileAbsPath 返回env方式写入的文件路径，未设置时使用默认路径
+func (s SystemProxyConfig) EnvFileAbsPath() (string, error) {
+	if s.EnvFile.IsEmpty() {
+		path := JSONPath{Data: defaultProxyEnvFile}
+		return path.AbsPath()
+	}
+
+	return s.EnvFile.AbsPath()
+}
+
+// Valid 检查设置方式和代理类型是否支持
+func (s SystemProxyConfig) Valid() error {
+	switch s.Backend {
+	case SystemProxyNone, SystemProxyAuto, SystemProxyGnome, SystemProxyKDE, SystemProxyEnv:
+	default:
+		return ErrSystemProxyBackend
+	}
+
+	switch s.ProxyMode() {
+	case SystemProxySOCKS, SystemProxyHTTP, SystemProxyPAC:
+		return nil
+	}
+
+	return ErrSystemProxyMode
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	// 文件写入完成或者被替换时通知，编辑器通常先写入临时文件再重命名
+	watchMask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE
+	// 默认的合并间隔，间隔内对同一文件的多次修改只通知一次
+	defaultWatchDelay = 200 * time.Millisecond
+	// 未被读取的通知数量超过这个值后丢弃新的通知
+	watchBufferSize = 16
+)
+
+// FileWatcher 使用inotify监视配置文件，文件被修改后通过channel发送文件路径
+// 监视的是文件所在的目录，因此文件被删除后重新创建也能收到通知
+type FileWatcher struct {
+	// Delay 合并修改通知的间隔
+	Delay time.Duration
+
+	// inotify的fd，调用inotify.Fd()会使fd变为阻塞模式，因此单独保存
+	fd      int
+	inotify *os.File
+	events  chan string
+
+	lock sync.Mutex
+	// watch descriptor -> 目录
+	dirs map[int32]string
+	// 监视的文件路径
+	files map[string]bool
+	// 等待发送的通知
+	timers map[string]*time.Timer
+	closed bool
+}
+
+// NewFileWatcher 创建FileWatcher，需要调用Add添加监视的文件
+func NewFileWatcher() (*FileWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	w := &FileWatcher{
+		Delay:   defaultWatchDelay,
+		fd:      fd,
+		inotify: os.NewFile(uintptr(fd), "inotify"),
+		events:  make(chan string, watchBufferSize),
+		dirs:    make(map[int32]string),
+		files:   make(map[string]bool),
+		timers:  make(map[string]*time.Timer),
+	}
+	go w.read()
+
+	return w, nil
+}
+
+// Add 监视path，path需要为绝对路径，所在的目录需要存在
+func (w *FileWatcher) Add(path string) error {
+	path = filepath.Clean(path)
+	if !filepath.IsAbs(path) {
+		return ErrNotAbs
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.files[path] {
+		return nil
+	}
+	wd, err := syscall.InotifyAddWatch(w.fd, filepath.Dir(path), watchMask)
+	if err != nil {
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+	w.dirs[int32(wd)] = filepath.Dir(path)
+	w.files[path] = true
+
+	return nil
+}
+
+// Events 返回接收被修改的文件路径的channel，Close后channel被关闭
+func (w *FileWatcher) Events() <-chan string {
+	return w.events
+}
+
+// Close 停止监视并关闭channel
+func (w *FileWatcher) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	for _, timer := range w.timers {
+		timer.Stop()
+	}
+	close(w.events)
+
+	return w.inotify.Close()
+}
+
+// read 读取inotify事件直到inotify被关闭
+func (w *FileWatcher) read() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.inotify.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[nameStart:nameStart+int(event.Len)], "\x00"))
+			offset = nameStart + int(event.Len)
+
+			w.lock.Lock()
+			dir, ok := w.dirs[event.Wd]
+			w.lock.Unlock()
+			if ok && name != "" {
+				w.changed(filepath.Join(dir, name))
+			}
+		}
+	}
+}
+
+// changed path被修改，Delay之后没有新的修改时发送通知
+func (w *FileWatcher) changed(path string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.closed || !w.files[path] {
+		return
+	}
+	if timer, ok := w.timers[path]; ok {
+		timer.Reset(w.Delay)
+		return
+	}
+	w.timers[path] = time.AfterFunc(w.Delay, func() {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+
+		delete(w.timers, path)
+		if w.closed {
+			return
+		}
+		select {
+		case w.events <- path:
+		default:
+		}
+	})
+}
+
+// WatchedFiles 返回需要监视的配置文件、客户端配置和节点配置的路径
+func (u *UserConfig) WatchedFiles() ([]string, error) {
+	configPath, err := ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	clientConfigPath, err := u.SSRClientConfigPath.AbsPath()
+	if err != nil {
+		return nil, err
+	}
+	nodeConfigPath, err := u.SSRNodeConfigPath.AbsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{configPath, clientConfigPath, nodeConfigPath}, nil
+}
+
+// SameAs 两个配置保存后的内容是否相同，用于忽略StoreConfig自身引起的文件修改
+func (u *UserConfig) SameAs(other *UserConfig) bool {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return false
+	}
+	otherData, err := json.Marshal(other)
+	if err != nil || !bytes.Equal(data, otherData) {
+		return false
+	}
+	if u.SSRClientConfig == nil || other.SSRClientConfig == nil {
+		return u.SSRClientConfig == other.SSRClientConfig
+	}
+
+	clientData, err := json.Marshal(u.SSRClientConfig)
+	if err != nil {
+		return false
+	}
+	otherClientData, err := json.Marshal(other.SSRClientConfig)
+	return err == nil && bytes.Equal(clientData, otherClientData)
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"testing"
+
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// waitEvent 等待watcher发送通知，超时返回空字符串
+func waitEvent(w *FileWatcher, timeout time.Duration) string {
+	select {
+	case path := <-w.Events():
+		return path
+	case <-time.After(timeout):
+		return ""
+	}
+}
+
+func TestFileWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schannel-qt5-watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := NewFileWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Delay = 10 * time.Millisecond
+
+	path := filepath.Join(dir, "ssrclient.json")
+	if err := w.Add("ssrclient.json"); err != ErrNotAbs {
+		t.Errorf("相对路径应该返回错误: %v\n", err)
+	}
+	if err := w.Add(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有监视的文件不会发送通知
+	if err := ioutil.WriteFile(filepath.Join(dir, "other.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if changed := waitEvent(w, 100*time.Millisecond); changed != "" {
+		t.Errorf("收到了没有监视的文件的通知: %s\n", changed)
+	}
+
+	// 多次写入只通知一次
+	for i := 0; i < 3; i++ {
+		if err := ioutil.WriteFile(path, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if changed := waitEvent(w, time.Second); changed != path {
+		t.Errorf("写入文件后没有收到通知: %s\n", changed)
+	}
+	if changed := waitEvent(w, 100*time.Millisecond); changed != "" {
+		t.Errorf("重复的通知: %s\n", changed)
+	}
+
+	// 编辑器使用临时文件替换原文件
+	tmp := filepath.Join(dir, "ssrclient.json.tmp")
+	if err := ioutil.WriteFile(tmp, []byte(`{"local_port":"1081"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	if changed := waitEvent(w, time.Second); changed != path {
+		t.Errorf("替换文件后没有收到通知: %s\n", changed)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+	if _, ok := <-w.Events(); ok {
+		t.Errorf("Close后channel没有被关闭\n")
+	}
+}
+
+func TestUserConfigSameAs(t *testing.T) {
+	u := new(UserConfig)
+	u.SSRNodeConfigPath.Data = "/tmp/node.json"
+	u.SSRClientConfigPath.Data = "/tmp/client.json"
+	u.SSRBin.Data = "/tmp/a.out"
+
+	other := *u
+	if !u.SameAs(&other) {
+		t.Errorf("相同的配置被判断为不同\n")
+	}
+	other.HTTPProxyPort = "8118"
+	if u.SameAs(&other) {
+		t.Errorf("不同的配置被判断为相同\n")
+	}
+}
@@ -0,0 +1,21 @@
+package credential
+
+import (
+	"errors"
+)
+
+// 保存密码时使用的应用名称，用于区分其他程序保存的密码
+const applicationName = "schannel-qt5"
+
+var (
+	// ErrNotFound 没有保存该账号的密码
+	ErrNotFound = errors.New("no password stored for the user")
+	// ErrMasterPassword 主密码错误或文件被修改
+	ErrMasterPassword = errors.New("wrong master password or corrupted credential file")
+	// ErrEmptyMasterPassword 主密码为空
+	ErrEmptyMasterPassword = errors.New("master password can't be empty")
+	// ErrFileVersion 不支持的加密文件版本
+	ErrFileVersion = errors.New("unsupported credential file version")
+	// ErrDismissed 用户取消了Secret Service的解锁提示
+	ErrDismissed = errors.New("secret service prompt dismissed")
+)
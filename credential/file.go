@@ -0,0 +1,205 @@
+package credential
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+
+	"schannel-qt5/atomicfile"
+	"schannel-qt5/xdg"
+)
+
+const (
+	// 加密文件名，位于$XDG_DATA_HOME/schannel-qt5下
+	credentialFileName = "credentials.json"
+	// 当前的加密文件版本
+	fileVersion = 1
+	// 附加在密文上的验证数据，与salt一起防止文件头被修改
+	fileAAD = "schannel-qt5 credentials"
+)
+
+// scrypt参数，生成AES-256的key
+const (
+	scryptN   = 1 << 15
+	scryptR   = 8
+	scryptP   = 1
+	saltSize  = 16
+	keyLength = 32
+)
+
+// fileContent 加密文件的内容，[]byte字段以base64保存
+type fileContent struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// DefaultFilePath 返回加密文件的默认路径
+func DefaultFilePath() (string, error) {
+	return xdg.DataFile(credentialFileName)
+}
+
+// FileStore 将所有账号的密码用主密码加密后保存在一个文件中
+// 每次写入都会使用新的随机nonce
+type FileStore struct {
+	path string
+	salt []byte
+	aead cipher.AEAD
+
+	lock      sync.Mutex
+	passwords map[string]string
+}
+
+// OpenFile 使用masterPassword打开path处的加密文件
+// 文件不存在时使用masterPassword创建新的存储，第一次保存密码时才写入文件
+func OpenFile(path, masterPassword string) (*FileStore, error) {
+	if masterPassword == "" {
+		return nil, ErrEmptyMasterPassword
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		return newFileStore(path, masterPassword, salt)
+	} else if err != nil {
+		return nil, err
+	}
+
+	content := &fileContent{}
+	if err := json.Unmarshal(data, content); err != nil {
+		return nil, err
+	}
+	if content.Version != fileVersion {
+		return nil, ErrFileVersion
+	}
+	store, err := newFileStore(path, masterPassword, content.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(content.Nonce) != store.aead.NonceSize() {
+		return nil, ErrMasterPassword
+	}
+	plain, err := store.aead.Open(nil, content.Nonce, content.Data, store.additionalData())
+	if err != nil {
+		return nil, ErrMasterPassword
+	}
+	if err := json.Unmarshal(plain, &store.passwords); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// newFileStore 根据masterPassword和salt生成key
+func newFileStore(path, masterPassword string, salt []byte) (*FileStore, error) {
+	key, err := scrypt.Key([]byte(masterPassword), salt, scryptN, scryptR, scryptP, keyLength)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &FileStore{
+		path:      path,
+		salt:      salt,
+		aead:      aead,
+		passwords: make(map[string]string),
+	}
+	return store, nil
+}
+
+// additionalData 返回加密时附加的验证数据
+func (f *FileStore) additionalData() []byte {
+	return append([]byte(fileAAD), f.salt...)
+}
+
+// Get 返回user的密码，没有保存时返回ErrNotFound
+func (f *FileStore) Get(user string) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	password, ok := f.passwords[user]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return password, nil
+}
+
+// Set 保存user的密码并写入文件
+func (f *FileStore) Set(user, password string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if old, ok := f.passwords[user]; ok && old == password {
+		return nil
+	}
+	passwords := make(map[string]string, len(f.passwords)+1)
+	for k, v := range f.passwords {
+		passwords[k] = v
+	}
+	passwords[user] = password
+	return f.store(passwords)
+}
+
+// Delete 删除user的密码，没有保存时不做任何操作
+func (f *FileStore) Delete(user string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if _, ok := f.passwords[user]; !ok {
+		return nil
+	}
+	passwords := make(map[string]string, len(f.passwords))
+	for k, v := range f.passwords {
+		if k != user {
+			passwords[k] = v
+		}
+	}
+	return f.store(passwords)
+}
+
+// store 加密passwords并写入文件，成功后替换内存中的密码
+func (f *FileStore) store(passwords map[string]string) error {
+	plain, err := json.Marshal(passwords)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, f.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	content := &fileContent{
+		Version: fileVersion,
+		Salt:    f.salt,
+		Nonce:   nonce,
+		Data:    f.aead.Seal(nil, nonce, plain, f.additionalData()),
+	}
+	data, err := json.MarshalIndent(content, "", "\t")
+	if err != nil {
+		return err
+	}
+	// 只有当前用户可读写
+	if err := atomicfile.WriteFile(f.path, data, 0600); err != nil {
+		return err
+	}
+
+	f.passwords = passwords
+	return nil
+}
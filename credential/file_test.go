@@ -0,0 +1,77 @@
+package credential
+
+import (
+	"testing"
+
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schannel-qt5-credential")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "credentials.json")
+
+	if _, err := OpenFile(path, ""); err != ErrEmptyMasterPassword {
+		t.Errorf("空主密码应该返回ErrEmptyMasterPassword: %v\n", err)
+	}
+	store, err := OpenFile(path, "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("a"); err != ErrNotFound {
+		t.Errorf("没有保存的密码应该返回ErrNotFound: %v\n", err)
+	}
+	if err := store.Set("a", "password-a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("b", "password-b"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("password-a")) {
+		t.Errorf("文件中含有明文: %s\n", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("文件权限错误: %v\n", info.Mode())
+	}
+
+	// 重新打开后可以读取，错误的主密码无法打开
+	store, err = OpenFile(path, "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password, err := store.Get("a"); err != nil || password != "password-a" {
+		t.Errorf("want: password-a, have: %s, %v\n", password, err)
+	}
+	if _, err := OpenFile(path, "wrong"); err != ErrMasterPassword {
+		t.Errorf("错误的主密码应该返回ErrMasterPassword: %v\n", err)
+	}
+
+	// 每次写入使用新的nonce
+	if err := store.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("b"); err != nil {
+		t.Errorf("删除不存在的密码不应该返回错误: %v\n", err)
+	}
+	newData, _ := ioutil.ReadFile(path)
+	if bytes.Equal(data, newData) {
+		t.Errorf("删除后文件没有变化\n")
+	}
+	store, err = OpenFile(path, "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("b"); err != ErrNotFound {
+		t.Errorf("被删除的密码仍然存在: %v\n", err)
+	}
+}
@@ -0,0 +1,202 @@
+package credential
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+// freedesktop Secret Service的D-Bus名称和接口
+const (
+	secretsService      = "org.freedesktop.secrets"
+	secretsPath         = dbus.ObjectPath("/org/freedesktop/secrets")
+	defaultCollection   = dbus.ObjectPath("/org/freedesktop/secrets/aliases/default")
+	serviceInterface    = "org.freedesktop.Secret.Service"
+	sessionInterface    = "org.freedesktop.Secret.Session"
+	collectionInterface = "org.freedesktop.Secret.Collection"
+	itemInterface       = "org.freedesktop.Secret.Item"
+	promptInterface     = "org.freedesktop.Secret.Prompt"
+	// 不需要显示提示时返回的prompt路径
+	noPrompt = dbus.ObjectPath("/")
+)
+
+// secret Secret Service中的密码结构，对应D-Bus签名(oayays)
+type secret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// SecretService 使用freedesktop Secret Service(gnome-keyring、KWallet等)保存密码
+// 密码保存在默认collection中，通过application和user属性查找
+type SecretService struct {
+	conn    *dbus.Conn
+	session dbus.ObjectPath
+}
+
+// NewSecretService 连接session bus并打开Secret Service会话
+// 服务不可用时返回错误
+func NewSecretService() (*SecretService, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, err
+	}
+
+	// 会话只在session bus上传输，使用plain算法
+	var output dbus.Variant
+	var session dbus.ObjectPath
+	err = conn.Object(secretsService, secretsPath).
+		Call(serviceInterface+".OpenSession", 0, "plain", dbus.MakeVariant("")).
+		Store(&output, &session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SecretService{conn: conn, session: session}, nil
+}
+
+// Close 关闭Secret Service会话
+func (s *SecretService) Close() error {
+	return s.conn.Object(secretsService, s.session).Call(sessionInterface+".Close", 0).Err
+}
+
+// attributes 返回user的密码项的查找属性
+func attributes(user string) map[string]string {
+	return map[string]string{
+		"application": applicationName,
+		"user":        user,
+	}
+}
+
+// search 查找user的密码项，被锁定的项会先解锁
+func (s *SecretService) search(user string) ([]dbus.ObjectPath, error) {
+	var unlocked, locked []dbus.ObjectPath
+	err := s.conn.Object(secretsService, secretsPath).
+		Call(serviceInterface+".SearchItems", 0, attributes(user)).
+		Store(&unlocked, &locked)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(locked) != 0 {
+		if err := s.unlock(locked); err != nil {
+			return nil, err
+		}
+		unlocked = append(unlocked, locked...)
+	}
+	return unlocked, nil
+}
+
+// unlock 解锁objects，需要时由Secret Service提示用户输入密码
+func (s *SecretService) unlock(objects []dbus.ObjectPath) error {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	err := s.conn.Object(secretsService, secretsPath).
+		Call(serviceInterface+".Unlock", 0, objects).
+		Store(&unlocked, &prompt)
+	if err != nil {
+		return err
+	}
+
+	return s.prompt(prompt)
+}
+
+// prompt 显示path对应的提示并等待用户完成，path为"/"时不需要提示
+func (s *SecretService) prompt(path dbus.ObjectPath) error {
+	if path == noPrompt || path == "" {
+		return nil
+	}
+
+	match := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface(promptInterface),
+		dbus.WithMatchMember("Completed"),
+	}
+	if err := s.conn.AddMatchSignal(match...); err != nil {
+		return err
+	}
+	defer s.conn.RemoveMatchSignal(match...)
+	signals := make(chan *dbus.Signal, 8)
+	s.conn.Signal(signals)
+	defer s.conn.RemoveSignal(signals)
+
+	if err := s.conn.Object(secretsService, path).Call(promptInterface+".Prompt", 0, "").Err; err != nil {
+		return err
+	}
+	for signal := range signals {
+		if signal.Path != path || signal.Name != promptInterface+".Completed" || len(signal.Body) == 0 {
+			continue
+		}
+		if dismissed, _ := signal.Body[0].(bool); dismissed {
+			return ErrDismissed
+		}
+		return nil
+	}
+
+	return ErrDismissed
+}
+
+// Get 返回user的密码，没有保存时返回ErrNotFound
+func (s *SecretService) Get(user string) (string, error) {
+	items, err := s.search(user)
+	if err != nil {
+		return "", err
+	}
+	if len(items) == 0 {
+		return "", ErrNotFound
+	}
+
+	var value secret
+	err = s.conn.Object(secretsService, items[0]).
+		Call(itemInterface+".GetSecret", 0, s.session).
+		Store(&value)
+	if err != nil {
+		return "", err
+	}
+	return string(value.Value), nil
+}
+
+// Set 在默认collection中保存user的密码，已有的密码项会被替换
+func (s *SecretService) Set(user, password string) error {
+	if err := s.unlock([]dbus.ObjectPath{defaultCollection}); err != nil {
+		return err
+	}
+
+	properties := map[string]dbus.Variant{
+		itemInterface + ".Label":      dbus.MakeVariant(applicationName + ": " + user),
+		itemInterface + ".Attributes": dbus.MakeVariant(attributes(user)),
+	}
+	value := secret{
+		Session:     s.session,
+		Parameters:  []byte{},
+		Value:       []byte(password),
+		ContentType: "text/plain; charset=utf8",
+	}
+	var item, prompt dbus.ObjectPath
+	err := s.conn.Object(secretsService, defaultCollection).
+		Call(collectionInterface+".CreateItem", 0, properties, value, true).
+		Store(&item, &prompt)
+	if err != nil {
+		return err
+	}
+
+	return s.prompt(prompt)
+}
+
+// Delete 删除user的所有密码项，没有保存时不做任何操作
+func (s *SecretService) Delete(user string) error {
+	items, err := s.search(user)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		var prompt dbus.ObjectPath
+		if err := s.conn.Object(secretsService, item).Call(itemInterface+".Delete", 0).Store(&prompt); err != nil {
+			return err
+		}
+		if err := s.prompt(prompt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,7 +1,6 @@
 package geoip
 
 import (
-	"errors"
 	"net"
 	"os"
 	"path/filepath"
@@ -9,25 +8,37 @@ import (
 	"time"
 
 	"github.com/oschwald/geoip2-golang"
+
+	"schannel-qt5/xdg"
 )
 
 const (
-	// GeoIP Database的存放路径
-	geoIPSavePath = ".local/share/data/schannel-qt5/GeoIP"
+	// GeoIP Database的存放目录，位于$XDG_CACHE_HOME/schannel-qt5下
+	geoIPDirName = "GeoIP"
+	// 旧版本使用的存放目录，相对于$HOME
+	legacyGeoIPSavePath = ".local/share/data/schannel-qt5/GeoIP"
 	// GeoIP Database下载地址
 	DownloadPath = "https://geolite.maxmind.com/download/geoip/database/GeoLite2-City.mmdb.gz"
 	// 数据库文件名
 	DatabaseName = "GeoLite2-City.mmdb"
 )
 
-// GetGeoIPSavePath 返回完整的数据库路径，默认在$HOME/.local/share/data/schannel-qt5/GeoIP下
+// GetGeoIPSavePath 返回数据库的存放目录$XDG_CACHE_HOME/schannel-qt5/GeoIP
+// 旧位置的目录存在时会先被移动到新位置
 func GetGeoIPSavePath() (string, error) {
-	home, err := os.UserHomeDir()
+	path, err := xdg.CacheFile(geoIPDirName)
+	if err != nil {
+		return "", err
+	}
+	legacy, err := xdg.HomeFile(legacyGeoIPSavePath)
 	if err != nil {
-		return "", errors.New("cannot find user home dir")
+		return "", err
+	}
+	if _, err := xdg.Migrate(legacy, path); err != nil {
+		return "", err
 	}
 
-	return filepath.Join(home, geoIPSavePath), nil
+	return path, nil
 }
 
 // getRecord 根据ip返回geoIP查询结果
@@ -0,0 +1,198 @@
+package goclient
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"errors"
+	"io"
+	"net"
+
+	"golang.org/x/crypto/chacha20"
+)
+
+var (
+	// ErrCipherNotSupported 不支持的加密算法
+	ErrCipherNotSupported = errors.New("cipher not supported")
+)
+
+// cipherInfo 流加密算法的key和iv长度，以及生成cipher.Stream的方法
+type cipherInfo struct {
+	keyLen    int
+	ivLen     int
+	newStream func(key, iv []byte, decrypt bool) (cipher.Stream, error)
+}
+
+// 支持的加密算法
+var ciphers = map[string]*cipherInfo{
+	"aes-128-cfb":   {16, 16, newAESCFBStream},
+	"aes-192-cfb":   {24, 16, newAESCFBStream},
+	"aes-256-cfb":   {32, 16, newAESCFBStream},
+	"aes-128-ctr":   {16, 16, newAESCTRStream},
+	"aes-192-ctr":   {24, 16, newAESCTRStream},
+	"aes-256-ctr":   {32, 16, newAESCTRStream},
+	"chacha20":      {32, 8, newChaCha20Stream},
+	"chacha20-ietf": {32, 12, newChaCha20Stream},
+	"rc4-md5":       {16, 16, newRC4MD5Stream},
+	"none":          {16, 0, newNoneStream},
+}
+
+// Ciphers 返回所有支持的加密算法名称
+func Ciphers() []string {
+	names := make([]string, 0, len(ciphers))
+	for name := range ciphers {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func newAESCFBStream(key, iv []byte, decrypt bool) (cipher.Stream, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if decrypt {
+		return cipher.NewCFBDecrypter(block, iv), nil
+	}
+	return cipher.NewCFBEncrypter(block, iv), nil
+}
+
+func newAESCTRStream(key, iv []byte, _ bool) (cipher.Stream, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewCTR(block, iv), nil
+}
+
+// newChaCha20Stream 同时支持8字节nonce的原始chacha20和12字节nonce的chacha20-ietf
+// 原始chacha20的计数器为64位，将高32位填充为0即可转换为ietf格式的nonce
+func newChaCha20Stream(key, iv []byte, _ bool) (cipher.Stream, error) {
+	nonce := iv
+	if len(iv) == 8 {
+		nonce = make([]byte, chacha20.NonceSize)
+		copy(nonce[4:], iv)
+	}
+
+	return chacha20.NewUnauthenticatedCipher(key, nonce)
+}
+
+func newRC4MD5Stream(key, iv []byte, _ bool) (cipher.Stream, error) {
+	h := md5.New()
+	h.Write(key)
+	h.Write(iv)
+
+	return rc4.NewCipher(h.Sum(nil))
+}
+
+// noneStream 不进行加密
+type noneStream struct{}
+
+func (noneStream) XORKeyStream(dst, src []byte) {
+	copy(dst, src)
+}
+
+func newNoneStream(_, _ []byte, _ bool) (cipher.Stream, error) {
+	return noneStream{}, nil
+}
+
+// evpBytesToKey 与OpenSSL的EVP_BytesToKey(md5, 无salt, 迭代1次)相同，根据密码生成key
+func evpBytesToKey(password string, keyLen int) []byte {
+	var prev []byte
+	key := make([]byte, 0, keyLen+md5.Size)
+	for len(key) < keyLen {
+		h := md5.New()
+		h.Write(prev)
+		h.Write([]byte(password))
+		prev = h.Sum(nil)
+		key = append(key, prev...)
+	}
+
+	return key[:keyLen]
+}
+
+// streamCipher 一个连接使用的加密参数
+type streamCipher struct {
+	info *cipherInfo
+	key  []byte
+	// 发送数据时使用的iv，连接建立时随机生成
+	iv []byte
+}
+
+// newStreamCipher 根据加密算法名和密码生成streamCipher
+func newStreamCipher(method, password string) (*streamCipher, error) {
+	info, ok := ciphers[method]
+	if !ok {
+		return nil, ErrCipherNotSupported
+	}
+
+	c := &streamCipher{
+		info: info,
+		key:  evpBytesToKey(password, info.keyLen),
+		iv:   make([]byte, info.ivLen),
+	}
+	if _, err := io.ReadFull(rand.Reader, c.iv); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// cipherConn 加密/解密经过的数据
+// 发送的数据以iv开头，接收的数据同样以服务端的iv开头
+type cipherConn struct {
+	net.Conn
+	cipher *streamCipher
+
+	enc    cipher.Stream
+	dec    cipher.Stream
+	ivSent bool
+}
+
+func newCipherConn(conn net.Conn, c *streamCipher) (*cipherConn, error) {
+	enc, err := c.info.newStream(c.key, c.iv, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cipherConn{Conn: conn, cipher: c, enc: enc}, nil
+}
+
+func (c *cipherConn) Write(b []byte) (int, error) {
+	data := make([]byte, len(b))
+	c.enc.XORKeyStream(data, b)
+	if !c.ivSent {
+		data = append(append([]byte{}, c.cipher.iv...), data...)
+		c.ivSent = true
+	}
+
+	if _, err := c.Conn.Write(data); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+func (c *cipherConn) Read(b []byte) (int, error) {
+	if c.dec == nil {
+		iv := make([]byte, c.cipher.info.ivLen)
+		if _, err := io.ReadFull(c.Conn, iv); err != nil {
+			return 0, err
+		}
+
+		dec, err := c.cipher.info.newStream(c.cipher.key, iv, true)
+		if err != nil {
+			return 0, err
+		}
+		c.dec = dec
+	}
+
+	n, err := c.Conn.Read(b)
+	c.dec.XORKeyStream(b[:n], b[:n])
+	return n, err
+}
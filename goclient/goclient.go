@@ -0,0 +1,120 @@
+package goclient
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"schannel-qt5/config"
+	"schannel-qt5/parser"
+	"schannel-qt5/ssr"
+)
+
+var (
+	// ErrRunning 客户端已经在运行
+	ErrRunning = errors.New("client is already running")
+	// ErrNotRunning 客户端未运行
+	ErrNotRunning = errors.New("client is not running")
+)
+
+// GoSSRClient 在本进程中运行的ssr客户端，不需要外部程序和root权限
+type GoSSRClient struct {
+	// 节点配置文件路径
+	nodeConfigPath string
+	// 程序需要的配置
+	conf config.ClientConfig
+
+	lock   sync.Mutex
+	server *socksServer
+}
+
+func init() {
+	// 注册为可用的Launcher，name为go
+	ssr.SetLuancherMaker("go", ssr.LauncherMaker(newGoSSRClient))
+	// 客户端运行在程序内部，不需要外部程序
+	ssr.SetBinRequirement("go", ssr.BinRequirement{})
+}
+
+// newGoSSRClient 这个函数供ssr.LauncherMaker调用，用于生成ssr.Launcher
+func newGoSSRClient(c *config.UserConfig) ssr.Launcher {
+	nodeConfigPath, err := c.SSRNodeConfigPath.AbsPath()
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	return &GoSSRClient{
+		nodeConfigPath: nodeConfigPath,
+		conf:           c.SSRClientConfig,
+	}
+}
+
+// Start 启动客户端
+// 每次启动时重新读取节点配置
+func (g *GoSSRClient) Start() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.server != nil {
+		return ErrRunning
+	}
+
+	node := &parser.SSRNode{}
+	if err := node.Load(g.nodeConfigPath); err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(g.conf.LocalAddr(), g.conf.LocalPort())
+	server, err := newSocksServer(addr, node)
+	if err != nil {
+		return err
+	}
+	server.timeout = time.Duration(g.conf.Timeout()) * time.Second
+	server.verbose = g.conf.Verbose()
+	g.server = server
+	go g.server.serve()
+
+	return nil
+}
+
+// Restart 重新启动客户端
+func (g *GoSSRClient) Restart() error {
+	if err := g.Stop(); err != nil && err != ErrNotRunning {
+		return err
+	}
+
+	return g.Start()
+}
+
+// Stop 停止客户端，关闭所有正在转发的连接
+func (g *GoSSRClient) Stop() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.server == nil {
+		return ErrNotRunning
+	}
+
+	err := g.server.close()
+	g.server = nil
+	return err
+}
+
+// IsRunning 客户端正在运行返回nil
+func (g *GoSSRClient) IsRunning() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.server == nil {
+		return ErrNotRunning
+	}
+
+	return nil
+}
+
+// ConnectionCheck 检查代理是否可用，不可用则返回error
+func (g *GoSSRClient) ConnectionCheck(timeout time.Duration) error {
+	return ssr.CheckProxy(g.conf.LocalAddr(), g.conf.LocalPort(), timeout)
+}
@@ -0,0 +1,193 @@
+package goclient
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"schannel-qt5/atomicfile"
+	"schannel-qt5/config"
+	"schannel-qt5/ssr"
+)
+
+var (
+	defaultPort    = "1080"
+	defaultAddr    = "127.0.0.1"
+	defaultTimeout = 60
+)
+
+// ClientConfig 进程内ssr客户端的本地配置
+type ClientConfig struct {
+	// 本地端口和ip(default: 127.0.0.1:1080)
+	Addr string `json:"local_addr,omitempty"`
+	Port string `json:"local_port,omitempty"`
+
+	// fast-open 需要linux 3.7+(default: false)
+	IsFastOpen bool `json:"fast-open,omitempty"`
+
+	// 客户端运行在本进程中，不会生成pidfile，保留此项以便切换客户端时迁移设置
+	PidFile string `json:"pid-file,omitempty"`
+
+	// 连接空闲超过此时间后关闭，单位为秒(default: 60)
+	TimeoutSeconds int `json:"timeout,omitempty"`
+	// 输出每个连接的日志(default: false)
+	IsVerbose bool `json:"verbose,omitempty"`
+
+	// 不支持udp转发，日志写入程序日志
+	// 保留这几项以便切换客户端时迁移设置
+	IsUDPRelay bool   `json:"udp-relay,omitempty"`
+	LogFile    string `json:"log-file,omitempty"`
+}
+
+func init() {
+	// 注册到config生成器
+	ssr.SetClientConfigMaker("go", config.ClientConfigMaker(newClientConfig))
+}
+
+// newClientConfig 生成config对象
+func newClientConfig() config.ClientConfig {
+	return &ClientConfig{}
+}
+
+// 实现ClientConfigGetter
+func (c *ClientConfig) LocalPort() string {
+	if c.Port == "" {
+		return defaultPort
+	}
+
+	return c.Port
+}
+
+func (c *ClientConfig) LocalAddr() string {
+	if c.Addr == "" {
+		return defaultAddr
+	}
+
+	return c.Addr
+}
+
+func (c *ClientConfig) FastOpen() bool {
+	return c.IsFastOpen
+}
+
+func (c *ClientConfig) PidFilePath() string {
+	return c.PidFile
+}
+
+func (c *ClientConfig) UDPRelay() bool {
+	return c.IsUDPRelay
+}
+
+func (c *ClientConfig) Timeout() int {
+	if c.TimeoutSeconds == 0 {
+		return defaultTimeout
+	}
+
+	return c.TimeoutSeconds
+}
+
+func (c *ClientConfig) LogFilePath() string {
+	return c.LogFile
+}
+
+func (c *ClientConfig) Verbose() bool {
+	return c.IsVerbose
+}
+
+func (c *ClientConfig) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, c)
+}
+
+func (c *ClientConfig) Store(path string) error {
+	// 格式化成易于阅读的形式
+	data, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(path, data, 0664)
+}
+
+// 实现ClientConfigSetter
+// SetLocalPort 设置本地端口，端口不能大于65535且不能为0
+func (c *ClientConfig) SetLocalPort(port string) error {
+	i, err := strconv.Atoi(port)
+	if err != nil {
+		return err
+	} else if i > 65535 || i <= 0 {
+		return errors.New("port over range")
+	}
+
+	c.Port = port
+	return nil
+}
+
+func (c *ClientConfig) SetFastOpen(isFOP bool) {
+	c.IsFastOpen = isFOP
+}
+
+// SetPidFilePath 设置pidfile存放路径，需要为绝对路径，允许为空
+func (c *ClientConfig) SetPidFilePath(path string) error {
+	if path != "" {
+		jpath := config.JSONPath{Data: path}
+		if _, err := jpath.AbsPath(); err != nil {
+			return err
+		}
+	}
+
+	c.PidFile = path
+	return nil
+}
+
+// SetLocalAddr 检查并设置要bind的本地地址
+// 可以是IPv4、IPv6(包括"[::1]"的形式)或者主机名
+func (c *ClientConfig) SetLocalAddr(addr string) error {
+	addr, err := config.ParseBindAddr(addr)
+	if err != nil {
+		return err
+	}
+
+	c.Addr = addr
+	return nil
+}
+
+func (c *ClientConfig) SetUDPRelay(udp bool) {
+	c.IsUDPRelay = udp
+}
+
+// SetTimeout 设置连接空闲超时时间，范围为1-3600秒
+func (c *ClientConfig) SetTimeout(seconds int) error {
+	if err := config.CheckTimeout(seconds); err != nil {
+		return err
+	}
+
+	c.TimeoutSeconds = seconds
+	return nil
+}
+
+// SetLogFilePath 设置日志文件路径，需要为绝对路径，允许为空
+func (c *ClientConfig) SetLogFilePath(path string) error {
+	if err := config.CheckLogFilePath(path); err != nil {
+		return err
+	}
+
+	c.LogFile = path
+	return nil
+}
+
+func (c *ClientConfig) SetVerbose(verbose bool) {
+	c.IsVerbose = verbose
+}
@@ -0,0 +1,293 @@
+package goclient
+
+import (
+	"testing"
+
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"schannel-qt5/parser"
+)
+
+func TestEVPBytesToKey(t *testing.T) {
+	testData := []struct {
+		password string
+		keyLen   int
+		res      string
+	}{
+		{
+			password: "foobar",
+			keyLen:   32,
+			res:      "3858f62230ac3c915f300c664312c63f568378529614d22ddb49237d2f60bfdf",
+		},
+		{
+			password: "test",
+			keyLen:   16,
+			res:      "098f6bcd4621d373cade4e832627b4f6",
+		},
+	}
+
+	for _, v := range testData {
+		key := hex.EncodeToString(evpBytesToKey(v.password, v.keyLen))
+		if key != v.res {
+			t.Errorf("wrong key for %s:\n\twant: %s\n\thave: %s\n", v.password, v.res, key)
+		}
+	}
+}
+
+func TestCipherConn(t *testing.T) {
+	data := []byte("schannel-qt5 cipher test data")
+	for method := range ciphers {
+		left, right := net.Pipe()
+		c1, err := newStreamCipher(method, "password")
+		if err != nil {
+			t.Fatalf("%s: %v\n", method, err)
+		}
+		c2, _ := newStreamCipher(method, "password")
+		conn1, err := newCipherConn(left, c1)
+		if err != nil {
+			t.Fatalf("%s: %v\n", method, err)
+		}
+		conn2, _ := newCipherConn(right, c2)
+
+		go conn1.Write(data)
+		buf := make([]byte, len(data))
+		if _, err := io.ReadFull(conn2, buf); err != nil {
+			t.Errorf("%s read failed: %v\n", method, err)
+		} else if !bytes.Equal(buf, data) {
+			t.Errorf("%s decrypt wrong: %s\n", method, buf)
+		}
+		left.Close()
+		right.Close()
+	}
+
+	if _, err := newStreamCipher("unknown", "password"); err != ErrCipherNotSupported {
+		t.Errorf("unknown cipher didn't fail\n")
+	}
+}
+
+func TestAuthAES128Unpack(t *testing.T) {
+	info := &serverInfo{
+		key:  evpBytesToKey("password", 16),
+		iv:   randBytes(16),
+		auth: &authData{},
+	}
+	left, right := net.Pipe()
+	defer left.Close()
+	defer right.Close()
+
+	// 服务端发送的数据包格式与客户端的普通数据包相同
+	server := newAuthAES128Conn(nil, info, md5.New, "auth_aes128_md5")
+	client := newAuthAES128Conn(right, info, md5.New, "auth_aes128_md5")
+
+	testData := [][]byte{
+		[]byte("hello"),
+		bytes.Repeat([]byte("a"), 1000),
+		bytes.Repeat([]byte("b"), 5000),
+	}
+	go func() {
+		for _, v := range testData {
+			left.Write(server.packData(v))
+		}
+	}()
+
+	for _, v := range testData {
+		buf := make([]byte, len(v))
+		if _, err := io.ReadFull(client, buf); err != nil {
+			t.Fatalf("read failed: %v\n", err)
+		}
+		if !bytes.Equal(buf, v) {
+			t.Errorf("unpack wrong data, length: %d\n", len(v))
+		}
+	}
+}
+
+// echoSSRServer 模拟origin协议和plain混淆的ssr服务器，将收到的数据原样返回
+func echoSSRServer(t *testing.T, method, password string) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			raw, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer raw.Close()
+				c, _ := newStreamCipher(method, password)
+				conn, _ := newCipherConn(raw, c)
+				// 读取目标地址：atyp + len + domain + port
+				head := make([]byte, 2)
+				if _, err := io.ReadFull(conn, head); err != nil {
+					return
+				}
+				rest := make([]byte, int(head[1])+2)
+				if _, err := io.ReadFull(conn, rest); err != nil {
+					return
+				}
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return listener
+}
+
+func TestSocksServer(t *testing.T) {
+	listener := echoSSRServer(t, "aes-256-cfb", "password")
+	defer listener.Close()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	p, _ := strconv.ParseInt(port, 10, 64)
+	node := &parser.SSRNode{
+		IP:     host,
+		Port:   p,
+		Passwd: "password",
+		Crypto: "aes-256-cfb",
+		Proto:  "origin",
+		Minx:   "plain",
+	}
+	server, err := newSocksServer("127.0.0.1:0", node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.serve()
+	defer server.close()
+
+	conn, err := net.Dial("tcp", server.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	conn.Write([]byte{socksVersion, 1, 0x00})
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil || reply[1] != 0x00 {
+		t.Fatalf("socks5 auth failed: %v %v\n", reply, err)
+	}
+	domain := "example.com"
+	request := []byte{socksVersion, cmdConnect, 0x00, atypDomain, byte(len(domain))}
+	request = append(request, domain...)
+	request = append(request, 0x00, 0x50)
+	conn.Write(request)
+	reply = make([]byte, 10)
+	if _, err := io.ReadFull(conn, reply); err != nil || reply[1] != repSuccess {
+		t.Fatalf("socks5 connect failed: %v %v\n", reply, err)
+	}
+
+	data := []byte("hello schannel-qt5")
+	conn.Write(data)
+	buf := make([]byte, len(data))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("read echo failed: %v\n", err)
+	}
+	if !bytes.Equal(buf, data) {
+		t.Errorf("wrong echo data: %s\n", buf)
+	}
+}
+
+func TestCheckNode(t *testing.T) {
+	node := &parser.SSRNode{Crypto: "aes-128-cfb", Proto: "auth_aes128_md5", Minx: "tls1.2_ticket_auth"}
+	if err := checkNode(node); err != nil {
+		t.Errorf("check supported node failed: %v\n", err)
+	}
+
+	node.Minx = "http_simple_compatible"
+	if err := checkNode(node); err != nil {
+		t.Errorf("check compatible obfs failed: %v\n", err)
+	}
+
+	node.Proto = "auth_chain_z"
+	if err := checkNode(node); err != ErrProtocolNotSupported {
+		t.Errorf("unsupported protocol didn't fail\n")
+	}
+}
+
+// fakeTLSServerHello 按照服务端的实现生成握手回复
+func fakeTLSServerHello(key, clientID []byte) []byte {
+	mac := func(data []byte) []byte {
+		c := &tlsObfsConn{info: &serverInfo{key: key, tls: &tlsData{clientID: clientID}}}
+		return c.hmac(data)
+	}
+
+	random := appendUint32BE(nil, uint32(time.Now().Unix()))
+	random = append(random, randBytes(18)...)
+	random = append(random, mac(random)...)
+
+	data := append([]byte{}, tlsVersion...)
+	data = append(data, random...)
+	data = append(data, 0x20)
+	data = append(data, clientID...)
+	data = append(data, mustDecodeHex("c02f000005ff01000100")...)
+	hello := appendUint16BE([]byte{0x02, 0x00}, uint16(len(data)))
+	hello = append(hello, data...)
+
+	resp := appendUint16BE([]byte{tlsHandshake, 0x03, 0x03}, uint16(len(hello)))
+	resp = append(resp, hello...)
+	resp = append(resp, tlsChangeCipherSpec, 0x03, 0x03, 0x00, 0x01, 0x01)
+	resp = append(resp, tlsHandshake, 0x03, 0x03, 0x00, 0x20)
+	resp = append(resp, randBytes(22)...)
+	return append(resp, mac(resp)...)
+}
+
+func TestTLSObfsHandshake(t *testing.T) {
+	info := &serverInfo{
+		host: "127.0.0.1",
+		port: 443,
+		key:  evpBytesToKey("password", 32),
+		tls:  &tlsData{},
+	}
+	left, right := net.Pipe()
+	defer left.Close()
+	defer right.Close()
+
+	client, err := newObfsConn(left, "tls1.2_ticket_auth", info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("first packet")
+	errs := make(chan error, 1)
+	go func() {
+		_, err := client.Write(data)
+		errs <- err
+	}()
+
+	server := &tlsObfsConn{Conn: right, info: info}
+	hello, err := server.readRecord()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// record(5) + handshake header(4) + version(2) + random(32) + session id length(1)
+	clientID := hello[44:76]
+	if !bytes.Equal(clientID, info.tls.id()) {
+		t.Fatalf("wrong client id in client hello\n")
+	}
+	right.Write(fakeTLSServerHello(info.key, clientID))
+
+	// change cipher spec + finished + application data
+	for i := 0; i < 2; i++ {
+		if _, err := server.readRecord(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	record, err := server.readRecord()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record[0] != tlsApplicationData || !bytes.Equal(record[5:], data) {
+		t.Errorf("wrong application data: %v\n", record)
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("handshake failed: %v\n", err)
+	}
+}
@@ -0,0 +1,418 @@
+package goclient
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrObfsNotSupported 不支持的混淆算法
+	ErrObfsNotSupported = errors.New("obfs not supported")
+	// ErrObfsData 收到的混淆数据校验失败
+	ErrObfsData = errors.New("obfs data check failed")
+)
+
+// 支持的混淆算法
+var obfses = map[string]bool{
+	"plain":              true,
+	"http_simple":        true,
+	"http_post":          true,
+	"tls1.2_ticket_auth": true,
+}
+
+// Obfses 返回所有支持的混淆算法名称
+func Obfses() []string {
+	names := make([]string, 0, len(obfses))
+	for name := range obfses {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// newObfsConn 根据混淆算法名称在conn上建立混淆层
+func newObfsConn(conn net.Conn, name string, info *serverInfo) (net.Conn, error) {
+	switch normalizeName(name, "plain") {
+	case "plain":
+		return conn, nil
+	case "http_simple":
+		return &httpObfsConn{Conn: conn, info: info, method: "GET"}, nil
+	case "http_post":
+		return &httpObfsConn{Conn: conn, info: info, method: "POST"}, nil
+	case "tls1.2_ticket_auth":
+		return &tlsObfsConn{Conn: conn, info: info}, nil
+	}
+
+	return nil, ErrObfsNotSupported
+}
+
+// obfsHosts 返回混淆使用的域名列表和自定义的http header
+// 混淆参数格式为"host1,host2#header"，未设置时使用服务器地址
+func obfsHosts(info *serverInfo) ([]string, string) {
+	hosts := info.obfsParam
+	if hosts == "" {
+		hosts = info.host
+	}
+
+	var body string
+	if i := strings.Index(hosts, "#"); i != -1 {
+		body = strings.NewReplacer("\n", "\r\n", `\n`, "\r\n").Replace(hosts[i+1:])
+		hosts = hosts[:i]
+	}
+
+	return strings.Split(hosts, ","), body
+}
+
+// 伪装的User-Agent
+var userAgents = []string{
+	"Mozilla/5.0 (Windows NT 6.3; WOW64; rv:40.0) Gecko/20100101 Firefox/40.0",
+	"Mozilla/5.0 (Windows NT 6.3; WOW64; rv:40.0) Gecko/20100101 Firefox/44.0",
+	"Mozilla/5.0 (Windows NT 6.1; WOW64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/41.0.2228.0 Safari/537.36",
+	"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:44.0) Gecko/20100101 Firefox/44.0",
+}
+
+// httpObfsConn http_simple和http_post混淆
+// 第一个数据包伪装为http请求，服务端回复的http header将被丢弃
+type httpObfsConn struct {
+	net.Conn
+	info *serverInfo
+	// GET或POST
+	method string
+
+	sentHeader bool
+	recvHeader bool
+	recvBuf    []byte
+}
+
+// encodeHead 将数据编码为%xx形式的URL path
+func encodeHead(data []byte) string {
+	var sb strings.Builder
+	for _, b := range data {
+		fmt.Fprintf(&sb, "%%%02x", b)
+	}
+
+	return sb.String()
+}
+
+func (c *httpObfsConn) Write(b []byte) (int, error) {
+	if c.sentHeader {
+		return c.Conn.Write(b)
+	}
+
+	headSize := len(c.info.iv) + 30
+	headLen := len(b)
+	if len(b)-headSize > 64 {
+		headLen = headSize + randInt(65)
+	}
+
+	hosts, body := obfsHosts(c.info)
+	host := hosts[randInt(len(hosts))]
+	if c.info.port != 80 {
+		host += ":" + strconv.Itoa(c.info.port)
+	}
+
+	var header bytes.Buffer
+	fmt.Fprintf(&header, "%s /%s HTTP/1.1\r\n", c.method, encodeHead(b[:headLen]))
+	fmt.Fprintf(&header, "Host: %s\r\n", host)
+	if body != "" {
+		header.WriteString(body + "\r\n\r\n")
+	} else {
+		fmt.Fprintf(&header, "User-Agent: %s\r\n", userAgents[randInt(len(userAgents))])
+		header.WriteString("Accept: text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8\r\n")
+		header.WriteString("Accept-Language: en-US,en;q=0.8\r\nAccept-Encoding: gzip, deflate\r\n")
+		if c.method == "POST" {
+			boundary := hex.EncodeToString(randBytes(8))
+			fmt.Fprintf(&header, "Content-Type: multipart/form-data; boundary=----WebKitFormBoundary%s\r\n", boundary)
+		}
+		header.WriteString("DNT: 1\r\nConnection: keep-alive\r\n\r\n")
+	}
+	header.Write(b[headLen:])
+
+	if _, err := c.Conn.Write(header.Bytes()); err != nil {
+		return 0, err
+	}
+	c.sentHeader = true
+
+	return len(b), nil
+}
+
+func (c *httpObfsConn) Read(b []byte) (int, error) {
+	if !c.recvHeader {
+		buf := make([]byte, 4096)
+		for {
+			n, err := c.Conn.Read(buf)
+			c.recvBuf = append(c.recvBuf, buf[:n]...)
+			if i := bytes.Index(c.recvBuf, []byte("\r\n\r\n")); i != -1 {
+				c.recvBuf = c.recvBuf[i+4:]
+				c.recvHeader = true
+				break
+			}
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if len(c.recvBuf) > 0 {
+		n := copy(b, c.recvBuf)
+		c.recvBuf = c.recvBuf[n:]
+		return n, nil
+	}
+
+	return c.Conn.Read(b)
+}
+
+const (
+	// tls record类型
+	tlsHandshake        = 0x16
+	tlsChangeCipherSpec = 0x14
+	tlsApplicationData  = 0x17
+)
+
+var (
+	// tls1.2
+	tlsVersion = []byte{0x03, 0x03}
+)
+
+// tlsData tls1.2_ticket_auth在多个连接间共享的client id和session ticket
+type tlsData struct {
+	sync.Mutex
+	clientID []byte
+	tickets  map[string][]byte
+}
+
+// id 返回连接使用的client id
+func (t *tlsData) id() []byte {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.clientID == nil {
+		t.clientID = randBytes(32)
+	}
+	return t.clientID
+}
+
+// ticket 返回host对应的session ticket
+func (t *tlsData) ticket(host string) []byte {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.tickets == nil {
+		t.tickets = make(map[string][]byte)
+	}
+	if _, ok := t.tickets[host]; !ok {
+		t.tickets[host] = randBytes((randInt(17) + 8) * 16)
+	}
+	return t.tickets[host]
+}
+
+// tlsObfsConn tls1.2_ticket_auth混淆
+// 第一次写入时模拟tls握手，之后的数据作为application data发送
+type tlsObfsConn struct {
+	net.Conn
+	info *serverInfo
+
+	handshaked bool
+	recvBuf    []byte
+}
+
+func (c *tlsObfsConn) hmac(data []byte) []byte {
+	key := append(append([]byte{}, c.info.key...), c.info.tls.id()...)
+	h := hmac.New(sha1.New, key)
+	h.Write(data)
+	return h.Sum(nil)[:10]
+}
+
+// packAuthData 生成client hello中的random字段
+func (c *tlsObfsConn) packAuthData() []byte {
+	data := appendUint32BE(nil, uint32(time.Now().Unix()))
+	data = append(data, randBytes(18)...)
+	return append(data, c.hmac(data)...)
+}
+
+// sni 生成server name indication扩展
+func sni(host string) []byte {
+	data := []byte{0x00}
+	data = appendUint16BE(data, uint16(len(host)))
+	data = append(data, host...)
+
+	ext := []byte{0x00, 0x00}
+	ext = appendUint16BE(ext, uint16(len(data)+2))
+	ext = appendUint16BE(ext, uint16(len(data)))
+	return append(ext, data...)
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
+
+// clientHello 生成tls client hello
+func (c *tlsObfsConn) clientHello() []byte {
+	clientID := c.info.tls.id()
+	data := append([]byte{}, tlsVersion...)
+	data = append(data, c.packAuthData()...)
+	data = append(data, 0x20)
+	data = append(data, clientID...)
+	data = append(data, mustDecodeHex("001cc02bc02fcca9cca8cc14cc13c00ac014c009c013009c0035002f000a0100")...)
+
+	hosts, _ := obfsHosts(c.info)
+	host := hosts[randInt(len(hosts))]
+	// ip地址不能作为sni
+	if host != "" && host[len(host)-1] >= '0' && host[len(host)-1] <= '9' {
+		host = ""
+	}
+	ticket := c.info.tls.ticket(host)
+
+	ext := mustDecodeHex("ff01000100")
+	ext = append(ext, sni(host)...)
+	ext = append(ext, 0x00, 0x17, 0x00, 0x00)
+	ext = append(ext, 0x00, 0x23)
+	ext = appendUint16BE(ext, uint16(len(ticket)))
+	ext = append(ext, ticket...)
+	ext = append(ext, mustDecodeHex("000d001600140601060305010503040104030301030302010203")...)
+	ext = append(ext, mustDecodeHex("000500050100000000")...)
+	ext = append(ext, mustDecodeHex("00120000")...)
+	ext = append(ext, mustDecodeHex("75500000")...)
+	ext = append(ext, mustDecodeHex("000b00020100")...)
+	ext = append(ext, mustDecodeHex("000a0006000400170018")...)
+
+	data = appendUint16BE(data, uint16(len(ext)))
+	data = append(data, ext...)
+
+	hello := appendUint16BE([]byte{0x01, 0x00}, uint16(len(data)))
+	hello = append(hello, data...)
+	record := appendUint16BE([]byte{tlsHandshake, 0x03, 0x01}, uint16(len(hello)))
+	return append(record, hello...)
+}
+
+// readRecord 读取一个完整的tls record
+func (c *tlsObfsConn) readRecord() ([]byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(c.Conn, header); err != nil {
+		return nil, err
+	}
+
+	size := int(header[3])<<8 | int(header[4])
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(c.Conn, payload); err != nil {
+		return nil, err
+	}
+
+	return append(header, payload...), nil
+}
+
+// packRecords 将数据打包为application data
+func packRecords(buf []byte) []byte {
+	res := make([]byte, 0, len(buf)+64)
+	for len(buf) > 2048 {
+		size := randInt(4096) + 100
+		if size > len(buf) {
+			size = len(buf)
+		}
+		res = append(res, tlsApplicationData, tlsVersion[0], tlsVersion[1])
+		res = appendUint16BE(res, uint16(size))
+		res = append(res, buf[:size]...)
+		buf = buf[size:]
+	}
+	if len(buf) > 0 {
+		res = append(res, tlsApplicationData, tlsVersion[0], tlsVersion[1])
+		res = appendUint16BE(res, uint16(len(buf)))
+		res = append(res, buf...)
+	}
+
+	return res
+}
+
+// handshake 完成模拟的tls握手，并发送第一个数据包
+func (c *tlsObfsConn) handshake(first []byte) error {
+	if _, err := c.Conn.Write(c.clientHello()); err != nil {
+		return err
+	}
+
+	// server hello之后可能有new session ticket，以change cipher spec之后的finished结束
+	var resp []byte
+	for {
+		record, err := c.readRecord()
+		if err != nil {
+			return err
+		}
+		resp = append(resp, record...)
+		if record[0] == tlsChangeCipherSpec {
+			finished, err := c.readRecord()
+			if err != nil {
+				return err
+			}
+			resp = append(resp, finished...)
+			break
+		}
+	}
+
+	if len(resp) < 11+32+1+32 {
+		return ErrObfsData
+	}
+	if !hmac.Equal(c.hmac(resp[11:33]), resp[33:43]) ||
+		!hmac.Equal(c.hmac(resp[:len(resp)-10]), resp[len(resp)-10:]) {
+		return ErrObfsData
+	}
+
+	finish := []byte{tlsChangeCipherSpec, tlsVersion[0], tlsVersion[1], 0x00, 0x01, 0x01}
+	finish = append(finish, tlsHandshake, tlsVersion[0], tlsVersion[1], 0x00, 0x20)
+	finish = append(finish, randBytes(22)...)
+	finish = append(finish, c.hmac(finish)...)
+
+	record := append([]byte{tlsApplicationData}, tlsVersion...)
+	record = appendUint16BE(record, uint16(len(first)))
+	record = append(record, first...)
+	if _, err := c.Conn.Write(append(finish, record...)); err != nil {
+		return err
+	}
+	c.handshaked = true
+
+	return nil
+}
+
+func (c *tlsObfsConn) Write(b []byte) (int, error) {
+	if !c.handshaked {
+		if err := c.handshake(b); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if _, err := c.Conn.Write(packRecords(b)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *tlsObfsConn) Read(b []byte) (int, error) {
+	if len(c.recvBuf) == 0 {
+		record, err := c.readRecord()
+		if err != nil {
+			return 0, err
+		}
+		if record[0] != tlsApplicationData {
+			return 0, ErrObfsData
+		}
+		c.recvBuf = record[5:]
+	}
+
+	n := copy(b, c.recvBuf)
+	c.recvBuf = c.recvBuf[n:]
+	return n, nil
+}
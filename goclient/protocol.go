@@ -0,0 +1,397 @@
+package goclient
+
+import (
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// auth_aes128系列协议单个数据包的最大长度
+	authAES128UnitLen = 8100
+)
+
+var (
+	// ErrProtocolNotSupported 不支持的协议
+	ErrProtocolNotSupported = errors.New("protocol not supported")
+	// ErrProtocolData 收到的数据校验失败
+	ErrProtocolData = errors.New("protocol data check failed")
+)
+
+// 支持的协议
+var protocols = map[string]bool{
+	"origin":           true,
+	"auth_aes128_md5":  true,
+	"auth_aes128_sha1": true,
+}
+
+// Protocols 返回所有支持的协议名称
+func Protocols() []string {
+	names := make([]string, 0, len(protocols))
+	for name := range protocols {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// serverInfo 协议和混淆需要的服务器信息
+type serverInfo struct {
+	host string
+	port int
+	// 协议和混淆的参数
+	protocolParam string
+	obfsParam     string
+
+	// 加密使用的key和发送数据使用的iv
+	key []byte
+	iv  []byte
+
+	// 同一节点的所有连接共享的数据
+	auth *authData
+	tls  *tlsData
+}
+
+// authData auth_aes128系列协议在多个连接间共享的client id和connection id
+type authData struct {
+	sync.Mutex
+	clientID     []byte
+	connectionID uint32
+}
+
+// next 返回本次连接使用的client id和connection id
+func (a *authData) next() ([]byte, uint32) {
+	a.Lock()
+	defer a.Unlock()
+
+	if a.clientID == nil || a.connectionID > 0xFF000000 {
+		a.clientID = randBytes(4)
+		a.connectionID = binary.LittleEndian.Uint32(randBytes(4)) & 0xFFFFFF
+	}
+	a.connectionID++
+
+	return a.clientID, a.connectionID
+}
+
+// normalizeName 去除协议和混淆名称的_compatible后缀，空值使用def替代
+func normalizeName(name, def string) string {
+	name = strings.TrimSuffix(name, "_compatible")
+	if name == "" {
+		return def
+	}
+
+	return name
+}
+
+// newProtocolConn 根据协议名称在conn上建立协议层
+func newProtocolConn(conn net.Conn, name string, info *serverInfo) (net.Conn, error) {
+	switch normalizeName(name, "origin") {
+	case "origin":
+		return conn, nil
+	case "auth_aes128_md5":
+		return newAuthAES128Conn(conn, info, md5.New, "auth_aes128_md5"), nil
+	case "auth_aes128_sha1":
+		return newAuthAES128Conn(conn, info, sha1.New, "auth_aes128_sha1"), nil
+	}
+
+	return nil, ErrProtocolNotSupported
+}
+
+// authAES128Conn auth_aes128_md5和auth_aes128_sha1协议的客户端实现
+type authAES128Conn struct {
+	net.Conn
+	info     *serverInfo
+	hashFunc func() hash.Hash
+	salt     string
+
+	uid     []byte
+	userKey []byte
+
+	sentHeader bool
+	packID     uint32
+	recvID     uint32
+
+	// 尚未处理完的接收数据和已经解包的数据
+	recvBuf []byte
+	pending []byte
+}
+
+func newAuthAES128Conn(conn net.Conn, info *serverInfo, hashFunc func() hash.Hash, salt string) *authAES128Conn {
+	c := &authAES128Conn{
+		Conn:     conn,
+		info:     info,
+		hashFunc: hashFunc,
+		salt:     salt,
+		uid:      randBytes(4),
+		packID:   1,
+		recvID:   1,
+	}
+
+	// 协议参数格式为"uid:password"，用于单端口多用户
+	if i := strings.Index(info.protocolParam, ":"); i != -1 {
+		uid, err := strconv.ParseUint(info.protocolParam[:i], 10, 32)
+		if err == nil {
+			binary.LittleEndian.PutUint32(c.uid, uint32(uid))
+			h := hashFunc()
+			h.Write([]byte(info.protocolParam[i+1:]))
+			c.userKey = h.Sum(nil)
+		}
+	}
+	if c.userKey == nil {
+		c.userKey = info.key
+	}
+
+	return c
+}
+
+func (c *authAES128Conn) hmac(key, data []byte) []byte {
+	h := hmac.New(c.hashFunc, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// macKey 数据包校验使用的key：user key + 小端序的包序号
+func (c *authAES128Conn) macKey(id uint32) []byte {
+	key := make([]byte, len(c.userKey), len(c.userKey)+4)
+	copy(key, c.userKey)
+	return appendUint32LE(key, id)
+}
+
+// rndData 生成数据包头部的随机填充，第一个字节(或之后的两个字节)记录填充长度
+func (c *authAES128Conn) rndData(bufSize int) []byte {
+	if bufSize > 1200 {
+		return []byte{1}
+	}
+
+	var n int
+	switch {
+	case c.packID > 4:
+		n = randInt(32)
+	case bufSize > 900:
+		n = randInt(128)
+	default:
+		n = randInt(512)
+	}
+
+	if n < 128 {
+		return append([]byte{byte(n + 1)}, randBytes(n)...)
+	}
+	head := []byte{255}
+	head = appendUint16LE(head, uint16(n+3))
+	return append(head, randBytes(n)...)
+}
+
+// packData 打包普通数据：长度(2) + 长度校验(2) + 随机填充 + 数据 + 校验(4)
+func (c *authAES128Conn) packData(buf []byte) []byte {
+	rnd := c.rndData(len(buf))
+	dataLen := len(rnd) + len(buf) + 8
+	macKey := c.macKey(c.packID)
+
+	data := appendUint16LE(make([]byte, 0, dataLen), uint16(dataLen))
+	data = append(data, c.hmac(macKey, data[:2])[:2]...)
+	data = append(data, rnd...)
+	data = append(data, buf...)
+	data = append(data, c.hmac(macKey, data)[:4]...)
+	c.packID++
+
+	return data
+}
+
+// packAuthData 打包连接的第一个数据包，包含认证信息
+func (c *authAES128Conn) packAuthData(buf []byte) ([]byte, error) {
+	var rndLen int
+	if len(buf) > 400 {
+		rndLen = randInt(512)
+	} else {
+		rndLen = randInt(1024)
+	}
+
+	// 认证数据：时间戳(4) + client id(4) + connection id(4) + 数据包长度(2) + 填充长度(2)
+	clientID, connectionID := c.info.auth.next()
+	dataLen := 7 + 4 + 16 + 4 + len(buf) + rndLen + 4
+	auth := appendUint32LE(nil, uint32(time.Now().Unix()))
+	auth = append(auth, clientID...)
+	auth = appendUint32LE(auth, connectionID)
+	auth = appendUint16LE(auth, uint16(dataLen))
+	auth = appendUint16LE(auth, uint16(rndLen))
+
+	// 认证数据使用aes-128-cbc加密，iv为0，只有一个block
+	encKey := evpBytesToKey(base64.StdEncoding.EncodeToString(c.userKey)+c.salt, 16)
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := make([]byte, aes.BlockSize)
+	block.Encrypt(encrypted, auth)
+
+	macKey := append(append([]byte{}, c.info.iv...), c.info.key...)
+	data := append(append([]byte{}, c.uid...), encrypted...)
+	data = append(data, c.hmac(macKey, data)[:4]...)
+
+	checkHead := randBytes(1)
+	checkHead = append(checkHead, c.hmac(macKey, checkHead)[:6]...)
+
+	res := make([]byte, 0, dataLen)
+	res = append(res, checkHead...)
+	res = append(res, data...)
+	res = append(res, randBytes(rndLen)...)
+	res = append(res, buf...)
+	res = append(res, c.hmac(c.userKey, res)[:4]...)
+
+	return res, nil
+}
+
+func (c *authAES128Conn) Write(b []byte) (int, error) {
+	buf := b
+	out := make([]byte, 0, len(b)+64)
+	if !c.sentHeader {
+		headLen := randInt(32) + getHeadSize(buf, 30)
+		if headLen > len(buf) {
+			headLen = len(buf)
+		}
+
+		data, err := c.packAuthData(buf[:headLen])
+		if err != nil {
+			return 0, err
+		}
+		out = append(out, data...)
+		buf = buf[headLen:]
+		c.sentHeader = true
+	}
+
+	for len(buf) > authAES128UnitLen {
+		out = append(out, c.packData(buf[:authAES128UnitLen])...)
+		buf = buf[authAES128UnitLen:]
+	}
+	if len(buf) > 0 {
+		out = append(out, c.packData(buf)...)
+	}
+
+	if _, err := c.Conn.Write(out); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// unpack 从recvBuf中解出所有完整的数据包
+func (c *authAES128Conn) unpack() error {
+	for len(c.recvBuf) > 4 {
+		macKey := c.macKey(c.recvID)
+		if !hmac.Equal(c.hmac(macKey, c.recvBuf[:2])[:2], c.recvBuf[2:4]) {
+			return ErrProtocolData
+		}
+
+		length := int(binary.LittleEndian.Uint16(c.recvBuf[:2]))
+		if length >= 8192 || length < 7 {
+			return ErrProtocolData
+		}
+		if length > len(c.recvBuf) {
+			break
+		}
+
+		if !hmac.Equal(c.hmac(macKey, c.recvBuf[:length-4])[:4], c.recvBuf[length-4:length]) {
+			return ErrProtocolData
+		}
+		c.recvID++
+
+		pos := int(c.recvBuf[4])
+		if pos < 255 {
+			pos += 4
+		} else {
+			pos = int(binary.LittleEndian.Uint16(c.recvBuf[5:7])) + 4
+		}
+		if pos > length-4 {
+			return ErrProtocolData
+		}
+		c.pending = append(c.pending, c.recvBuf[pos:length-4]...)
+		c.recvBuf = c.recvBuf[length:]
+	}
+
+	return nil
+}
+
+func (c *authAES128Conn) Read(b []byte) (int, error) {
+	buf := make([]byte, authAES128UnitLen+512)
+	for len(c.pending) == 0 {
+		n, err := c.Conn.Read(buf)
+		c.recvBuf = append(c.recvBuf, buf[:n]...)
+		if perr := c.unpack(); perr != nil {
+			return 0, perr
+		}
+		if err != nil {
+			if len(c.pending) == 0 {
+				return 0, err
+			}
+			break
+		}
+	}
+
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// getHeadSize 根据地址类型计算socks5目标地址头部的长度
+func getHeadSize(buf []byte, def int) int {
+	if len(buf) < 2 {
+		return def
+	}
+
+	switch buf[0] & 0x7 {
+	case atypIPv4:
+		return 7
+	case atypIPv6:
+		return 19
+	case atypDomain:
+		return 4 + int(buf[1])
+	}
+
+	return def
+}
+
+// randBytes 返回n个随机字节
+func randBytes(n int) []byte {
+	b := make([]byte, n)
+	io.ReadFull(rand.Reader, b)
+	return b
+}
+
+// randInt 返回[0, max)之间的随机数
+func randInt(max int) int {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0
+	}
+
+	return int(n.Int64())
+}
+
+func appendUint16LE(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32LE(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint16BE(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32BE(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
@@ -0,0 +1,343 @@
+package goclient
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"schannel-qt5/parser"
+)
+
+const (
+	socksVersion = 0x05
+
+	// socks5请求命令
+	cmdConnect = 0x01
+
+	// socks5地址类型
+	atypIPv4   = 0x01
+	atypDomain = 0x03
+	atypIPv6   = 0x04
+
+	// socks5回复状态
+	repSuccess             = 0x00
+	repCommandNotSupported = 0x07
+	repAddrNotSupported    = 0x08
+
+	// 连接ssr服务器的超时时间
+	dialTimeout = 10 * time.Second
+	// socks5握手的超时时间
+	handshakeTimeout = 30 * time.Second
+)
+
+var (
+	// ErrSocksVersion 不是socks5协议
+	ErrSocksVersion = errors.New("socks version not supported")
+	// ErrSocksCommand 不支持的socks5命令
+	ErrSocksCommand = errors.New("socks command not supported")
+	// ErrSocksAddrType 不支持的socks5地址类型
+	ErrSocksAddrType = errors.New("socks address type not supported")
+)
+
+// readSocksRequest 完成socks5握手，返回目标地址的原始数据(atyp + addr + port)
+func readSocksRequest(conn net.Conn) ([]byte, error) {
+	// 客户端问候：ver + nmethods + methods
+	buf := make([]byte, 262)
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return nil, err
+	}
+	if buf[0] != socksVersion {
+		return nil, ErrSocksVersion
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		return nil, err
+	}
+	// 不需要认证
+	if _, err := conn.Write([]byte{socksVersion, 0x00}); err != nil {
+		return nil, err
+	}
+
+	// 请求：ver + cmd + rsv + atyp
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		return nil, err
+	}
+	if buf[0] != socksVersion {
+		return nil, ErrSocksVersion
+	}
+	if buf[1] != cmdConnect {
+		writeSocksReply(conn, repCommandNotSupported)
+		return nil, ErrSocksCommand
+	}
+
+	atyp := buf[3]
+	var addrLen int
+	switch atyp {
+	case atypIPv4:
+		addrLen = net.IPv4len
+	case atypIPv6:
+		addrLen = net.IPv6len
+	case atypDomain:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return nil, err
+		}
+		addrLen = int(buf[0])
+	default:
+		writeSocksReply(conn, repAddrNotSupported)
+		return nil, ErrSocksAddrType
+	}
+
+	addr := []byte{atyp}
+	if atyp == atypDomain {
+		addr = append(addr, byte(addrLen))
+	}
+	// 地址和2字节的端口
+	if _, err := io.ReadFull(conn, buf[:addrLen+2]); err != nil {
+		return nil, err
+	}
+	addr = append(addr, buf[:addrLen+2]...)
+
+	return addr, nil
+}
+
+// writeSocksReply 回复socks5请求，bind地址固定为0.0.0.0:0
+func writeSocksReply(conn net.Conn, rep byte) error {
+	_, err := conn.Write([]byte{socksVersion, rep, 0x00, atypIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
+
+// sharedData 同一节点的所有连接共享的协议数据
+type sharedData struct {
+	auth authData
+	tls  tlsData
+}
+
+// checkNode 检查节点使用的加密算法，协议和混淆是否支持
+func checkNode(node *parser.SSRNode) error {
+	if _, ok := ciphers[node.Crypto]; !ok {
+		return ErrCipherNotSupported
+	}
+	if !protocols[normalizeName(node.Proto, "origin")] {
+		return ErrProtocolNotSupported
+	}
+	if !obfses[normalizeName(node.Minx, "plain")] {
+		return ErrObfsNotSupported
+	}
+
+	return nil
+}
+
+// dialSSR 连接ssr服务器，返回的连接可以直接读写原始数据
+func dialSSR(node *parser.SSRNode, shared *sharedData) (net.Conn, error) {
+	c, err := newStreamCipher(node.Crypto, node.Passwd)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := net.JoinHostPort(node.IP, strconv.FormatInt(node.Port, 10))
+	raw, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &serverInfo{
+		host:          node.IP,
+		port:          int(node.Port),
+		protocolParam: node.ProtoParam,
+		obfsParam:     node.MinxParam,
+		key:           c.key,
+		iv:            c.iv,
+		auth:          &shared.auth,
+		tls:           &shared.tls,
+	}
+	conn, err := newObfsConn(raw, node.Minx, info)
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	conn, err = newCipherConn(conn, c)
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	conn, err = newProtocolConn(conn, node.Proto, info)
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// socksServer 本地socks5服务，将所有连接通过ssr节点转发
+type socksServer struct {
+	listener net.Listener
+	node     *parser.SSRNode
+	shared   *sharedData
+	// 连接空闲超过timeout后关闭，为0时不限制
+	timeout time.Duration
+	// 是否记录每个连接的目标地址
+	verbose bool
+
+	// 记录正在转发的连接，关闭服务时一并关闭
+	lock  sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+// newSocksServer 监听addr，使用node转发数据
+func newSocksServer(addr string, node *parser.SSRNode) (*socksServer, error) {
+	if err := checkNode(node); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &socksServer{
+		listener: listener,
+		node:     node,
+		shared:   &sharedData{},
+		conns:    make(map[net.Conn]struct{}),
+	}
+	return s, nil
+}
+
+// serve 处理连接，直到listener被关闭
+func (s *socksServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// track 记录或删除活动的连接
+func (s *socksServer) track(conn net.Conn, add bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if add {
+		s.conns[conn] = struct{}{}
+	} else {
+		delete(s.conns, conn)
+	}
+}
+
+func (s *socksServer) handle(conn net.Conn) {
+	s.track(conn, true)
+	defer s.track(conn, false)
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	target, err := readSocksRequest(conn)
+	if err != nil {
+		log.Printf("goclient: socks5 handshake: %v\n", err)
+		return
+	}
+
+	remote, err := dialSSR(s.node, s.shared)
+	if err != nil {
+		log.Printf("goclient: dial ssr server: %v\n", err)
+		writeSocksReply(conn, 0x01)
+		return
+	}
+	s.track(remote, true)
+	defer s.track(remote, false)
+	defer remote.Close()
+
+	if err := writeSocksReply(conn, repSuccess); err != nil {
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	if s.verbose {
+		log.Printf("goclient: %v connect %s\n", conn.RemoteAddr(), targetString(target))
+	}
+
+	// 目标地址作为第一个数据包发送给服务器
+	if _, err := remote.Write(target); err != nil {
+		log.Printf("goclient: send target address: %v\n", err)
+		return
+	}
+
+	relay(conn, remote, s.timeout)
+}
+
+// targetString 将目标地址的原始数据转换为host:port
+func targetString(target []byte) string {
+	var host []byte
+	switch target[0] {
+	case atypIPv4, atypIPv6:
+		host = target[1 : len(target)-2]
+		return net.JoinHostPort(net.IP(host).String(), portString(target))
+	}
+
+	host = target[2 : len(target)-2]
+	return net.JoinHostPort(string(host), portString(target))
+}
+
+// portString 返回目标地址中的端口
+func portString(target []byte) string {
+	port := binary.BigEndian.Uint16(target[len(target)-2:])
+	return strconv.Itoa(int(port))
+}
+
+// idleReader 每次读取前延长两个连接的读取超时，双向都空闲超过timeout时读取失败
+type idleReader struct {
+	src, dst net.Conn
+	timeout  time.Duration
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	if r.timeout > 0 {
+		deadline := time.Now().Add(r.timeout)
+		r.src.SetReadDeadline(deadline)
+		r.dst.SetReadDeadline(deadline)
+	}
+
+	return r.src.Read(p)
+}
+
+// relay 双向转发数据，任意一方关闭或者空闲超过timeout后结束
+func relay(left, right net.Conn, timeout time.Duration) {
+	done := make(chan struct{}, 2)
+	copyConn := func(dst, src net.Conn) {
+		io.Copy(dst, &idleReader{src: src, dst: dst, timeout: timeout})
+		// 使另一方向的io.Copy返回
+		dst.SetDeadline(time.Now())
+		src.SetDeadline(time.Now())
+		done <- struct{}{}
+	}
+
+	go copyConn(left, right)
+	go copyConn(right, left)
+	<-done
+	<-done
+}
+
+// close 关闭监听和所有活动连接，等待处理结束
+func (s *socksServer) close() error {
+	err := s.listener.Close()
+
+	s.lock.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.lock.Unlock()
+
+	s.wg.Wait()
+	return err
+}
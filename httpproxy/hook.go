@@ -0,0 +1,55 @@
+package httpproxy
+
+import (
+	"schannel-qt5/config"
+	"schannel-qt5/ssr"
+)
+
+func init() {
+	// 设置了http_proxy_port时随客户端一起启动
+	ssr.SetHookMaker("http-proxy", ssr.HookMaker(newHook))
+}
+
+// Hook 随ssr客户端启动和停止的http代理
+type Hook struct {
+	conf   *config.UserConfig
+	server *Server
+}
+
+// newHook 这个函数供ssr.HookMaker调用，没有启用http代理时返回nil
+func newHook(c *config.UserConfig) ssr.Hook {
+	if !c.HTTPProxyEnabled() {
+		return nil
+	}
+
+	return &Hook{conf: c}
+}
+
+// Start 启动http代理，转发到ssr客户端的socks5端口
+func (h *Hook) Start() error {
+	if h.server != nil {
+		return nil
+	}
+
+	// 客户端监听在所有地址上时通过回环地址连接
+	socksAddr := ssr.SocksAddr(h.conf.SSRClientConfig)
+	server, err := NewServer(h.conf.HTTPProxyHostPort(), socksAddr)
+	if err != nil {
+		return err
+	}
+	h.server = server
+	go h.server.Serve()
+
+	return nil
+}
+
+// Stop 停止http代理并关闭所有连接
+func (h *Hook) Stop() error {
+	if h.server == nil {
+		return ErrNotRunning
+	}
+
+	err := h.server.Close()
+	h.server = nil
+	return err
+}
@@ -0,0 +1,242 @@
+package httpproxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+
+	"schannel-qt5/ssr"
+)
+
+// ErrNotRunning 代理没有运行
+var ErrNotRunning = errors.New("http proxy is not running")
+
+const (
+	// 连接上游的超时时间
+	dialTimeout = 30 * time.Second
+	// 等待上游响应头的超时时间
+	responseHeaderTimeout = time.Minute
+)
+
+// hopHeaders 只对单跳连接有效，转发时需要删除的头
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Server http代理服务器，所有请求通过socks5代理转发
+// 支持普通的http请求和用于https的CONNECT
+type Server struct {
+	listener  net.Listener
+	dialer    proxy.Dialer
+	transport *http.Transport
+
+	// Close时取消所有正在转发的请求
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lock  sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+// NewServer 在addr上监听，socksAddr为转发使用的socks5代理地址
+func NewServer(addr, socksAddr string) (*Server, error) {
+	dialer, err := proxy.SOCKS5("tcp", socksAddr, nil, &net.Dialer{Timeout: dialTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		listener: listener,
+		dialer:   dialer,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.transport = &http.Transport{
+		Dial:                  dialer.Dial,
+		Proxy:                 nil,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       90 * time.Second,
+		ResponseHeaderTimeout: responseHeaderTimeout,
+	}
+	return s, nil
+}
+
+// Addr 返回监听的地址
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Serve 接受连接直到Close被调用
+func (s *Server) Serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.track(conn, true)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.track(conn, false)
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+// Close 停止监听并关闭所有连接
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.cancel()
+
+	s.lock.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.lock.Unlock()
+	s.wg.Wait()
+	s.transport.CloseIdleConnections()
+
+	return err
+}
+
+// track 记录或者删除正在处理的连接
+func (s *Server) track(conn net.Conn, add bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if add {
+		s.conns[conn] = struct{}{}
+	} else {
+		delete(s.conns, conn)
+	}
+}
+
+// handle 处理一个客户端连接上的所有请求
+func (s *Server) handle(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+
+		if req.Method == http.MethodConnect {
+			s.handleConnect(conn, reader, req)
+			return
+		}
+		if !s.handleHTTP(conn, req) {
+			return
+		}
+	}
+}
+
+// handleConnect 建立隧道，之后原样转发数据
+func (s *Server) handleConnect(conn net.Conn, reader *bufio.Reader, req *http.Request) {
+	upstream, err := s.dialer.Dial("tcp", req.Host)
+	if err != nil {
+		ssr.Logger().Printf("httpproxy: connect %s: %v\n", req.Host, err)
+		writeError(conn, http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+	// Close时同时关闭上游连接，结束转发
+	s.track(upstream, true)
+	defer s.track(upstream, false)
+
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		return
+	}
+
+	// 一个方向结束时只关闭对端的写方向，另一个方向的数据继续转发，直到两个方向都结束
+	var wg sync.WaitGroup
+	pipe := func(dst net.Conn, src io.Reader) {
+		defer wg.Done()
+		if _, err := io.Copy(dst, src); err != nil {
+			// 连接出错，另一个方向也无法继续
+			conn.Close()
+			upstream.Close()
+			return
+		}
+		closeWrite(dst)
+	}
+	wg.Add(2)
+	// reader中可能有客户端已经发送的数据
+	go pipe(upstream, reader)
+	go pipe(conn, upstream)
+	wg.Wait()
+}
+
+// closeWrite 关闭conn的写方向，通知对方数据已经发送完毕
+func closeWrite(conn net.Conn) {
+	if c, ok := conn.(interface{ CloseWrite() error }); ok {
+		c.CloseWrite()
+	}
+}
+
+// handleHTTP 转发普通的http请求，返回连接是否可以继续使用
+func (s *Server) handleHTTP(conn net.Conn, req *http.Request) bool {
+	if req.URL.Scheme == "" || req.URL.Host == "" {
+		// 不是代理请求
+		writeError(conn, http.StatusBadRequest)
+		return false
+	}
+
+	keepAlive := !req.Close && !strings.EqualFold(req.Header.Get("Proxy-Connection"), "close")
+	req.RequestURI = ""
+	for _, h := range hopHeaders {
+		req.Header.Del(h)
+	}
+
+	resp, err := s.transport.RoundTrip(req.WithContext(s.ctx))
+	if err != nil {
+		ssr.Logger().Printf("httpproxy: %s %s: %v\n", req.Method, req.URL, err)
+		writeError(conn, http.StatusBadGateway)
+		return false
+	}
+	defer resp.Body.Close()
+
+	for _, h := range hopHeaders {
+		resp.Header.Del(h)
+	}
+	resp.Close = !keepAlive
+	if err := resp.Write(conn); err != nil {
+		return false
+	}
+
+	return keepAlive
+}
+
+// writeError 返回只有状态码的错误响应
+func writeError(conn net.Conn, code int) {
+	resp := &http.Response{
+		StatusCode: code,
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Close:      true,
+	}
+	resp.Write(conn)
+}
@@ -0,0 +1,281 @@
+package httpproxy
+
+import (
+	"testing"
+
+	"bufio"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// socks5Server 只支持无认证CONNECT的socks5服务器，直接连接目标地址
+func socks5Server(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				head := make([]byte, 2)
+				if _, err := io.ReadFull(conn, head); err != nil {
+					return
+				}
+				io.ReadFull(conn, make([]byte, head[1]))
+				conn.Write([]byte{0x05, 0x00})
+
+				// ver cmd rsv atyp
+				req := make([]byte, 4)
+				if _, err := io.ReadFull(conn, req); err != nil {
+					return
+				}
+				var host string
+				switch req[3] {
+				case 0x01:
+					ip := make([]byte, 4)
+					io.ReadFull(conn, ip)
+					host = net.IP(ip).String()
+				case 0x03:
+					l := make([]byte, 1)
+					io.ReadFull(conn, l)
+					domain := make([]byte, l[0])
+					io.ReadFull(conn, domain)
+					host = string(domain)
+				default:
+					return
+				}
+				port := make([]byte, 2)
+				io.ReadFull(conn, port)
+
+				addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+				upstream, err := net.Dial("tcp", addr)
+				if err != nil {
+					conn.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer upstream.Close()
+				conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+
+				// 转发客户端的半关闭
+				go func() {
+					io.Copy(upstream, conn)
+					upstream.(*net.TCPConn).CloseWrite()
+				}()
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+
+	return listener
+}
+
+// newTestServer 启动转发到测试socks5服务器的http代理
+func newTestServer(t *testing.T) (*Server, func()) {
+	socks := socks5Server(t)
+	server, err := NewServer("127.0.0.1:0", socks.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+
+	return server, func() {
+		server.Close()
+		socks.Close()
+	}
+}
+
+func TestHTTPRequest(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Connection") != "" {
+			t.Errorf("hop-by-hop header was forwarded\n")
+		}
+		io.WriteString(w, "hello "+r.URL.Path)
+	}))
+	defer backend.Close()
+	server, closeServer := newTestServer(t)
+	defer closeServer()
+
+	proxyURL, _ := url.Parse("http://" + server.Addr().String())
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+		Timeout:   5 * time.Second,
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(backend.URL + "/schannel")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(data) != "hello /schannel" {
+			t.Errorf("wrong response: %s\n", data)
+		}
+	}
+}
+
+func TestConnect(t *testing.T) {
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	server, closeServer := newTestServer(t)
+	defer closeServer()
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	io.WriteString(conn, "CONNECT "+echo.Addr().String()+" HTTP/1.1\r\nHost: "+echo.Addr().String()+"\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("connect failed: %v\n", resp.Status)
+	}
+
+	data := "tunnel data"
+	io.WriteString(conn, data)
+	buf := make([]byte, len(data))
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != data {
+		t.Errorf("wrong tunnel data: %s\n", buf)
+	}
+}
+
+func TestConnectHalfClose(t *testing.T) {
+	// 读取完整的请求后才发送响应
+	response := strings.Repeat("response data\n", 64<<10)
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	go func() {
+		conn, err := target.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if _, err := ioutil.ReadAll(conn); err != nil {
+			return
+		}
+		io.WriteString(conn, response)
+	}()
+	server, closeServer := newTestServer(t)
+	defer closeServer()
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	io.WriteString(conn, "CONNECT "+target.Addr().String()+" HTTP/1.1\r\nHost: "+target.Addr().String()+"\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("connect failed: %v\n", resp.Status)
+	}
+
+	io.WriteString(conn, "request")
+	conn.(*net.TCPConn).CloseWrite()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != len(response) {
+		t.Errorf("response was cut after half-close: %d of %d bytes\n", len(data), len(response))
+	}
+}
+
+func TestNotProxyRequest(t *testing.T) {
+	server, closeServer := newTestServer(t)
+	defer closeServer()
+
+	resp, err := http.Get("http://" + server.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("wrong status for direct request: %v\n", resp.Status)
+	}
+}
+
+func TestCloseWithPendingRequest(t *testing.T) {
+	// 上游只接受连接，不返回响应
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+	go func() {
+		for {
+			conn, err := upstream.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	server, closeServer := newTestServer(t)
+	defer closeServer()
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET http://"+upstream.Addr().String()+"/ HTTP/1.1\r\nHost: "+upstream.Addr().String()+"\r\n\r\n")
+	time.Sleep(100 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		server.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Close blocked by a pending request\n")
+	}
+}
@@ -0,0 +1,203 @@
+package libevclient
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"schannel-qt5/atomicfile"
+	"schannel-qt5/config"
+	"schannel-qt5/parser"
+	"schannel-qt5/ssr"
+)
+
+var (
+	// ErrRunning 客户端已经在运行
+	ErrRunning = errors.New("client is already running")
+	// ErrWrongConfig ClientConfig不是libev客户端使用的配置
+	ErrWrongConfig = errors.New("client config is not a libev config")
+)
+
+// libevRequirement libev客户端需要shadowsocksr-libev的ssr-local
+var libevRequirement = ssr.BinRequirement{
+	Names: []string{"ssr-local"},
+	Dirs:  []string{"/usr/local/bin", "/usr/bin", "~/.local/bin"},
+}
+
+// LibevClient 调用shadowsocksr-libev的ssr-local
+type LibevClient struct {
+	// ssr-local的路径
+	bin string
+	// 节点配置文件路径
+	nodeConfigPath string
+	// 程序需要的配置
+	conf *ClientConfig
+	// 需要root权限时使用的权限提升方式
+	elevator ssr.Elevator
+}
+
+func init() {
+	// 注册为可用的Launcher，name为libev
+	ssr.SetLuancherMaker("libev", ssr.LauncherMaker(newLibevClient))
+	ssr.SetBinRequirement("libev", libevRequirement)
+}
+
+// newLibevClient 这个函数供ssr.LauncherMaker调用，用于生成ssr.Launcher
+func newLibevClient(c *config.UserConfig) ssr.Launcher {
+	bin, err := c.SSRBin.AbsPath()
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	nodeConfigPath, err := c.SSRNodeConfigPath.AbsPath()
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	conf, ok := c.SSRClientConfig.(*ClientConfig)
+	if !ok {
+		log.Println(ErrWrongConfig)
+		return nil
+	}
+
+	elevator, err := ssr.NewElevator(c.Elevator("libev"))
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	return &LibevClient{
+		bin:            bin,
+		nodeConfigPath: nodeConfigPath,
+		conf:           conf,
+		elevator:       elevator,
+	}
+}
+
+// genConfigFile 根据当前节点生成ssr-local的配置文件
+// 配置文件中含有节点密码，所以只有当前用户可读写
+func (l *LibevClient) genConfigFile() error {
+	node := &parser.SSRNode{}
+	if err := node.Load(l.nodeConfigPath); err != nil {
+		return err
+	}
+
+	data, err := l.conf.GenLibevConfig(node)
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(l.conf.ConfigFilePath(), data, 0600)
+}
+
+// Start 启动客户端
+// ssr-local使用-f参数后将以daemon模式运行
+func (l *LibevClient) Start() error {
+	if err := l.IsRunning(); err == nil {
+		return ErrRunning
+	}
+
+	if err := l.genConfigFile(); err != nil {
+		return err
+	}
+
+	// ssr-local不会创建pidfile所在的目录
+	if err := os.MkdirAll(filepath.Dir(l.conf.PidFilePath()), 0700); err != nil {
+		return err
+	}
+
+	args := []string{"-c", l.conf.ConfigFilePath(), "-f", l.conf.PidFilePath()}
+	args = append(args, l.conf.GenArgs()...)
+	// 只有监听特权端口或者pidfile不可写时才需要root权限
+	elevator := ssr.NoElevator
+	if ssr.NeedsPrivilege(l.conf.LocalPort(), l.conf.PidFilePath()) {
+		elevator = l.elevator
+	}
+	cmd := elevator.Command(l.bin, args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// Restart 重新启动客户端
+func (l *LibevClient) Restart() error {
+	if err := l.IsRunning(); err == nil {
+		if err := l.Stop(); err != nil {
+			return err
+		}
+	}
+
+	return l.Start()
+}
+
+// Stop 停止客户端
+func (l *LibevClient) Stop() error {
+	pid, err := readPidFile(l.conf.PidFilePath())
+	if err != nil {
+		return err
+	}
+
+	err = syscall.Kill(pid, syscall.SIGTERM)
+	if err == syscall.EPERM {
+		// 客户端以root权限运行
+		cmd := l.elevator.Command("kill", "-TERM", strconv.Itoa(pid))
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+		}
+	} else if err != nil && err != syscall.ESRCH {
+		return err
+	}
+
+	// ssr-local退出时不会删除pidfile
+	if err := os.Remove(l.conf.PidFilePath()); err != nil && !os.IsPermission(err) {
+		return err
+	}
+
+	return nil
+}
+
+// State 根据pidfile对应的进程和端口占用情况返回客户端状态
+func (l *LibevClient) State() (ssr.State, error) {
+	match := func(cmdline []string) bool {
+		return len(cmdline) != 0 && filepath.Base(cmdline[0]) == filepath.Base(l.bin)
+	}
+
+	checker := ssr.NewProcessChecker(l.conf.PidFilePath(), l.conf.LocalAddr(), l.conf.LocalPort(), match)
+	return checker.State()
+}
+
+// IsRunning 客户端正在运行返回nil
+func (l *LibevClient) IsRunning() error {
+	state, err := l.State()
+	if err != nil {
+		return err
+	}
+
+	return state.Err()
+}
+
+// ConnectionCheck 检查代理是否可用，不可用则返回error
+func (l *LibevClient) ConnectionCheck(timeout time.Duration) error {
+	return ssr.CheckProxy(l.conf.LocalAddr(), l.conf.LocalPort(), timeout)
+}
+
+// readPidFile 读取pidfile中记录的pid
+func readPidFile(path string) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
@@ -0,0 +1,341 @@
+package libevclient
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"schannel-qt5/atomicfile"
+	"schannel-qt5/config"
+	"schannel-qt5/parser"
+	"schannel-qt5/ssr"
+	"schannel-qt5/xdg"
+)
+
+// 默认的pidfile和生成的配置文件名，位于$XDG_STATE_HOME/schannel-qt5下
+// 配置文件中含有节点密码，不能放在所有用户都可写的/tmp中
+const (
+	pidFileName    = "ssr_libev.pid"
+	configFileName = "ssr_libev.json"
+)
+
+var (
+	defaultPort    = "1080"
+	defaultAddr    = "127.0.0.1"
+	defaultTimeout = 60
+)
+
+// ClientConfig ssr-local(shadowsocksr-libev)的本地配置
+type ClientConfig struct {
+	// 本地端口和ip(default: 127.0.0.1:1080)
+	Addr string `json:"local_addr,omitempty"`
+	Port string `json:"local_port,omitempty"`
+
+	// fast-open 需要linux 3.7+(default: false)
+	IsFastOpen bool `json:"fast-open,omitempty"`
+
+	// pidfile存放位置(default: $XDG_STATE_HOME/schannel-qt5/ssr_libev.pid)
+	PidFile string `json:"pid-file,omitempty"`
+
+	// 根据节点和本地配置生成的ssr-local配置文件(default: $XDG_STATE_HOME/schannel-qt5/ssr_libev.json)
+	ConfigFile string `json:"libev-config,omitempty"`
+
+	// -u 开启udp转发(default: false)
+	IsUDPRelay bool `json:"udp-relay,omitempty"`
+	// -t 连接超时时间，单位为秒(default: 60)
+	TimeoutSeconds int `json:"timeout,omitempty"`
+	// -n 最大打开文件数，0表示使用系统默认值
+	NoFile int `json:"nofile,omitempty"`
+	// --mtu 0表示使用默认值
+	MTU int `json:"mtu,omitempty"`
+	// --reuse-port(default: false)
+	IsReusePort bool `json:"reuse-port,omitempty"`
+	// -v 输出详细日志(default: false)
+	IsVerbose bool `json:"verbose,omitempty"`
+
+	// ssr-local不支持日志文件，保留这一项以便切换客户端时迁移设置
+	LogFile string `json:"log-file,omitempty"`
+}
+
+func init() {
+	// 注册到config生成器
+	ssr.SetClientConfigMaker("libev", config.ClientConfigMaker(newClientConfig))
+}
+
+// newClientConfig 生成config对象
+func newClientConfig() config.ClientConfig {
+	return &ClientConfig{}
+}
+
+// 实现ClientConfigGetter
+func (c *ClientConfig) LocalPort() string {
+	if c.Port == "" {
+		return defaultPort
+	}
+
+	return c.Port
+}
+
+func (c *ClientConfig) LocalAddr() string {
+	if c.Addr == "" {
+		return defaultAddr
+	}
+
+	return c.Addr
+}
+
+func (c *ClientConfig) FastOpen() bool {
+	return c.IsFastOpen
+}
+
+func (c *ClientConfig) PidFilePath() string {
+	if c.PidFile == "" {
+		return statePath(pidFileName)
+	}
+
+	return c.PidFile
+}
+
+// ConfigFilePath 生成的ssr-local配置文件的路径
+func (c *ClientConfig) ConfigFilePath() string {
+	if c.ConfigFile == "" {
+		return statePath(configFileName)
+	}
+
+	return c.ConfigFile
+}
+
+// statePath 返回状态目录中名为name的默认路径，找不到$HOME时使用当前目录
+func statePath(name string) string {
+	path, err := xdg.StateFile(name)
+	if err != nil {
+		return name
+	}
+
+	return path
+}
+
+// Timeout 连接超时时间
+func (c *ClientConfig) Timeout() int {
+	if c.TimeoutSeconds == 0 {
+		return defaultTimeout
+	}
+
+	return c.TimeoutSeconds
+}
+
+func (c *ClientConfig) UDPRelay() bool {
+	return c.IsUDPRelay
+}
+
+func (c *ClientConfig) LogFilePath() string {
+	return c.LogFile
+}
+
+func (c *ClientConfig) Verbose() bool {
+	return c.IsVerbose
+}
+
+func (c *ClientConfig) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, c)
+}
+
+func (c *ClientConfig) Store(path string) error {
+	// 格式化成易于阅读的形式
+	data, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(path, data, 0664)
+}
+
+// 实现ClientConfigSetter
+// SetLocalPort 设置本地端口，端口不能大于65535且不能为0
+func (c *ClientConfig) SetLocalPort(port string) error {
+	i, err := strconv.Atoi(port)
+	if err != nil {
+		return err
+	} else if i > 65535 || i <= 0 {
+		return errors.New("port over range")
+	}
+
+	c.Port = port
+	return nil
+}
+
+// SetLocalAddr 检查并设置要bind的本地地址
+// 可以是IPv4、IPv6(包括"[::1]"的形式)或者主机名
+func (c *ClientConfig) SetLocalAddr(addr string) error {
+	addr, err := config.ParseBindAddr(addr)
+	if err != nil {
+		return err
+	}
+
+	c.Addr = addr
+	return nil
+}
+
+func (c *ClientConfig) SetFastOpen(isFOP bool) {
+	c.IsFastOpen = isFOP
+}
+
+// SetPidFilePath 设置pidfile存放路径，需要为绝对路径
+func (c *ClientConfig) SetPidFilePath(path string) error {
+	jpath := config.JSONPath{Data: path}
+	if _, err := jpath.AbsPath(); err != nil {
+		return err
+	}
+
+	c.PidFile = path
+	return nil
+}
+
+// SetConfigFilePath 设置生成的ssr-local配置文件路径，需要为绝对路径
+func (c *ClientConfig) SetConfigFilePath(path string) error {
+	jpath := config.JSONPath{Data: path}
+	if _, err := jpath.AbsPath(); err != nil {
+		return err
+	}
+
+	c.ConfigFile = path
+	return nil
+}
+
+// SetUDPRelay 设置是否开启udp转发
+func (c *ClientConfig) SetUDPRelay(udp bool) {
+	c.IsUDPRelay = udp
+}
+
+// SetTimeout 设置连接超时时间，范围为1-3600秒
+func (c *ClientConfig) SetTimeout(seconds int) error {
+	if err := config.CheckTimeout(seconds); err != nil {
+		return err
+	}
+
+	c.TimeoutSeconds = seconds
+	return nil
+}
+
+// SetLogFilePath 设置日志文件路径，需要为绝对路径，允许为空
+func (c *ClientConfig) SetLogFilePath(path string) error {
+	if err := config.CheckLogFilePath(path); err != nil {
+		return err
+	}
+
+	c.LogFile = path
+	return nil
+}
+
+// SetNoFile 设置最大打开文件数，0表示使用系统默认值
+func (c *ClientConfig) SetNoFile(n int) error {
+	if n < 0 {
+		return errors.New("nofile can't be negative")
+	}
+
+	c.NoFile = n
+	return nil
+}
+
+// SetMTU 设置udp转发的MTU，0表示使用默认值，否则范围为576-9000
+func (c *ClientConfig) SetMTU(mtu int) error {
+	if mtu != 0 && (mtu < 576 || mtu > 9000) {
+		return errors.New("mtu over range")
+	}
+
+	c.MTU = mtu
+	return nil
+}
+
+// SetReusePort 设置是否使用SO_REUSEPORT
+func (c *ClientConfig) SetReusePort(reuse bool) {
+	c.IsReusePort = reuse
+}
+
+// SetVerbose 设置是否输出详细日志
+func (c *ClientConfig) SetVerbose(verbose bool) {
+	c.IsVerbose = verbose
+}
+
+// GenArgs 根据config对象生成额外的命令行参数选项
+func (c *ClientConfig) GenArgs() []string {
+	args := make([]string, 0)
+	args = append(args, "-t", strconv.Itoa(c.Timeout()))
+	if c.IsUDPRelay {
+		args = append(args, "-u")
+	}
+	if c.NoFile != 0 {
+		args = append(args, "-n", strconv.Itoa(c.NoFile))
+	}
+	if c.MTU != 0 {
+		args = append(args, "--mtu", strconv.Itoa(c.MTU))
+	}
+	if c.IsReusePort {
+		args = append(args, "--reuse-port")
+	}
+	if c.IsVerbose {
+		args = append(args, "-v")
+	}
+
+	return args
+}
+
+// libevConfig ssr-local使用的配置文件格式
+type libevConfig struct {
+	Server     string `json:"server"`
+	ServerPort int64  `json:"server_port"`
+	Password   string `json:"password"`
+	Method     string `json:"method"`
+	Protocol   string `json:"protocol"`
+	ProtoParam string `json:"protocol_param,omitempty"`
+	Obfs       string `json:"obfs"`
+	ObfsParam  string `json:"obfs_param,omitempty"`
+
+	LocalAddress string `json:"local_address"`
+	LocalPort    int    `json:"local_port"`
+	Timeout      int    `json:"timeout"`
+	FastOpen     bool   `json:"fast_open"`
+	Mode         string `json:"mode"`
+}
+
+// GenLibevConfig 根据节点信息和本地配置生成ssr-local的配置文件内容
+func (c *ClientConfig) GenLibevConfig(node *parser.SSRNode) ([]byte, error) {
+	port, err := strconv.Atoi(c.LocalPort())
+	if err != nil {
+		return nil, err
+	}
+
+	conf := &libevConfig{
+		Server:       node.IP,
+		ServerPort:   node.Port,
+		Password:     node.Passwd,
+		Method:       node.Crypto,
+		Protocol:     node.Proto,
+		ProtoParam:   node.ProtoParam,
+		Obfs:         node.Minx,
+		ObfsParam:    node.MinxParam,
+		LocalAddress: c.LocalAddr(),
+		LocalPort:    port,
+		Timeout:      c.Timeout(),
+		FastOpen:     c.IsFastOpen,
+		Mode:         "tcp_only",
+	}
+	if c.IsUDPRelay {
+		conf.Mode = "tcp_and_udp"
+	}
+
+	return json.MarshalIndent(conf, "", "\t")
+}
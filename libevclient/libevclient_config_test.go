@@ -0,0 +1,141 @@
+package libevclient
+
+import (
+	"testing"
+
+	"encoding/json"
+	"os"
+	"reflect"
+
+	"schannel-qt5/parser"
+)
+
+func TestClientConfigDefault(t *testing.T) {
+	conf := &ClientConfig{}
+	if conf.LocalPort() != defaultPort {
+		t.Errorf("wrong default port\n")
+	}
+
+	if conf.LocalAddr() != defaultAddr {
+		t.Errorf("wrong default addr\n")
+	}
+
+	os.Setenv("XDG_STATE_HOME", "/tmp/state")
+	defer os.Unsetenv("XDG_STATE_HOME")
+	if conf.PidFilePath() != "/tmp/state/schannel-qt5/"+pidFileName {
+		t.Errorf("wrong default pid-file: %s\n", conf.PidFilePath())
+	}
+
+	if conf.ConfigFilePath() != "/tmp/state/schannel-qt5/"+configFileName {
+		t.Errorf("wrong default libev config file: %s\n", conf.ConfigFilePath())
+	}
+
+	if conf.Timeout() != defaultTimeout {
+		t.Errorf("wrong default timeout\n")
+	}
+}
+
+func TestClientConfigSetters(t *testing.T) {
+	conf := &ClientConfig{}
+
+	for _, v := range []int{1, 60, 3600} {
+		if err := conf.SetTimeout(v); err != nil || conf.Timeout() != v {
+			t.Errorf("set timeout failed: %v\n", v)
+		}
+	}
+	for _, v := range []int{-1, 0, 3601} {
+		if err := conf.SetTimeout(v); err == nil {
+			t.Errorf("set wrong timeout but didn't fail: %v\n", v)
+		}
+	}
+
+	for _, v := range []int{0, 576, 1500, 9000} {
+		if err := conf.SetMTU(v); err != nil || conf.MTU != v {
+			t.Errorf("set mtu failed: %v\n", v)
+		}
+	}
+	for _, v := range []int{-1, 100, 9001} {
+		if err := conf.SetMTU(v); err == nil {
+			t.Errorf("set wrong mtu but didn't fail: %v\n", v)
+		}
+	}
+
+	if err := conf.SetNoFile(-1); err == nil {
+		t.Errorf("set wrong nofile but didn't fail\n")
+	}
+
+	if err := conf.SetConfigFilePath("a.json"); err == nil {
+		t.Errorf("set relative config file path but didn't fail\n")
+	}
+}
+
+func TestClientConfigGenArgs(t *testing.T) {
+	testData := []struct {
+		conf *ClientConfig
+		args []string
+	}{
+		{
+			conf: &ClientConfig{},
+			args: []string{"-t", "60"},
+		},
+		{
+			conf: &ClientConfig{
+				IsUDPRelay:     true,
+				TimeoutSeconds: 300,
+				NoFile:         1024,
+				MTU:            1500,
+				IsReusePort:    true,
+				IsVerbose:      true,
+			},
+			args: []string{"-t", "300", "-u", "-n", "1024", "--mtu", "1500", "--reuse-port", "-v"},
+		},
+	}
+
+	for _, v := range testData {
+		args := v.conf.GenArgs()
+		if !reflect.DeepEqual(args, v.args) {
+			t.Errorf("wrong args:\n\twant: %v\n\thave: %v\n", v.args, args)
+		}
+	}
+}
+
+func TestClientConfigGenLibevConfig(t *testing.T) {
+	conf := &ClientConfig{
+		Addr:       "0.0.0.0",
+		Port:       "1081",
+		IsUDPRelay: true,
+	}
+	node := &parser.SSRNode{
+		IP:     "10.0.0.1",
+		Port:   443,
+		Passwd: "password",
+		Crypto: "aes-256-cfb",
+		Proto:  "auth_aes128_md5",
+		Minx:   "tls1.2_ticket_auth",
+	}
+
+	data, err := conf.GenLibevConfig(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := &libevConfig{}
+	if err := json.Unmarshal(data, res); err != nil {
+		t.Fatal(err)
+	}
+	want := &libevConfig{
+		Server:       "10.0.0.1",
+		ServerPort:   443,
+		Password:     "password",
+		Method:       "aes-256-cfb",
+		Protocol:     "auth_aes128_md5",
+		Obfs:         "tls1.2_ticket_auth",
+		LocalAddress: "0.0.0.0",
+		LocalPort:    1081,
+		Timeout:      defaultTimeout,
+		Mode:         "tcp_and_udp",
+	}
+	if *res != *want {
+		t.Errorf("wrong libev config:\n\twant: %v\n\thave: %v\n", *want, *res)
+	}
+}
@@ -0,0 +1,37 @@
+package libevclient
+
+import (
+	"log"
+
+	"schannel-qt5/config"
+	"schannel-qt5/ssr"
+	"schannel-qt5/systemd"
+)
+
+func init() {
+	// 注册为可用的Launcher，name为libev-systemd，与libev使用相同的配置
+	ssr.SetLuancherMaker("libev-systemd", ssr.LauncherMaker(newLibevSystemdClient))
+	ssr.SetClientConfigMaker("libev-systemd", config.ClientConfigMaker(newClientConfig))
+	ssr.SetBinRequirement("libev-systemd", libevRequirement)
+}
+
+// newLibevSystemdClient 这个函数供ssr.LauncherMaker调用，生成以systemd用户服务运行的ssr.Launcher
+func newLibevSystemdClient(c *config.UserConfig) ssr.Launcher {
+	l, ok := newLibevClient(c).(*LibevClient)
+	if !ok {
+		log.Println("create libev client failed")
+		return nil
+	}
+
+	// 每次启动前重新生成ssr-local的配置文件，由systemd在前台运行
+	execStart := func() ([]string, error) {
+		if err := l.genConfigFile(); err != nil {
+			return nil, err
+		}
+
+		args := []string{l.bin, "-c", l.conf.ConfigFilePath()}
+		return append(args, l.conf.GenArgs()...), nil
+	}
+
+	return systemd.NewLauncher("libev", l.conf, execStart)
+}
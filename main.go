@@ -1,8 +1,13 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/astaxie/beego/orm"
 	_ "github.com/mattn/go-sqlite3"
@@ -10,9 +15,14 @@ import (
 	std_widgets "github.com/therecipe/qt/widgets"
 
 	"schannel-qt5/config"
+	_ "schannel-qt5/goclient"
+	_ "schannel-qt5/httpproxy"
+	_ "schannel-qt5/libevclient"
 	"schannel-qt5/models"
+	_ "schannel-qt5/pac"
 	_ "schannel-qt5/pyclient"
 	"schannel-qt5/ssr"
+	"schannel-qt5/sysproxy"
 	"schannel-qt5/widgets"
 )
 
@@ -21,11 +31,87 @@ const (
 	prefix = "schannel-qt5: "
 )
 
-func init() {
+// parseArgs 解析命令行参数和环境变量，返回覆盖配置文件的配置项、交给Qt的参数、导出导入参数和指定的配置方案
+// 优先级：命令行参数 > SCHANNEL_*环境变量 > 配置文件 > 默认值
+// Qt的参数需要放在"--"之后
+func parseArgs() (config.Overrides, []string, *backupFlags, string) {
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	configPath := fs.String("config", os.Getenv("SCHANNEL_CONFIG"), "path of schannel-qt5.json (env: SCHANNEL_CONFIG)")
+	dbPath := fs.String("db", os.Getenv("SCHANNEL_DB"), "path of the user database (env: SCHANNEL_DB)")
+	profile := fs.String("profile", os.Getenv("SCHANNEL_PROFILE"), "use the named config profile for this run (env: SCHANNEL_PROFILE)")
+	backupCmd := new(backupFlags)
+	backupCmd.register(fs)
+	flags := make(config.Overrides)
+	flags.RegisterFlags(fs)
+	fs.Parse(os.Args[1:])
+
+	stderr := log.New(os.Stderr, prefix, 0)
+	if err := config.SetConfigPath(*configPath); err != nil {
+		stderr.Fatalln("--config:", err)
+	}
+	if err := models.SetDBPath(*dbPath); err != nil {
+		stderr.Fatalln("--db:", err)
+	}
+
+	overrides := config.EnvOverrides(os.Environ())
+	overrides.Merge(flags)
+	return overrides, append([]string{os.Args[0]}, fs.Args()...), backupCmd, *profile
+}
+
+// loadConfig 读取配置，首次运行时由设置向导生成配置文件
+// 配置无法读取时询问用户是否运行设置向导，用户选择退出时返回false
+func loadConfig(conf *config.UserConfig, profile string, profileErr error) bool {
+	switch profileErr {
+	case nil:
+	case config.ErrProfileNotFound:
+		// 由设置向导新建指定的配置方案
+		if !widgets.AskSetupWizard(profileErr) {
+			return false
+		}
+		if err := config.CreateProfile(profile); err != nil {
+			widgets.ShowConfigError(err)
+			return false
+		}
+	default:
+		widgets.ShowConfigError(profileErr)
+		return false
+	}
+
+	for {
+		exists, err := config.ConfigExists()
+		if err == nil && exists {
+			if err = conf.LoadConfig(); err == nil {
+				return true
+			}
+		}
+		if err != nil {
+			if !widgets.AskSetupWizard(err) {
+				return false
+			}
+			// 设置向导会覆盖无法读取的配置文件，先进行备份
+			if _, err := config.BackupConfig(); err != nil && !os.IsNotExist(err) {
+				widgets.ShowConfigError(err)
+				return false
+			}
+		}
+
+		// 首次运行或者配置无法读取时由设置向导生成配置文件，用户取消时退出
+		wizard := widgets.NewSetupWizard2(conf)
+		if wizard.Exec() != int(std_widgets.QDialog__Accepted) {
+			return false
+		}
+	}
+}
+
+// initDB 注册并同步用户数据库
+func initDB() {
 	dbPath, err := models.GetDBPath()
 	if err != nil {
 		panic(err)
 	}
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		panic(err)
+	}
 	orm.Debug = false
 	orm.RegisterDataBase("default", "sqlite3", dbPath)
 	err = orm.RunSyncdb("default", false, false)
@@ -35,15 +121,30 @@ func init() {
 }
 
 func main() {
-	app := std_widgets.NewQApplication(len(os.Args), os.Args)
+	overrides, qtArgs, backupCmd, profile := parseArgs()
+	var profileErr error
+	if profile != "" {
+		profileErr = config.UseProfile(profile)
+	}
+	// 导出和导入在打开数据库之前执行，完成后退出
+	stderr := log.New(os.Stderr, prefix, 0)
+	if backupCmd.requested() && profileErr != nil {
+		stderr.Fatalln("--profile:", profileErr)
+	}
+	if ran, err := backupCmd.run(); err != nil {
+		stderr.Fatalln(err)
+	} else if ran {
+		return
+	}
+	initDB()
+
+	app := std_widgets.NewQApplication(len(qtArgs), qtArgs)
 	app.SetAttribute(core.Qt__AA_EnableHighDpiScaling, true)
 
-	// 初始化用户配置
-	conf := &config.UserConfig{}
-	conf.SSRClientConfig = ssr.NewClientConfig("python")
-	err := conf.LoadConfig()
-	if err != nil {
-		panic(err)
+	// 初始化用户配置，ClientConfig根据配置的客户端类型生成
+	conf := &config.UserConfig{Overrides: overrides}
+	if !loadConfig(conf, profile, profileErr) {
+		return
 	}
 
 	var logger *log.Logger
@@ -62,13 +163,66 @@ func main() {
 		// 未指定logfile时使用stdout替代
 		logger = log.New(os.Stdout, prefix, log.LstdFlags|log.Lshortfile)
 	}
+	// ssr客户端的输出也写入程序日志
+	ssr.SetLogger(logger)
+
+	// 上次运行时没有恢复就退出的系统代理
+	if n, err := sysproxy.RestoreSaved(); err != nil {
+		logger.Println("restore saved system proxy:", err)
+	} else if n != 0 {
+		logger.Printf("已恢复上次运行时设置的%d个系统代理\n", n)
+	}
+	// 被信号终止时app.Exec不会返回，需要在这里恢复系统代理
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		sig := <-sigs
+		logger.Println("received signal:", sig)
+		if err := sysproxy.RestoreAll(); err != nil {
+			logger.Println("restore system proxy:", err)
+		}
+		os.Exit(1)
+	}()
+
+	// 旧版本的配置文件已被升级，原文件备份在同一目录
+	if len(conf.MigratedKeys) != 0 {
+		changes := make([]string, 0, len(conf.MigratedKeys))
+		for _, change := range conf.MigratedKeys {
+			logger.Println("config migrated:", change)
+			changes = append(changes, change.String())
+		}
+		widgets.ShowNotification("配置升级", "配置文件已升级，变化的配置项:\n"+strings.Join(changes, "\n"), "", 0)
+	}
 
 	// 获取用户数据库连接
 	db := orm.NewOrm()
 
+	// 记住的密码保存在Secret Service或加密文件中，旧版本保存在数据库中的密码在这里迁移
+	store, err := widgets.OpenCredentialStore(conf, logger)
+	if err != nil {
+		logger.Println("open credential store:", err)
+		widgets.ShowNotification("记住密码", "无法打开密码保存方式，本次不会记住密码", "", -1)
+	}
+	if store != nil {
+		models.SetCredentialStore(store)
+		// 无法迁移的账号被跳过，数据库中保留旧的密文
+		n, err := models.MigrateCredentials(db)
+		if err != nil {
+			logger.Println("migrate credentials:", err)
+		}
+		if n != 0 {
+			logger.Printf("已将%d个账号的密码迁移到新的保存方式\n", n)
+		}
+	}
+
 	// 初始化GUI
 	mainWindow := widgets.NewMainWidget2(conf, logger, db)
 	mainWindow.Show()
 
 	app.Exec()
+
+	// 客户端可能在程序退出后继续运行，系统代理需要在退出前恢复
+	if err := sysproxy.RestoreAll(); err != nil {
+		logger.Println("restore system proxy:", err)
+	}
 }
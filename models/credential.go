@@ -0,0 +1,130 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/astaxie/beego/orm"
+
+	"schannel-qt5/credential"
+)
+
+// StoredPasswd 数据库中表示密码保存在CredentialStore中的标记
+// 旧版本在数据库中保存base64编码的密文，不会与标记相同
+const StoredPasswd = "*credential-store*"
+
+// ErrNoCredentialStore 没有可用的CredentialStore，无法记住密码
+var ErrNoCredentialStore = errors.New("no credential store to save the password")
+
+// CredentialStore 保存账号密码的后端
+type CredentialStore interface {
+	// Get 返回user的密码，没有保存时返回credential.ErrNotFound
+	Get(user string) (string, error)
+	// Set 保存user的密码，已有的密码会被替换
+	Set(user, password string) error
+	// Delete 删除user的密码，没有保存时不返回错误
+	Delete(user string) error
+}
+
+// 当前使用的CredentialStore，为nil时不记住密码
+var credentialStore CredentialStore
+
+// SetCredentialStore 设置保存密码的后端，store为nil时不记住密码
+func SetCredentialStore(store CredentialStore) {
+	credentialStore = store
+}
+
+// isLegacyPasswd passwd是否为旧版本保存在数据库中的密文
+func isLegacyPasswd(passwd string) bool {
+	return passwd != "" && passwd != StoredPasswd
+}
+
+// readPassword 返回u保存的密码，旧版本的密文会被迁移到CredentialStore
+func readPassword(db orm.Ormer, u *User) (string, error) {
+	if isLegacyPasswd(u.Passwd) {
+		return migratePassword(db, u)
+	}
+	if u.Passwd == "" || credentialStore == nil {
+		return "", nil
+	}
+
+	password, err := credentialStore.Get(u.Name)
+	if err == credential.ErrNotFound {
+		// 后端中的密码已被删除，视为没有记住密码
+		return "", nil
+	}
+	return password, err
+}
+
+// migratePassword 解密u中旧版本的密文，保存到CredentialStore后数据库中只保留标记
+// 没有CredentialStore时只返回解密后的密码
+func migratePassword(db orm.Ormer, u *User) (string, error) {
+	password, err := decryptPassword(u.Name, u.Passwd)
+	if err != nil {
+		return "", err
+	}
+	if credentialStore == nil {
+		return password, nil
+	}
+
+	if err := credentialStore.Set(u.Name, password); err != nil {
+		return "", err
+	}
+	_, err = db.QueryTable(u).Filter("Name", u.Name).Update(orm.Params{
+		"Passwd": StoredPasswd,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	u.Passwd = StoredPasswd
+	return password, nil
+}
+
+// MigrateError 无法迁移密码的账号及原因，这些账号被跳过，数据库中保留旧的密文
+type MigrateError map[string]error
+
+func (e MigrateError) Error() string {
+	users := make([]string, 0, len(e))
+	for user := range e {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+
+	msgs := make([]string, 0, len(users))
+	for _, user := range users {
+		msgs = append(msgs, user+": "+e[user].Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// MigrateCredentials 将所有旧版本保存在数据库中的密码迁移到CredentialStore，返回迁移的账号数
+// 无法迁移的账号被跳过，不影响其他账号，这些账号通过MigrateError返回
+func MigrateCredentials(db orm.Ormer) (int, error) {
+	if credentialStore == nil {
+		return 0, ErrNoCredentialStore
+	}
+
+	users, err := GetAllUsers(db)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	failed := make(MigrateError)
+	for _, u := range users {
+		if !isLegacyPasswd(u.Passwd) {
+			continue
+		}
+		if _, err := migratePassword(db, u); err != nil {
+			failed[u.Name] = err
+			continue
+		}
+		count++
+	}
+	if len(failed) != 0 {
+		return count, failed
+	}
+
+	return count, nil
+}
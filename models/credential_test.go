@@ -0,0 +1,142 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/astaxie/beego/orm"
+
+	"schannel-qt5/credential"
+)
+
+// memoryStore 测试使用的CredentialStore，密码保存在内存中
+type memoryStore map[string]string
+
+func newMemoryStore() memoryStore {
+	return make(memoryStore)
+}
+
+func (m memoryStore) Get(user string) (string, error) {
+	password, ok := m[user]
+	if !ok {
+		return "", credential.ErrNotFound
+	}
+	return password, nil
+}
+
+func (m memoryStore) Set(user, password string) error {
+	m[user] = password
+	return nil
+}
+
+func (m memoryStore) Delete(user string) error {
+	delete(m, user)
+	return nil
+}
+
+// insertLegacyUser 插入旧版本加密保存密码的用户
+func insertLegacyUser(t *testing.T, db orm.Ormer, user, password string) {
+	crypted, err := encryptPassword(user, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Insert(&User{Name: user, Passwd: crypted}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoredPassword(t *testing.T) {
+	db, users := initUserDB(t)
+	store := credentialStore.(memoryStore)
+
+	for _, v := range users {
+		u := &User{Name: v.Name}
+		if err := db.Read(u); err != nil {
+			t.Fatal(err)
+		}
+		// 数据库中只保存标记
+		if v.Passwd != "" && (u.Passwd != StoredPasswd || store[v.Name] != v.Passwd) {
+			t.Errorf("密码没有保存在CredentialStore中: %v, %v\n", u.Passwd, store[v.Name])
+		}
+	}
+
+	// 不记住密码时删除后端中的密码
+	if err := SetUserPassword(db, "example", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store["example"]; ok {
+		t.Errorf("不记住密码时没有删除CredentialStore中的密码\n")
+	}
+	if err := DelUser(db, "test@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store["test@example.com"]; ok {
+		t.Errorf("删除用户时没有删除CredentialStore中的密码\n")
+	}
+}
+
+func TestMigrateCredentials(t *testing.T) {
+	db, _ := initUserDB(t)
+	store := credentialStore.(memoryStore)
+	insertLegacyUser(t, db, "legacy1", "password1")
+	insertLegacyUser(t, db, "legacy2", "password2")
+
+	// 读取时迁移
+	u, err := GetUserPassword(db, "legacy1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Passwd != "password1" || store["legacy1"] != "password1" {
+		t.Errorf("读取时迁移错误: %v, %v\n", u.Passwd, store["legacy1"])
+	}
+
+	n, err := MigrateCredentials(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || store["legacy2"] != "password2" {
+		t.Errorf("迁移错误: %d, %v\n", n, store["legacy2"])
+	}
+	for _, name := range []string{"legacy1", "legacy2"} {
+		raw := &User{Name: name}
+		if err := db.Read(raw); err != nil {
+			t.Fatal(err)
+		}
+		if raw.Passwd != StoredPasswd {
+			t.Errorf("数据库中仍然保存着旧的密文: %s\n", raw.Passwd)
+		}
+	}
+
+	// 无法解密的账号被跳过，不影响其他账号
+	if _, err := db.Insert(&User{Name: "broken", Passwd: "not a cipher text"}); err != nil {
+		t.Fatal(err)
+	}
+	insertLegacyUser(t, db, "legacy4", "password4")
+	n, err = MigrateCredentials(db)
+	if failed, ok := err.(MigrateError); !ok || len(failed) != 1 || failed["broken"] == nil {
+		t.Errorf("无法迁移的账号没有被返回: %v\n", err)
+	}
+	if n != 1 || store["legacy4"] != "password4" {
+		t.Errorf("跳过无法迁移的账号后没有继续迁移: %d, %v\n", n, store["legacy4"])
+	}
+
+	// 没有CredentialStore时仍然可以读取旧的密码，但不会迁移
+	SetCredentialStore(nil)
+	defer SetCredentialStore(store)
+	insertLegacyUser(t, db, "legacy3", "password3")
+	if u, err := GetUserPassword(db, "legacy3"); err != nil || u.Passwd != "password3" {
+		t.Errorf("want: password3, have: %v, %v\n", u, err)
+	}
+	if err := SetUserPassword(db, "nostore", "password"); err != ErrNoCredentialStore {
+		t.Errorf("没有CredentialStore时应该返回ErrNoCredentialStore: %v\n", err)
+	}
+	// 无法保存新密码时保留原来的标记
+	if err := SetUserPassword(db, "legacy1", "password"); err != ErrNoCredentialStore {
+		t.Errorf("没有CredentialStore时应该返回ErrNoCredentialStore: %v\n", err)
+	}
+	if raw := (&User{Name: "legacy1"}); db.Read(raw) != nil || raw.Passwd != StoredPasswd {
+		t.Errorf("无法保存新密码时原来的标记被覆盖: %v\n", raw.Passwd)
+	}
+	if _, err := MigrateCredentials(db); err != ErrNoCredentialStore {
+		t.Errorf("没有CredentialStore时应该返回ErrNoCredentialStore: %v\n", err)
+	}
+}
@@ -6,8 +6,15 @@ import (
 	"crypto/cipher"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 )
 
+// errLegacyPasswd 数据库中的旧版本密文无法解密
+var errLegacyPasswd = errors.New("invalid legacy encrypted password")
+
+// 以下是旧版本在数据库中保存密码使用的加密方式
+// key只由用户名生成且同时被用作IV，现在只用于将旧的密码迁移到CredentialStore
+
 // genKey 根据用户名生成key
 func genKey(user string) []byte {
 	salt := user[:len(user)/2] + "models"
@@ -47,8 +54,14 @@ func decryptPassword(user string, crypted string) (string, error) {
 	}
 
 	n := len(unbase)
+	if n == 0 || n%aes.BlockSize != 0 {
+		return "", errLegacyPasswd
+	}
 	origData := make([]byte, n)
 	blockMode.CryptBlocks(origData, unbase)
+	if padding := int(origData[n-1]); padding == 0 || padding > n {
+		return "", errLegacyPasswd
+	}
 	origData = PKCS5UnPadding(origData)
 	return string(origData), nil
 }
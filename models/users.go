@@ -1,18 +1,19 @@
 package models
 
 import (
-	"os"
 	"path/filepath"
 
 	"github.com/astaxie/beego/orm"
 	_ "github.com/mattn/go-sqlite3"
 
-	"schannel-qt5/config"
+	"schannel-qt5/xdg"
 )
 
 const (
-	// 数据库存放路径
-	databasePath = ".local/share/schannel-users.db"
+	// 数据库文件名，位于$XDG_DATA_HOME/schannel-qt5下
+	databaseName = "schannel-users.db"
+	// 旧版本使用的数据库路径，相对于$HOME
+	legacyDatabasePath = ".local/share/schannel-users.db"
 )
 
 // 注册模型
@@ -20,14 +21,42 @@ func init() {
 	orm.RegisterModel(&User{})
 }
 
-// GetDBPath 获取数据库存放路径
+// 通过SetDBPath指定的数据库路径
+var dbPathOverride string
+
+// SetDBPath 使用path代替默认的数据库路径，为空时恢复默认路径
+func SetDBPath(path string) error {
+	if path != "" && !filepath.IsAbs(path) {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		path = abs
+	}
+
+	dbPathOverride = path
+	return nil
+}
+
+// GetDBPath 获取数据库存放路径，旧位置的数据库存在时会先被移动到新位置
 func GetDBPath() (string, error) {
-	home, err := os.UserHomeDir()
+	if dbPathOverride != "" {
+		return dbPathOverride, nil
+	}
+
+	path, err := xdg.DataFile(databaseName)
 	if err != nil {
-		return "", config.ErrHOME
+		return "", err
+	}
+	legacy, err := xdg.HomeFile(legacyDatabasePath)
+	if err != nil {
+		return "", err
+	}
+	if _, err := xdg.Migrate(legacy, path); err != nil {
+		return "", err
 	}
 
-	return filepath.Join(home, databasePath), nil
+	return path, nil
 }
 
 // User 用户表，将和使用量表关联
@@ -37,6 +66,7 @@ type User struct {
 }
 
 // GetUserPassword 获取用户名以及密码
+// 密码从CredentialStore中读取，旧版本保存在数据库中的密码会被迁移
 func GetUserPassword(db orm.Ormer, user string) (*User, error) {
 	u := &User{Name: user}
 
@@ -44,40 +74,46 @@ func GetUserPassword(db orm.Ormer, user string) (*User, error) {
 		return nil, err
 	}
 
-	// 密码解密
-	if u.Passwd != "" {
-		data, err := decryptPassword(u.Name, u.Passwd)
-		if err != nil {
-			return nil, err
-		}
-		u.Passwd = data
+	password, err := readPassword(db, u)
+	if err != nil {
+		return nil, err
 	}
+	u.Passwd = password
 
 	return u, nil
 }
 
-// SetUserPassword 将用户名密码保存
-// password为nil表示不记住密码
+// SetUserPassword 将用户名保存，密码保存在CredentialStore中
+// password为空表示不记住密码；没有CredentialStore时只保存用户名并返回ErrNoCredentialStore
 func SetUserPassword(db orm.Ormer, user string, password string) error {
-	u := &User{
-		Name:   user,
-		Passwd: password,
-	}
+	u := &User{Name: user}
 
-	if u.Passwd != "" {
-		data, err := encryptPassword(u.Name, u.Passwd)
-		if err != nil {
+	var storeErr error
+	if password != "" {
+		if credentialStore == nil {
+			storeErr = ErrNoCredentialStore
+		} else if err := credentialStore.Set(u.Name, password); err != nil {
 			return err
+		} else {
+			u.Passwd = StoredPasswd
 		}
-		u.Passwd = data
 	}
 
 	if db.QueryTable(u).Filter("Name", u.Name).Exist() {
+		// 无法保存新密码时保留原来记住的密码
+		if storeErr != nil {
+			return storeErr
+		}
 		old := &User{Name: u.Name}
 		db.QueryTable(old).Filter("Name", old.Name).One(old)
-		// 和旧值一样，不更新，返回nil
+		// 和旧值一样，不更新
 		if u.Passwd == old.Passwd {
-			return nil
+			return storeErr
+		}
+		if old.Passwd == StoredPasswd && credentialStore != nil {
+			if err := credentialStore.Delete(u.Name); err != nil {
+				return err
+			}
 		}
 		_, err := db.QueryTable(u).Filter("Name", u.Name).Update(orm.Params{
 			"Passwd": u.Passwd,
@@ -85,14 +121,14 @@ func SetUserPassword(db orm.Ormer, user string, password string) error {
 		if err != nil {
 			return err
 		}
-		return nil
+		return storeErr
 	}
 
 	if _, err := db.Insert(u); err != nil {
 		return err
 	}
 
-	return nil
+	return storeErr
 }
 
 // GetAllUsers 返回所有user，包括未
@@ -106,9 +142,14 @@ func GetAllUsers(db orm.Ormer) ([]*User, error) {
 	return users, nil
 }
 
-// DelPassword 将指定user的password设置为null
+// DelPassword 将指定user的password设置为null，同时删除CredentialStore中的密码
 func DelPassword(db orm.Ormer, user string) error {
 	u := &User{Name: user}
+	if credentialStore != nil {
+		if err := credentialStore.Delete(u.Name); err != nil {
+			return err
+		}
+	}
 
 	_, err := db.QueryTable(u).Filter("Name", u.Name).Update(orm.Params{
 		"Passwd": "",
@@ -120,9 +161,14 @@ func DelPassword(db orm.Ormer, user string) error {
 	return nil
 }
 
-// DelUser 删除名字与name相同的User，同时会删除UserAmount记录
+// DelUser 删除名字与name相同的User，同时会删除UserAmount记录和CredentialStore中的密码
 func DelUser(db orm.Ormer, name string) error {
 	user := &User{Name: name}
+	if credentialStore != nil {
+		if err := credentialStore.Delete(user.Name); err != nil {
+			return err
+		}
+	}
 	_, err := db.QueryTable(user).Filter("Name", user.Name).Delete()
 	return err
 }
@@ -20,6 +20,7 @@ func TestMain(m *testing.M) {
 	orm.Debug = true
 	orm.RegisterDataBase("default", "sqlite3", dbPath)
 	orm.RegisterDataBase("testAmount", "sqlite3", amountPath)
+	SetCredentialStore(newMemoryStore())
 	os.Exit(m.Run())
 }
 
@@ -171,15 +172,15 @@ func TestGetDBPath(t *testing.T) {
 	}{
 		{
 			home: "/home/test",
-			res:  "/home/test/" + databasePath,
+			res:  "/home/test/.local/share/schannel-qt5/" + databaseName,
 		},
 		{
 			home: "/home/user1/",
-			res:  "/home/user1/" + databasePath,
+			res:  "/home/user1/.local/share/schannel-qt5/" + databaseName,
 		},
 		{
 			home: "/home/用户/",
-			res:  "/home/用户/" + databasePath,
+			res:  "/home/用户/.local/share/schannel-qt5/" + databaseName,
 		},
 	}
 
@@ -0,0 +1,89 @@
+package pac
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+var (
+	// ErrNoURL 没有设置规则列表的下载地址
+	ErrNoURL = errors.New("rule list url is not set")
+	// ErrEmptyList 下载的规则列表中没有可用的规则
+	ErrEmptyList = errors.New("rule list has no rules")
+)
+
+const (
+	// 规则列表的最大长度
+	maxListSize = 16 << 20
+)
+
+// DownloadGFWList 通过socksAddr上的socks5代理下载GFWList并保存到dest
+// socksAddr为空时直接连接，内容无法解析时不会覆盖dest
+func DownloadGFWList(url, socksAddr, dest string, timeout time.Duration) (*RuleSet, error) {
+	if url == "" {
+		return nil, ErrNoURL
+	}
+
+	client := &http.Client{Timeout: timeout}
+	if socksAddr != "" {
+		dialer, err := proxy.SOCKS5("tcp", socksAddr, nil, &net.Dialer{Timeout: timeout})
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = &http.Transport{Dial: dialer.Dial}
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download %s: %s", url, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxListSize))
+	if err != nil {
+		return nil, err
+	}
+	rules, err := ParseGFWList(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	} else if rules.Len() == 0 {
+		return nil, ErrEmptyList
+	}
+
+	if err := writeFile(dest, data); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// writeFile 先写入临时文件再替换dest，避免PAC服务读到不完整的文件
+func writeFile(dest string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(dest), filepath.Base(dest)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), dest)
+}
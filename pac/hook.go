@@ -0,0 +1,99 @@
+package pac
+
+import (
+	"strings"
+
+	"schannel-qt5/config"
+	"schannel-qt5/ssr"
+)
+
+func init() {
+	// 设置了pac端口时随客户端一起启动
+	ssr.SetHookMaker("pac", ssr.HookMaker(newHook))
+}
+
+// Hook 随ssr客户端启动和停止的PAC服务
+type Hook struct {
+	conf   *config.UserConfig
+	server *Server
+}
+
+// newHook 这个函数供ssr.HookMaker调用，没有启用PAC服务时返回nil
+func newHook(c *config.UserConfig) ssr.Hook {
+	if !c.PAC.Enabled() {
+		return nil
+	}
+
+	return &Hook{conf: c}
+}
+
+// Start 启动PAC服务
+func (h *Hook) Start() error {
+	if h.server != nil {
+		return nil
+	}
+
+	files, err := ListFiles(&h.conf.PAC)
+	if err != nil {
+		return err
+	}
+	server, err := NewServer(h.conf.PAC.HostPort(), ProxyString(h.conf), files)
+	if err != nil {
+		return err
+	}
+	h.server = server
+	go h.server.Serve()
+
+	return nil
+}
+
+// Stop 停止PAC服务
+func (h *Hook) Stop() error {
+	if h.server == nil {
+		return ErrNotRunning
+	}
+
+	err := h.server.Close()
+	h.server = nil
+	return err
+}
+
+// ListFiles 返回配置中的规则列表，用户规则在最后
+func ListFiles(c *config.PACConfig) ([]ListFile, error) {
+	files := make([]ListFile, 0, len(c.RuleFiles)+2)
+	if !c.GFWListPath.IsEmpty() {
+		path, err := c.GFWListPath.AbsPath()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, ListFile{Path: path, GFWList: true})
+	}
+
+	for i := range c.RuleFiles {
+		path, err := c.RuleFiles[i].AbsPath()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, ListFile{Path: path})
+	}
+
+	path, err := c.UserRuleAbsPath()
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, ListFile{Path: path})
+
+	return files, nil
+}
+
+// ProxyString 返回PAC中匹配代理规则时使用的代理
+// 启用了内置http代理时，将其作为不支持socks的程序的备选
+func ProxyString(c *config.UserConfig) string {
+	socksAddr := ssr.SocksAddr(c.SSRClientConfig)
+	proxies := []string{"SOCKS5 " + socksAddr, "SOCKS " + socksAddr}
+	if c.HTTPProxyEnabled() {
+		proxies = append(proxies, "PROXY "+c.HTTPProxyHostPort())
+	}
+
+	return strings.Join(proxies, "; ")
+}
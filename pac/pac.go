@@ -0,0 +1,121 @@
+package pac
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"text/template"
+)
+
+// pacTemplate 生成的PAC文件
+// 域名逐级去除前缀后查找，网段规则只在设置了网段时才解析域名
+// 未匹配任何规则的请求直连
+var pacTemplate = template.Must(template.New("pac").Parse(`// generated by schannel-qt5
+var proxy = {{.Proxy}};
+var direct = "DIRECT";
+var proxyDomains = {{.ProxyDomains}};
+var directDomains = {{.DirectDomains}};
+var proxyNets = {{.ProxyNets}};
+var directNets = {{.DirectNets}};
+
+function matchDomain(host, domains) {
+	var suffix = host;
+	while (true) {
+		if (Object.prototype.hasOwnProperty.call(domains, suffix)) {
+			return true;
+		}
+		var pos = suffix.indexOf(".");
+		if (pos < 0) {
+			return false;
+		}
+		suffix = suffix.substring(pos + 1);
+	}
+}
+
+function matchNet(ip, nets) {
+	for (var i = 0; i < nets.length; i++) {
+		if (isInNet(ip, nets[i][0], nets[i][1])) {
+			return true;
+		}
+	}
+	return false;
+}
+
+function FindProxyForURL(url, host) {
+	host = host.toLowerCase();
+	if (isPlainHostName(host) || matchDomain(host, directDomains)) {
+		return direct;
+	}
+	if (matchDomain(host, proxyDomains)) {
+		return proxy;
+	}
+	if (proxyNets.length + directNets.length > 0) {
+		var ip = dnsResolve(host);
+		if (ip && matchNet(ip, directNets)) {
+			return direct;
+		}
+		if (ip && matchNet(ip, proxyNets)) {
+			return proxy;
+		}
+	}
+	return direct;
+}
+`))
+
+// Generate 根据规则生成PAC文件，proxy为匹配代理规则时返回的代理字符串
+// 例如"SOCKS5 127.0.0.1:1080; SOCKS 127.0.0.1:1080"
+// PAC中的isInNet只支持IPv4，IPv6网段会被忽略
+func Generate(rules *RuleSet, proxy string) ([]byte, error) {
+	var data struct {
+		Proxy, ProxyDomains, DirectDomains, ProxyNets, DirectNets string
+	}
+
+	values := []struct {
+		dst *string
+		v   interface{}
+	}{
+		{&data.Proxy, proxy},
+		{&data.ProxyDomains, domainSet(rules.ProxyDomains)},
+		{&data.DirectDomains, domainSet(rules.DirectDomains)},
+		{&data.ProxyNets, ipv4Nets(rules.ProxyNets)},
+		{&data.DirectNets, ipv4Nets(rules.DirectNets)},
+	}
+	for _, value := range values {
+		b, err := json.Marshal(value.v)
+		if err != nil {
+			return nil, err
+		}
+		*value.dst = string(b)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := pacTemplate.Execute(buf, data); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// domainSet 将域名列表转换为JS对象使用的map
+func domainSet(domains []string) map[string]int {
+	res := make(map[string]int, len(domains))
+	for _, d := range domains {
+		res[d] = 1
+	}
+
+	return res
+}
+
+// ipv4Nets 将IPv4网段转换为isInNet使用的[ip, mask]
+func ipv4Nets(nets []*net.IPNet) [][2]string {
+	res := make([][2]string, 0, len(nets))
+	for _, n := range nets {
+		ip := n.IP.To4()
+		if ip == nil || len(n.Mask) != net.IPv4len {
+			continue
+		}
+		res = append(res, [2]string{ip.String(), net.IP(n.Mask).String()})
+	}
+
+	return res
+}
@@ -0,0 +1,263 @@
+package pac
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strings"
+
+	"schannel-qt5/config"
+)
+
+// RuleError 规则列表中无法解析的行
+type RuleError struct {
+	// 行号，从1开始
+	Line int
+	Text string
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("line %d: invalid rule %q", e.Line, e.Text)
+}
+
+// RuleSet 需要代理和直连的域名与网段
+// 域名匹配自身和所有子域名，直连规则优先于代理规则
+type RuleSet struct {
+	ProxyDomains  []string
+	DirectDomains []string
+	ProxyNets     []*net.IPNet
+	DirectNets    []*net.IPNet
+
+	// 已经添加的规则，用于去重
+	seen map[string]struct{}
+}
+
+// add 记录规则，规则已经存在时返回false
+func (r *RuleSet) add(rule string, direct bool) bool {
+	if direct {
+		rule = "@@" + rule
+	}
+	if r.seen == nil {
+		r.seen = make(map[string]struct{})
+	}
+	if _, ok := r.seen[rule]; ok {
+		return false
+	}
+
+	r.seen[rule] = struct{}{}
+	return true
+}
+
+// AddDomain 添加域名规则，已经存在的规则会被忽略
+func (r *RuleSet) AddDomain(domain string, direct bool) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if !r.add(domain, direct) {
+		return
+	}
+
+	if direct {
+		r.DirectDomains = append(r.DirectDomains, domain)
+	} else {
+		r.ProxyDomains = append(r.ProxyDomains, domain)
+	}
+}
+
+// AddNet 添加网段规则，已经存在的规则会被忽略
+func (r *RuleSet) AddNet(ipNet *net.IPNet, direct bool) {
+	if !r.add(ipNet.String(), direct) {
+		return
+	}
+
+	if direct {
+		r.DirectNets = append(r.DirectNets, ipNet)
+	} else {
+		r.ProxyNets = append(r.ProxyNets, ipNet)
+	}
+}
+
+// Merge 将other中的规则加入r
+func (r *RuleSet) Merge(other *RuleSet) {
+	for _, d := range other.ProxyDomains {
+		r.AddDomain(d, false)
+	}
+	for _, d := range other.DirectDomains {
+		r.AddDomain(d, true)
+	}
+	for _, n := range other.ProxyNets {
+		r.AddNet(n, false)
+	}
+	for _, n := range other.DirectNets {
+		r.AddNet(n, true)
+	}
+}
+
+// Len 返回规则的总数
+func (r *RuleSet) Len() int {
+	return len(r.ProxyDomains) + len(r.DirectDomains) + len(r.ProxyNets) + len(r.DirectNets)
+}
+
+// Sort 对规则排序，使生成的PAC文件内容固定
+func (r *RuleSet) Sort() {
+	sort.Strings(r.ProxyDomains)
+	sort.Strings(r.DirectDomains)
+	sortNets(r.ProxyNets)
+	sortNets(r.DirectNets)
+}
+
+func sortNets(nets []*net.IPNet) {
+	sort.Slice(nets, func(i, j int) bool {
+		return nets[i].String() < nets[j].String()
+	})
+}
+
+// ParseRules 解析每行一个规则的列表
+// 规则为域名、IP或者CIDR，以"@@"开头表示直连，以"#"或"!"开头的行为注释
+// 遇到无法解析的行时返回*RuleError
+func ParseRules(r io.Reader) (*RuleSet, error) {
+	return parseRules(r, nil)
+}
+
+// parseRules 解析规则列表，skip不为nil时跳过无法解析的行并交给skip处理
+func parseRules(r io.Reader, skip func(*RuleError)) (*RuleSet, error) {
+	rules := &RuleSet{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' || text[0] == '!' {
+			continue
+		}
+
+		rule := text
+		direct := strings.HasPrefix(rule, "@@")
+		if direct {
+			rule = rule[2:]
+		}
+
+		if ipNet := parseNet(rule); ipNet != nil {
+			rules.AddNet(ipNet, direct)
+		} else if isDomain(rule) {
+			rules.AddDomain(rule, direct)
+		} else if skip != nil {
+			skip(&RuleError{Line: line, Text: text})
+		} else {
+			return nil, &RuleError{Line: line, Text: text}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// ParseGFWList 解析base64编码的GFWList(AutoProxy格式)
+// 只保留可以转换为域名的规则，正则表达式和通配符规则会被忽略
+func ParseGFWList(r io.Reader) (*RuleSet, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = decodeGFWList(data)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &RuleSet{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '!' || text[0] == '[' {
+			continue
+		}
+
+		direct := strings.HasPrefix(text, "@@")
+		if direct {
+			text = text[2:]
+		}
+		if domain := gfwListDomain(text); domain != "" {
+			rules.AddDomain(domain, direct)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// decodeGFWList 解码base64编码的GFWList，未编码的数据原样返回
+func decodeGFWList(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[AutoProxy")) {
+		return data, nil
+	}
+
+	// base64数据可能按行分割
+	data = bytes.Join(bytes.Fields(data), nil)
+	res := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+	n, err := base64.StdEncoding.Decode(res, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return res[:n], nil
+}
+
+// gfwListDomain 返回规则对应的域名，无法转换时返回空字符串
+func gfwListDomain(rule string) string {
+	// 正则表达式
+	if strings.HasPrefix(rule, "/") {
+		return ""
+	}
+
+	switch {
+	case strings.HasPrefix(rule, "||"):
+		rule = rule[2:]
+	case strings.HasPrefix(rule, "|"):
+		rule = rule[1:]
+		if i := strings.Index(rule, "://"); i != -1 {
+			rule = rule[i+3:]
+		}
+	}
+	rule = strings.TrimPrefix(rule, ".")
+
+	// 去除路径和端口
+	if i := strings.IndexAny(rule, "/^"); i != -1 {
+		rule = rule[:i]
+	}
+	if host, _, err := net.SplitHostPort(rule); err == nil {
+		rule = host
+	}
+
+	if !isDomain(rule) {
+		return ""
+	}
+	return rule
+}
+
+// parseNet 将IP或CIDR解析为网段，不是IP或CIDR时返回nil
+func parseNet(rule string) *net.IPNet {
+	if _, ipNet, err := net.ParseCIDR(rule); err == nil {
+		return ipNet
+	}
+
+	ip := net.ParseIP(rule)
+	if ip == nil {
+		return nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// isDomain 判断是否为合法的域名，至少需要包含一个"."
+func isDomain(s string) bool {
+	return strings.Contains(strings.TrimSuffix(s, "."), ".") && config.IsHostname(s)
+}
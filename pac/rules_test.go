@@ -0,0 +1,77 @@
+package pac
+
+import (
+	"testing"
+
+	"encoding/base64"
+	"reflect"
+	"strings"
+)
+
+func TestParseRules(t *testing.T) {
+	data := `# comment
+example.com
+@@cn.example.com
+Example.COM.
+10.0.0.0/8
+@@192.168.1.1
+2001:db8::/32
+`
+	rules, err := ParseRules(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("解析规则出错: %v\n", err)
+	}
+
+	if !reflect.DeepEqual(rules.ProxyDomains, []string{"example.com"}) {
+		t.Errorf("代理域名错误: %v\n", rules.ProxyDomains)
+	}
+	if !reflect.DeepEqual(rules.DirectDomains, []string{"cn.example.com"}) {
+		t.Errorf("直连域名错误: %v\n", rules.DirectDomains)
+	}
+	if len(rules.ProxyNets) != 2 || rules.ProxyNets[0].String() != "10.0.0.0/8" {
+		t.Errorf("代理网段错误: %v\n", rules.ProxyNets)
+	}
+	if len(rules.DirectNets) != 1 || rules.DirectNets[0].String() != "192.168.1.1/32" {
+		t.Errorf("直连网段错误: %v\n", rules.DirectNets)
+	}
+
+	_, err = ParseRules(strings.NewReader("example.com\nnot a rule\n"))
+	if e, ok := err.(*RuleError); !ok || e.Line != 2 {
+		t.Errorf("无效规则的错误信息不正确: %v\n", err)
+	}
+	// 错误的IPv4地址不会被当作域名
+	if _, err = ParseRules(strings.NewReader("1.2.3.256\n")); err == nil {
+		t.Errorf("错误的IP地址被当作域名\n")
+	}
+}
+
+func TestParseGFWList(t *testing.T) {
+	list := `[AutoProxy 0.2.9]
+! comment
+||google.com
+|https://www.example.org/path
+.twitter.com
+foo.net/bar
+/^https?:\/\/[^\/]+blogspot\.(.*)/
+@@||cn.google.com
+*.wildcard.com
+|http://1.2.3.4
+`
+	want := []string{"google.com", "www.example.org", "twitter.com", "foo.net"}
+	for _, data := range []string{list, base64.StdEncoding.EncodeToString([]byte(list))} {
+		rules, err := ParseGFWList(strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("解析GFWList出错: %v\n", err)
+		}
+		if !reflect.DeepEqual(rules.ProxyDomains, want) {
+			t.Errorf("代理域名错误:\n\twant: %v\n\thave: %v\n", want, rules.ProxyDomains)
+		}
+		if !reflect.DeepEqual(rules.DirectDomains, []string{"cn.google.com"}) {
+			t.Errorf("直连域名错误: %v\n", rules.DirectDomains)
+		}
+	}
+
+	if _, err := ParseGFWList(strings.NewReader("not base64!")); err == nil {
+		t.Errorf("无效的GFWList应该返回错误\n")
+	}
+}
@@ -0,0 +1,163 @@
+package pac
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"schannel-qt5/config"
+	"schannel-qt5/ssr"
+)
+
+// ErrNotRunning PAC服务没有运行
+var ErrNotRunning = errors.New("pac server is not running")
+
+// ListFile 规则列表文件
+type ListFile struct {
+	Path string
+	// 是否为GFWList格式，否则为每行一个规则的列表
+	GFWList bool
+}
+
+// Load 读取并解析规则列表文件
+// 无法解析的规则会被跳过并记录到日志，不影响其他规则
+func (l ListFile) Load() (*RuleSet, error) {
+	f, err := os.Open(l.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if l.GFWList {
+		return ParseGFWList(f)
+	}
+	return parseRules(f, func(e *RuleError) {
+		ssr.Logger().Printf("pac: %s: skip %v\n", l.Path, e)
+	})
+}
+
+// LoadFiles 读取并合并所有规则列表，不存在的文件会被忽略
+func LoadFiles(files []ListFile) (*RuleSet, error) {
+	rules := &RuleSet{}
+	for _, file := range files {
+		r, err := file.Load()
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		rules.Merge(r)
+	}
+	rules.Sort()
+
+	return rules, nil
+}
+
+// Server 提供PAC文件的本地http服务
+// 规则文件被修改后，下一次请求时重新生成PAC文件
+type Server struct {
+	listener net.Listener
+	server   *http.Server
+	proxy    string
+	files    []ListFile
+
+	lock sync.Mutex
+	// 生成pac时各个文件的修改时间
+	modTimes []time.Time
+	pac      []byte
+}
+
+// NewServer 在addr上监听，proxy为PAC中使用的代理，files为规则列表
+func NewServer(addr, proxy string, files []ListFile) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		listener: listener,
+		proxy:    proxy,
+		files:    files,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(config.PACFileName, s.servePAC)
+	s.server = &http.Server{Handler: mux}
+
+	return s, nil
+}
+
+// Addr 返回实际监听的地址
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Serve 处理请求，直到Close被调用
+func (s *Server) Serve() error {
+	err := s.server.Serve(s.listener)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+
+	return err
+}
+
+// Close 停止服务
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+func (s *Server) servePAC(w http.ResponseWriter, r *http.Request) {
+	data, err := s.PAC()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ns-proxy-autoconfig")
+	w.Write(data)
+}
+
+// PAC 返回生成的PAC文件，规则文件没有变化时使用缓存
+func (s *Server) PAC() ([]byte, error) {
+	modTimes := make([]time.Time, len(s.files))
+	for i, file := range s.files {
+		if info, err := os.Stat(file.Path); err == nil {
+			modTimes[i] = info.ModTime()
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.pac != nil && equalTimes(modTimes, s.modTimes) {
+		return s.pac, nil
+	}
+
+	rules, err := LoadFiles(s.files)
+	if err != nil {
+		return nil, err
+	}
+	pac, err := Generate(rules, s.proxy)
+	if err != nil {
+		return nil, err
+	}
+	s.pac = pac
+	s.modTimes = modTimes
+
+	return s.pac, nil
+}
+
+func equalTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,113 @@
+package pac
+
+import (
+	"testing"
+
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	userRules := filepath.Join(dir, "user.txt")
+	if err := ioutil.WriteFile(userRules, []byte("example.com\n10.0.0.0/8\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files := []ListFile{
+		{Path: filepath.Join(dir, "gfwlist.txt"), GFWList: true},
+		{Path: userRules},
+	}
+	server, err := NewServer("127.0.0.1:0", "SOCKS5 127.0.0.1:1080", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	defer server.Close()
+
+	get := func() string {
+		resp, err := http.Get("http://" + server.Addr().String() + "/proxy.pac")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "application/x-ns-proxy-autoconfig" {
+			t.Errorf("Content-Type错误: %s\n", ct)
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		return string(data)
+	}
+
+	pac := get()
+	for _, s := range []string{`"SOCKS5 127.0.0.1:1080"`, `{"example.com":1}`, `[["10.0.0.0","255.0.0.0"]]`, "FindProxyForURL"} {
+		if !strings.Contains(pac, s) {
+			t.Errorf("PAC中没有%s\n", s)
+		}
+	}
+
+	// 规则文件修改后重新生成
+	later := time.Now().Add(time.Second)
+	ioutil.WriteFile(userRules, []byte("@@example.com\n"), 0644)
+	os.Chtimes(userRules, later, later)
+	if pac := get(); !strings.Contains(pac, `var directDomains = {"example.com":1};`) {
+		t.Errorf("规则文件修改后PAC没有更新\n")
+	}
+
+	// 无法解析的行被跳过
+	later = later.Add(time.Second)
+	ioutil.WriteFile(userRules, []byte("not a rule\nexample.org\n"), 0644)
+	os.Chtimes(userRules, later, later)
+	if pac := get(); !strings.Contains(pac, `{"example.org":1}`) {
+		t.Errorf("规则文件包含无效规则时PAC不正确\n")
+	}
+}
+
+func TestDownloadGFWList(t *testing.T) {
+	list := base64.StdEncoding.EncodeToString([]byte("[AutoProxy]\n||google.com\n"))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/empty" {
+			w.Write([]byte(base64.StdEncoding.EncodeToString([]byte("! nothing\n"))))
+			return
+		}
+		w.Write([]byte(list))
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "pac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dest := filepath.Join(dir, "gfwlist.txt")
+
+	if _, err := DownloadGFWList("", "", dest, time.Second); err != ErrNoURL {
+		t.Errorf("没有URL时应该返回ErrNoURL: %v\n", err)
+	}
+	if _, err := DownloadGFWList(ts.URL+"/empty", "", dest, time.Second); err != ErrEmptyList {
+		t.Errorf("空列表应该返回ErrEmptyList: %v\n", err)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Errorf("下载失败时不应写入文件\n")
+	}
+
+	rules, err := DownloadGFWList(ts.URL, "", dest, time.Second)
+	if err != nil {
+		t.Fatalf("下载出错: %v\n", err)
+	}
+	if len(rules.ProxyDomains) != 1 {
+		t.Errorf("下载的规则错误: %v\n", rules.ProxyDomains)
+	}
+	if data, _ := ioutil.ReadFile(dest); string(data) != list {
+		t.Errorf("保存的文件内容错误\n")
+	}
+}
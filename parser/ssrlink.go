@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ssr链接的协议头
+	ssrLinkPrefix = "ssr://"
+)
+
+var (
+	// ErrNotSSRLink 不是合法的ssr://链接
+	ErrNotSSRLink = errors.New("not a valid ssr link")
+)
+
+// SSRLink 生成节点的ssr://链接
+// 格式为ssr://base64(host:port:protocol:method:obfs:base64(password)/?obfsparam=base64(obfs_param)&protoparam=base64(protocol_param)&remarks=base64(name))
+// base64均为URL safe且不含padding，参数为空时不写入
+func (s *SSRNode) SSRLink() string {
+	password := base64.RawURLEncoding.EncodeToString([]byte(s.Passwd))
+	main := strings.Join([]string{
+		s.IP,
+		strconv.FormatInt(s.Port, 10),
+		s.Proto,
+		s.Crypto,
+		s.Minx,
+		password,
+	}, ":")
+
+	params := make([]string, 0, 3)
+	if s.MinxParam != "" {
+		params = append(params, "obfsparam="+base64.RawURLEncoding.EncodeToString([]byte(s.MinxParam)))
+	}
+	if s.ProtoParam != "" {
+		params = append(params, "protoparam="+base64.RawURLEncoding.EncodeToString([]byte(s.ProtoParam)))
+	}
+	params = append(params, "remarks="+base64.RawURLEncoding.EncodeToString([]byte(s.NodeName)))
+	data := main + "/?" + strings.Join(params, "&")
+
+	return ssrLinkPrefix + base64.RawURLEncoding.EncodeToString([]byte(data))
+}
+
+// ParseSSRLink 解析ssr://链接，返回对应的节点信息
+func ParseSSRLink(link string) (*SSRNode, error) {
+	link = strings.TrimSpace(link)
+	if !strings.HasPrefix(link, ssrLinkPrefix) {
+		return nil, ErrNotSSRLink
+	}
+
+	data, err := decodeSSRBase64(strings.TrimPrefix(link, ssrLinkPrefix))
+	if err != nil {
+		return nil, ErrNotSSRLink
+	}
+
+	main, params := data, ""
+	if i := strings.Index(data, "/?"); i != -1 {
+		main, params = data[:i], data[i+2:]
+	}
+
+	// IPv6地址中也含有`:`，所以从后向前分割出5个字段
+	fields := strings.Split(main, ":")
+	if len(fields) < 6 {
+		return nil, ErrNotSSRLink
+	}
+	n := len(fields)
+	host := strings.Join(fields[:n-5], ":")
+	port, err := strconv.ParseInt(fields[n-5], 10, 64)
+	if err != nil || host == "" {
+		return nil, ErrNotSSRLink
+	}
+	password, err := decodeSSRBase64(fields[n-1])
+	if err != nil {
+		return nil, ErrNotSSRLink
+	}
+
+	node := &SSRNode{
+		Type:   "ssr",
+		IP:     host,
+		Port:   port,
+		Proto:  fields[n-4],
+		Crypto: fields[n-3],
+		Minx:   fields[n-2],
+		Passwd: password,
+	}
+
+	values, err := url.ParseQuery(params)
+	if err != nil {
+		return nil, ErrNotSSRLink
+	}
+	// 参数值同样是base64编码的
+	paramFields := map[string]*string{
+		"remarks":    &node.NodeName,
+		"protoparam": &node.ProtoParam,
+		"obfsparam":  &node.MinxParam,
+	}
+	for key, field := range paramFields {
+		value := values.Get(key)
+		if value == "" {
+			continue
+		}
+		decoded, err := decodeSSRBase64(value)
+		if err != nil {
+			return nil, ErrNotSSRLink
+		}
+		*field = decoded
+	}
+
+	return node, nil
+}
+
+// decodeSSRBase64 解码ssr链接使用的base64数据
+// 兼容URL safe和标准编码，以及是否含有padding
+func decodeSSRBase64(data string) (string, error) {
+	data = strings.TrimRight(data, "=")
+	data = strings.NewReplacer("+", "-", "/", "_").Replace(data)
+	res, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return "", err
+	}
+
+	return string(res), nil
+}
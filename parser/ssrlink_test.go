@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestSSRLink(t *testing.T) {
+	testData := []*SSRNode{
+		{
+			NodeName: "美国_1",
+			Type:     "ssr",
+			IP:       "10.0.0.1",
+			Port:     8080,
+			Passwd:   "abc123",
+			Crypto:   "aes-256-cfb",
+			Proto:    "auth_aes128_md5",
+			Minx:     "tls1.2_ticket_auth",
+		},
+		{
+			NodeName:   "日本_2",
+			Type:       "ssr",
+			IP:         "10.0.0.2",
+			Port:       8443,
+			Passwd:     "abc123",
+			Crypto:     "aes-128-ctr",
+			Proto:      "auth_aes128_sha1",
+			ProtoParam: "1024:key",
+			Minx:       "http_simple",
+			MinxParam:  "a.example.com,b.example.com#User-Agent: test",
+		},
+		{
+			NodeName: "",
+			Type:     "ssr",
+			IP:       "2001:db8::1",
+			Port:     443,
+			Passwd:   "p@ss:word/?",
+			Crypto:   "chacha20",
+			Proto:    "origin",
+			Minx:     "plain",
+		},
+	}
+
+	for _, v := range testData {
+		link := v.SSRLink()
+		node, err := ParseSSRLink(link)
+		if err != nil {
+			t.Errorf("parse %s failed: %v\n", link, err)
+			continue
+		}
+		if *node != *v {
+			t.Errorf("node not equal:\n\twant: %v\n\thave: %v\n", *v, *node)
+		}
+	}
+}
+
+func TestParseSSRLink(t *testing.T) {
+	// 标准base64编码并含有padding的链接
+	link := "ssr://MTI3LjAuMC4xOjEyMzQ6YXV0aF9hZXMxMjhfbWQ1OmFlcy0xMjgtY2ZiOnRsczEuMl90aWNrZXRfYXV0aDpZV0ZoWW1KaS8/b2Jmc3BhcmFtPSZyZW1hcmtzPTVyV0w2Sy1WNUxpdDVwYUg="
+	node, err := ParseSSRLink(link)
+	if err != nil {
+		t.Fatalf("parse failed: %v\n", err)
+	}
+	want := SSRNode{
+		NodeName: "测试中文",
+		Type:     "ssr",
+		IP:       "127.0.0.1",
+		Port:     1234,
+		Passwd:   "aaabbb",
+		Crypto:   "aes-128-cfb",
+		Proto:    "auth_aes128_md5",
+		Minx:     "tls1.2_ticket_auth",
+	}
+	if *node != want {
+		t.Errorf("node not equal:\n\twant: %v\n\thave: %v\n", want, *node)
+	}
+
+	wrongData := []string{
+		"",
+		"ss://YWJj",
+		"ssr://!!!",
+		"ssr://MTI3LjAuMC4xOjEyMzQ",
+	}
+	for _, v := range wrongData {
+		if _, err := ParseSSRLink(v); err == nil {
+			t.Errorf("parse wrong link but didn't fail: %v\n", v)
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
+
+	"schannel-qt5/atomicfile"
 )
 
 // SSRNode ssr节点信息
@@ -23,28 +25,22 @@ type SSRNode struct {
 	Crypto string `json:"method"`
 	// 连接协议
 	Proto string `json:"protocol"`
+	// 协议参数，例如auth_aes128系列的"uid:key"
+	ProtoParam string `json:"protocol_param,omitempty"`
 	// 混淆算法
 	Minx string `json:"obfs"`
+	// 混淆参数，例如http_simple和tls1.2_ticket_auth使用的自定义域名
+	MinxParam string `json:"obfs_param,omitempty"`
 }
 
-// Store 将配置信息存入json文件
+// Store 将配置信息原子地存入json文件
 func (s *SSRNode) Store(path string) error {
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0664)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
 	data, err := json.MarshalIndent(s, "", "\t")
 	if err != nil {
 		return err
 	}
 
-	if _, err := f.Write(data); err != nil {
-		return err
-	}
-
-	return nil
+	return atomicfile.WriteFile(path, data, 0664)
 }
 
 // Load 从配置文件读取node信息
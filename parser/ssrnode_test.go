@@ -4,6 +4,9 @@ import (
 	"testing"
 
 	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 )
 
 func TestMarshalNode(t *testing.T) {
@@ -38,3 +41,30 @@ func TestUnmarshalNode(t *testing.T) {
 	}
 	t.Log(*node)
 }
+
+func TestStoreNode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schannel-qt5-node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "node.json")
+	long := &SSRNode{NodeName: "a very very long node name", IP: "255.255.255.255", Passwd: "a long password"}
+	if err := long.Store(path); err != nil {
+		t.Fatal(err)
+	}
+	// 较短的节点不能残留之前的内容
+	short := &SSRNode{NodeName: "a"}
+	if err := short.Store(path); err != nil {
+		t.Fatal(err)
+	}
+
+	node := new(SSRNode)
+	if err := node.Load(path); err != nil {
+		t.Fatalf("读取保存的节点出错: %v\n", err)
+	}
+	if *node != *short {
+		t.Errorf("want: %+v, have: %+v\n", short, node)
+	}
+}
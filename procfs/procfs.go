@@ -0,0 +1,295 @@
+package procfs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoProcess 进程不存在
+	ErrNoProcess = errors.New("process does not exist")
+	// ErrWrongFormat proc文件的格式无法解析
+	ErrWrongFormat = errors.New("wrong proc file format")
+)
+
+const (
+	// tcp连接处于LISTEN状态
+	tcpListen = "0A"
+	// 内核的USER_HZ，linux上一般为100
+	clockTicks = 100
+)
+
+// FS 挂载的proc文件系统根目录
+type FS string
+
+// DefaultFS 系统的proc文件系统
+const DefaultFS FS = "/proc"
+
+func (fs FS) path(elem ...string) string {
+	return filepath.Join(append([]string{string(fs)}, elem...)...)
+}
+
+// Process 进程信息
+type Process struct {
+	Pid int
+	// 命令行参数
+	Cmdline []string
+	// 进程启动时间
+	StartTime time.Time
+}
+
+// Process 读取pid对应的进程信息
+func (fs FS) Process(pid int) (*Process, error) {
+	pidDir := strconv.Itoa(pid)
+	data, err := ioutil.ReadFile(fs.path(pidDir, "cmdline"))
+	if os.IsNotExist(err) {
+		return nil, ErrNoProcess
+	} else if err != nil {
+		return nil, err
+	}
+
+	p := &Process{Pid: pid}
+	for _, arg := range bytes.Split(bytes.TrimRight(data, "\x00"), []byte{0}) {
+		if len(arg) != 0 {
+			p.Cmdline = append(p.Cmdline, string(arg))
+		}
+	}
+
+	p.StartTime, err = fs.startTime(pidDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// startTime 根据stat中的starttime和系统启动时间计算进程启动时间
+func (fs FS) startTime(pidDir string) (time.Time, error) {
+	data, err := ioutil.ReadFile(fs.path(pidDir, "stat"))
+	if os.IsNotExist(err) {
+		return time.Time{}, ErrNoProcess
+	} else if err != nil {
+		return time.Time{}, err
+	}
+
+	// comm可能含有空格和括号，从最后一个')'后开始解析，starttime为第22个字段
+	end := bytes.LastIndexByte(data, ')')
+	if end < 0 {
+		return time.Time{}, ErrWrongFormat
+	}
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 20 {
+		return time.Time{}, ErrWrongFormat
+	}
+	ticks, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	boot, err := fs.bootTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return boot.Add(time.Duration(ticks) * time.Second / clockTicks), nil
+}
+
+// bootTime 读取系统启动时间
+func (fs FS) bootTime() (time.Time, error) {
+	f, err := os.Open(fs.path("stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "btime" {
+			sec, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(sec, 0), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Time{}, ErrWrongFormat
+}
+
+// Listener 处于LISTEN状态的tcp socket
+type Listener struct {
+	IP    net.IP
+	Port  int
+	Inode uint64
+}
+
+// Listeners 返回所有在port上监听的tcp socket，包括IPv4和IPv6
+func (fs FS) Listeners(port int) ([]Listener, error) {
+	res := make([]Listener, 0)
+	for _, name := range []string{"tcp", "tcp6"} {
+		listeners, err := fs.readNetTCP(fs.path("net", name))
+		if os.IsNotExist(err) {
+			// 没有开启IPv6
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, l := range listeners {
+			if l.Port == port {
+				res = append(res, l)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// readNetTCP 解析/proc/net/tcp格式的文件，只返回LISTEN状态的socket
+func (fs FS) readNetTCP(path string) ([]Listener, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	res := make([]Listener, 0)
+	scanner := bufio.NewScanner(f)
+	// 跳过表头
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			return nil, ErrWrongFormat
+		}
+		if fields[3] != tcpListen {
+			continue
+		}
+
+		ip, port, err := parseHexAddr(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, Listener{IP: ip, Port: port, Inode: inode})
+	}
+
+	return res, scanner.Err()
+}
+
+// parseHexAddr 解析"0100007F:0438"格式的地址，ip按每4字节小端序存储
+func parseHexAddr(s string) (net.IP, int, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return nil, 0, ErrWrongFormat
+	}
+
+	raw, err := hex.DecodeString(parts[0])
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return nil, 0, ErrWrongFormat
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+
+	port, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return nil, 0, ErrWrongFormat
+	}
+
+	return ip, int(port), nil
+}
+
+// Match 监听的地址是否与addr冲突
+// 任意一方为通配地址或者addr无法解析为ip时视为冲突
+func (l Listener) Match(addr string) bool {
+	ip := net.ParseIP(strings.Trim(addr, "[]"))
+	if ip == nil || ip.IsUnspecified() || l.IP.IsUnspecified() {
+		return true
+	}
+
+	return ip.Equal(l.IP)
+}
+
+// SocketInodes 返回进程打开的所有socket的inode
+// 没有权限读取其他用户进程的fd时返回os.IsPermission为true的错误
+func (fs FS) SocketInodes(pid int) (map[uint64]bool, error) {
+	fdDir := fs.path(strconv.Itoa(pid), "fd")
+	fds, err := ioutil.ReadDir(fdDir)
+	if os.IsNotExist(err) {
+		return nil, ErrNoProcess
+	} else if err != nil {
+		return nil, err
+	}
+
+	inodes := make(map[uint64]bool)
+	for _, fd := range fds {
+		link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+		if err != nil {
+			// fd已经被关闭
+			continue
+		}
+
+		var inode uint64
+		if _, err := fmt.Sscanf(link, "socket:[%d]", &inode); err == nil {
+			inodes[inode] = true
+		}
+	}
+
+	return inodes, nil
+}
+
+// Pids 返回所有进程的pid
+func (fs FS) Pids() ([]int, error) {
+	entries, err := ioutil.ReadDir(string(fs))
+	if err != nil {
+		return nil, err
+	}
+
+	pids := make([]int, 0, len(entries))
+	for _, e := range entries {
+		if pid, err := strconv.Atoi(e.Name()); err == nil && e.IsDir() {
+			pids = append(pids, pid)
+		}
+	}
+
+	return pids, nil
+}
+
+// FindOwner 返回打开了inode对应socket的进程
+// 所有可读取的进程都不持有这个socket时返回ErrNoProcess，通常是因为它属于其他用户
+func (fs FS) FindOwner(inode uint64) (*Process, error) {
+	pids, err := fs.Pids()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pid := range pids {
+		inodes, err := fs.SocketInodes(pid)
+		if err != nil || !inodes[inode] {
+			continue
+		}
+
+		return fs.Process(pid)
+	}
+
+	return nil, ErrNoProcess
+}
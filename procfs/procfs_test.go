@@ -0,0 +1,131 @@
+package procfs
+
+import (
+	"testing"
+
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func TestParseHexAddr(t *testing.T) {
+	testData := []struct {
+		addr string
+		ip   string
+		port int
+	}{
+		{addr: "0100007F:0438", ip: "127.0.0.1", port: 1080},
+		{addr: "00000000:1F90", ip: "0.0.0.0", port: 8080},
+		{addr: "00000000000000000000000001000000:0438", ip: "::1", port: 1080},
+	}
+
+	for _, v := range testData {
+		ip, port, err := parseHexAddr(v.addr)
+		if err != nil {
+			t.Errorf("parse %s failed: %v\n", v.addr, err)
+			continue
+		}
+		if !ip.Equal(net.ParseIP(v.ip)) || port != v.port {
+			t.Errorf("wrong addr for %s:\n\twant: %s:%d\n\thave: %s:%d\n", v.addr, v.ip, v.port, ip, port)
+		}
+	}
+
+	for _, v := range []string{"", "0100007F", "zz00007F:0438", "0100007F:zz"} {
+		if _, _, err := parseHexAddr(v); err == nil {
+			t.Errorf("parse wrong addr didn't fail: %s\n", v)
+		}
+	}
+}
+
+func TestListenerMatch(t *testing.T) {
+	local := Listener{IP: net.ParseIP("127.0.0.1")}
+	any := Listener{IP: net.ParseIP("::")}
+
+	if !local.Match("127.0.0.1") || !local.Match("0.0.0.0") || !local.Match("localhost") {
+		t.Errorf("127.0.0.1 should match\n")
+	}
+	if local.Match("192.168.1.1") || local.Match("[::1]") {
+		t.Errorf("127.0.0.1 shouldn't match other addr\n")
+	}
+	if !any.Match("192.168.1.1") {
+		t.Errorf("wildcard addr should match everything\n")
+	}
+}
+
+func TestProcess(t *testing.T) {
+	p, err := DefaultFS.Process(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Cmdline) == 0 || filepath.Base(p.Cmdline[0]) != filepath.Base(os.Args[0]) {
+		t.Errorf("wrong cmdline: %v\n", p.Cmdline)
+	}
+	if p.StartTime.After(time.Now()) || time.Since(p.StartTime) > time.Hour {
+		t.Errorf("wrong start time: %v\n", p.StartTime)
+	}
+
+	if _, err := DefaultFS.Process(-1); err != ErrNoProcess {
+		t.Errorf("nonexistent process didn't fail: %v\n", err)
+	}
+}
+
+func TestProcessWithParens(t *testing.T) {
+	dir, err := ioutil.TempDir("", "procfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 伪造的proc文件系统，comm中含有空格和括号
+	os.MkdirAll(filepath.Join(dir, "42"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "stat"), []byte("cpu 0 0 0\nbtime 1000\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "42", "cmdline"), []byte("python\x00local.py\x00"), 0644)
+	stat := "42 (a (b) c) S 1 42 42 0 -1 0 0 0 0 0 0 0 0 0 20 0 1 0 500 0 0\n"
+	ioutil.WriteFile(filepath.Join(dir, "42", "stat"), []byte(stat), 0644)
+
+	p, err := FS(dir).Process(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Cmdline) != 2 || p.Cmdline[1] != "local.py" {
+		t.Errorf("wrong cmdline: %v\n", p.Cmdline)
+	}
+	if !p.StartTime.Equal(time.Unix(1005, 0)) {
+		t.Errorf("wrong start time: %v\n", p.StartTime)
+	}
+}
+
+func TestListeners(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	listeners, err := DefaultFS.Listeners(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners) != 1 || !listeners[0].IP.Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("wrong listeners: %v\n", listeners)
+	}
+
+	inodes, err := DefaultFS.SocketInodes(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !inodes[listeners[0].Inode] {
+		t.Errorf("listener inode not found in fd\n")
+	}
+
+	owner, err := DefaultFS.FindOwner(listeners[0].Inode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner.Pid != os.Getpid() {
+		t.Errorf("wrong owner: %d\n", owner.Pid)
+	}
+}
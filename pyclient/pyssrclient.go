@@ -1,20 +1,37 @@
 package pyclient
 
 import (
-	"errors"
-	"fmt"
 	"log"
-	"net/http"
-	"net/url"
 	"os/exec"
-	"syscall"
 	"time"
 
 	"schannel-qt5/config"
 	"schannel-qt5/ssr"
-	"schannel-qt5/urls"
 )
 
+// daemon模式下python客户端默认的日志文件
+const pyLogFile = "/var/log/shadowsocksr.log"
+
+// pyRequirement python客户端需要python解释器和shadowsocksr的local.py
+var pyRequirement = ssr.BinRequirement{
+	Interpreters: []string{"python"},
+	Names:        []string{"local.py"},
+	Dirs: []string{
+		"~/shadowsocksr/shadowsocks",
+		"/usr/share/shadowsocksr/shadowsocks",
+		"/opt/shadowsocksr/shadowsocks",
+	},
+}
+
+// logFilePath 返回daemon模式下实际使用的日志文件
+func logFilePath(c config.ClientConfig) string {
+	if path := c.LogFilePath(); path != "" {
+		return path
+	}
+
+	return pyLogFile
+}
+
 // PySSRClient 调用Python实现的ssr客户端
 type PySSRClient struct {
 	// 可执行程序的路径
@@ -23,11 +40,14 @@ type PySSRClient struct {
 	binArgs []string
 	// 程序需要的配置
 	conf config.ClientConfig
+	// 需要root权限时使用的权限提升方式
+	elevator ssr.Elevator
 }
 
 func init() {
 	// 注册为可用的Launcher，name为python
 	ssr.SetLuancherMaker("python", ssr.LauncherMaker(newPySSRClient))
+	ssr.SetBinRequirement("python", pyRequirement)
 }
 
 // newPySSRClient 这个函数供ssr.LauncherMaker调用，用于生成ssr.Launcher
@@ -47,6 +67,11 @@ func newPySSRClient(c *config.UserConfig) ssr.Launcher {
 	}
 
 	p.conf = c.SSRClientConfig
+	p.elevator, err = ssr.NewElevator(c.Elevator("python"))
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
 
 	// -c ssr_node_config_file
 	p.binArgs = []string{"python", p.bin}
@@ -56,70 +81,61 @@ func newPySSRClient(c *config.UserConfig) ssr.Launcher {
 	return p
 }
 
-// Start 启动客户端
-func (p *PySSRClient) Start() error {
-	// 使用pkexec在gui程序中请求权限
+// command 生成执行action的命令
+// 端口、pid-file或者日志文件需要root权限时才使用elevator
+func (p *PySSRClient) command(action string) *exec.Cmd {
 	args := make([]string, len(p.binArgs))
 	copy(args, p.binArgs)
-	args = append(args, "-d", "start")
-	cmd := exec.Command("pkexec", args...)
-	return cmd.Run()
+	args = append(args, "-d", action)
+
+	elevator := ssr.NoElevator
+	if ssr.NeedsPrivilege(p.conf.LocalPort(), p.conf.PidFilePath(), logFilePath(p.conf)) {
+		elevator = p.elevator
+	}
+	return elevator.Command(args[0], args[1:]...)
+}
+
+// Start 启动客户端
+func (p *PySSRClient) Start() error {
+	return p.command("start").Run()
 }
 
 // Restart 重新启动客户端
 func (p *PySSRClient) Restart() error {
-	args := make([]string, len(p.binArgs))
-	copy(args, p.binArgs)
-	args = append(args, "-d", "restart")
-	cmd := exec.Command("pkexec", args...)
-	return cmd.Run()
+	return p.command("restart").Run()
 }
 
 // Stop 停止客户端
 func (p *PySSRClient) Stop() error {
-	args := make([]string, len(p.binArgs))
-	copy(args, p.binArgs)
-	args = append(args, "-d", "stop")
-	cmd := exec.Command("pkexec", args...)
-	return cmd.Run()
+	return p.command("stop").Run()
 }
 
-// IsRunning 客户端正在运行返回nil
-// INFO: 如果两个不同客户端进程使用了相同的端口号，则会导致pid-file无法删除，致使判断错误
-func (p *PySSRClient) IsRunning() error {
-	if err := syscall.Access(p.conf.PidFilePath(), syscall.F_OK); err != nil {
-		return err
+// State 根据pid-file对应的进程和端口占用情况返回客户端状态
+func (p *PySSRClient) State() (ssr.State, error) {
+	match := func(cmdline []string) bool {
+		for _, arg := range cmdline {
+			if arg == p.bin {
+				return true
+			}
+		}
+		return false
 	}
 
-	return nil
+	checker := ssr.NewProcessChecker(p.conf.PidFilePath(), p.conf.LocalAddr(), p.conf.LocalPort(), match)
+	return checker.State()
 }
 
-// ConnectionCheck 检查代理是否可用，不可用则返回error
-func (p *PySSRClient) ConnectionCheck(timeout time.Duration) error {
-	proxyURL, err := url.Parse("socks5://" + p.conf.LocalAddr() + ":" + p.conf.LocalPort())
-	if err != nil {
-		return err
-	}
-
-	client := &http.Client{
-		Timeout: timeout,
-	}
-	client.Transport = &http.Transport{Proxy: http.ProxyURL(proxyURL)}
-
-	request, err := http.NewRequest("GET", urls.ProxyTestPath, nil)
-	if err != nil {
-		return err
-	}
-	resp, err := client.Do(request)
+// IsRunning 客户端正在运行返回nil
+func (p *PySSRClient) IsRunning() error {
+	state, err := p.State()
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		info := fmt.Sprintf("Get a wrong status code: %v", resp.StatusCode)
-		return errors.New(info)
-	}
+	return state.Err()
+}
 
-	return nil
+// ConnectionCheck 检查代理是否可用，不可用则返回error
+func (p *PySSRClient) ConnectionCheck(timeout time.Duration) error {
+	return ssr.CheckProxy(p.conf.LocalAddr(), p.conf.LocalPort(), timeout)
 }
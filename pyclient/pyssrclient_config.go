@@ -5,9 +5,9 @@ import (
 	"errors"
 	"io/ioutil"
 	"os"
-	"regexp"
 	"strconv"
 
+	"schannel-qt5/atomicfile"
 	"schannel-qt5/config"
 	"schannel-qt5/ssr"
 )
@@ -16,6 +16,7 @@ var (
 	defaultPidFile = "/tmp/ssr_pyclient.pid"
 	defaultPort    = "1080"
 	defaultAddr    = "127.0.0.1"
+	defaultTimeout = 300
 )
 
 // ClientConfig pyssrclient的本地配置
@@ -29,6 +30,15 @@ type ClientConfig struct {
 
 	// pidfile存放位置(default: /tmp/ssr_client.pid)
 	PidFile string `json:"pid-file,omitempty"`
+
+	// python客户端总是开启udp转发，保留此项以便切换客户端时迁移设置
+	IsUDPRelay bool `json:"udp-relay,omitempty"`
+	// -t 连接超时时间，单位为秒(default: 300)
+	TimeoutSeconds int `json:"timeout,omitempty"`
+	// --log-file daemon模式下的日志文件(default: /var/log/shadowsocksr.log)
+	LogFile string `json:"log-file,omitempty"`
+	// -v 输出详细日志(default: false)
+	IsVerbose bool `json:"verbose,omitempty"`
 }
 
 func init() {
@@ -70,6 +80,26 @@ func (c *ClientConfig) PidFilePath() string {
 	return c.PidFile
 }
 
+func (c *ClientConfig) UDPRelay() bool {
+	return c.IsUDPRelay
+}
+
+func (c *ClientConfig) Timeout() int {
+	if c.TimeoutSeconds == 0 {
+		return defaultTimeout
+	}
+
+	return c.TimeoutSeconds
+}
+
+func (c *ClientConfig) LogFilePath() string {
+	return c.LogFile
+}
+
+func (c *ClientConfig) Verbose() bool {
+	return c.IsVerbose
+}
+
 func (c *ClientConfig) Load(path string) error {
 	f, err := os.Open(path)
 	if err != nil {
@@ -90,20 +120,13 @@ func (c *ClientConfig) Load(path string) error {
 }
 
 func (c *ClientConfig) Store(path string) error {
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0664)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
 	// 格式化成易于阅读的形式
 	data, err := json.MarshalIndent(c, "", "\t")
 	if err != nil {
 		return err
 	}
-	f.Write(data)
 
-	return nil
+	return atomicfile.WriteFile(path, data, 0664)
 }
 
 // 实现ClientConfigSetter
@@ -135,18 +158,46 @@ func (c *ClientConfig) SetPidFilePath(path string) error {
 	return nil
 }
 
-// SetLocalAddr 检查并设置要bind的本地ip
-// 暂时只支持IPv4
+// SetLocalAddr 检查并设置要bind的本地地址
+// 可以是IPv4、IPv6(包括"[::1]"的形式)或者主机名
 func (c *ClientConfig) SetLocalAddr(addr string) error {
-	IP := regexp.MustCompile(`^(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)$`)
-	if !IP.MatchString(addr) {
-		return errors.New("not a valid ip addr")
+	addr, err := config.ParseBindAddr(addr)
+	if err != nil {
+		return err
 	}
 
 	c.Addr = addr
 	return nil
 }
 
+func (c *ClientConfig) SetUDPRelay(udp bool) {
+	c.IsUDPRelay = udp
+}
+
+// SetTimeout 设置连接超时时间，范围为1-3600秒
+func (c *ClientConfig) SetTimeout(seconds int) error {
+	if err := config.CheckTimeout(seconds); err != nil {
+		return err
+	}
+
+	c.TimeoutSeconds = seconds
+	return nil
+}
+
+// SetLogFilePath 设置日志文件路径，需要为绝对路径，为空时使用默认值
+func (c *ClientConfig) SetLogFilePath(path string) error {
+	if err := config.CheckLogFilePath(path); err != nil {
+		return err
+	}
+
+	c.LogFile = path
+	return nil
+}
+
+func (c *ClientConfig) SetVerbose(verbose bool) {
+	c.IsVerbose = verbose
+}
+
 // GenArgs 根据config对象生成命令行参数选项
 func (c *ClientConfig) GenArgs() []string {
 	args := make([]string, 0)
@@ -156,6 +207,15 @@ func (c *ClientConfig) GenArgs() []string {
 	if c.IsFastOpen {
 		args = append(args, "--fast-open")
 	}
+	if c.TimeoutSeconds != 0 {
+		args = append(args, "-t", strconv.Itoa(c.TimeoutSeconds))
+	}
+	if c.LogFile != "" {
+		args = append(args, "--log-file", c.LogFile)
+	}
+	if c.IsVerbose {
+		args = append(args, "-v")
+	}
 
 	return args
 }
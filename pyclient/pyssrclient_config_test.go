@@ -79,13 +79,18 @@ func TestClientConfigSetLocalAddr(t *testing.T) {
 		"127.0.0.1",
 		"10.1.1.2",
 		"210.199.200.201",
+		"::",
+		"::1",
+		"test",
+		"localhost",
 	}
 	wrongData := []string{
 		"",
 		"12345",
 		"12.22.33.",
 		"255.256.0.1",
-		"test",
+		"[127.0.0.1]",
+		"a b",
 	}
 
 	for _, v := range trueData {
@@ -102,6 +107,11 @@ func TestClientConfigSetLocalAddr(t *testing.T) {
 			t.Errorf("set wrong addr but didn't fail: %v\n", v)
 		}
 	}
+
+	// IPv6地址保存时去除方括号
+	if err := conf.SetLocalAddr("[::1]"); err != nil || conf.LocalAddr() != "::1" {
+		t.Errorf("set [::1] failed: %v, %v\n", conf.LocalAddr(), err)
+	}
 }
 
 func TestClientConfigSetPidFilePath(t *testing.T) {
@@ -165,6 +175,19 @@ func TestClientConfigGenArgs(t *testing.T) {
 				"--pid-file /tmp/a.pid " +
 				"--fast-open",
 		},
+		{
+			c: &ClientConfig{
+				PidFile:        "/tmp/a.pid",
+				IsUDPRelay:     true,
+				TimeoutSeconds: 60,
+				LogFile:        "/tmp/ssr.log",
+				IsVerbose:      true,
+			},
+			args: "-b " + defaultAddr +
+				" -l " + defaultPort +
+				" --pid-file /tmp/a.pid" +
+				" -t 60 --log-file /tmp/ssr.log -v",
+		},
 	}
 
 	for _, v := range testData {
@@ -175,6 +198,32 @@ func TestClientConfigGenArgs(t *testing.T) {
 	}
 }
 
+func TestClientConfigOptions(t *testing.T) {
+	conf := &ClientConfig{}
+	if conf.Timeout() != defaultTimeout || conf.LogFilePath() != "" {
+		t.Errorf("wrong default options: %+v\n", conf)
+	}
+
+	for _, v := range []int{0, -1, 3601} {
+		if err := conf.SetTimeout(v); err == nil {
+			t.Errorf("set wrong timeout but didn't fail: %v\n", v)
+		}
+	}
+	if err := conf.SetLogFilePath("ssr.log"); err == nil {
+		t.Errorf("set relative log file but didn't fail\n")
+	}
+
+	if err := conf.SetTimeout(30); err != nil || conf.Timeout() != 30 {
+		t.Errorf("set timeout failed: %v\n", err)
+	}
+	if err := conf.SetLogFilePath("/tmp/ssr.log"); err != nil || conf.LogFilePath() != "/tmp/ssr.log" {
+		t.Errorf("set log file failed: %v\n", err)
+	}
+	if err := conf.SetLogFilePath(""); err != nil || conf.LogFilePath() != "" {
+		t.Errorf("clear log file failed: %v\n", err)
+	}
+}
+
 func TestClientConfigLoad(t *testing.T) {
 	testData := []*struct {
 		// load文件路径
@@ -0,0 +1,98 @@
+package pyclient
+
+import (
+	"errors"
+	"log"
+	"os/exec"
+	"time"
+
+	"schannel-qt5/config"
+	"schannel-qt5/ssr"
+)
+
+var (
+	// ErrWrongConfig ClientConfig不是python客户端使用的配置
+	ErrWrongConfig = errors.New("client config is not a python config")
+	// ErrNeedsPrivilege 前台运行的客户端不提升权限，无法监听特权端口
+	ErrNeedsPrivilege = errors.New("python-supervised runs without root privileges: use a local port above 1023 or the python backend")
+)
+
+// PySupervisedClient 以前台子进程运行Python实现的ssr客户端
+// 客户端崩溃后自动重启，输出写入程序日志
+type PySupervisedClient struct {
+	*ssr.Supervisor
+	// 程序需要的配置
+	conf config.ClientConfig
+}
+
+func init() {
+	// 注册为可用的Launcher，name为python-supervised，与python使用相同的配置
+	ssr.SetLuancherMaker("python-supervised", ssr.LauncherMaker(newPySupervisedClient))
+	ssr.SetClientConfigMaker("python-supervised", config.ClientConfigMaker(newClientConfig))
+	ssr.SetBinRequirement("python-supervised", pyRequirement)
+}
+
+// foregroundArgs 生成前台运行客户端时python的参数
+// 前台运行时不需要root权限，也不会使用pid-file
+func foregroundArgs(c *config.UserConfig) ([]string, *ClientConfig, error) {
+	bin, err := c.SSRBin.AbsPath()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nodeConfigFile, err := c.SSRNodeConfigPath.AbsPath()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conf, ok := c.SSRClientConfig.(*ClientConfig)
+	if !ok {
+		return nil, nil, ErrWrongConfig
+	}
+
+	args := []string{bin, "-c", nodeConfigFile}
+	args = append(args, conf.GenArgs()...)
+	return args, conf, nil
+}
+
+// newPySupervisedClient 这个函数供ssr.LauncherMaker调用，用于生成ssr.Launcher
+func newPySupervisedClient(c *config.UserConfig) ssr.Launcher {
+	args, conf, err := foregroundArgs(c)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	newCmd := func() *exec.Cmd {
+		return exec.Command("python", args...)
+	}
+
+	return &PySupervisedClient{
+		Supervisor: ssr.NewSupervisor("python-supervised", newCmd),
+		conf:       conf,
+	}
+}
+
+// Start 启动客户端，需要root权限时返回ErrNeedsPrivilege
+// 子进程由Supervisor直接管理，不能通过Elevator运行，否则无法结束以root身份运行的进程
+func (p *PySupervisedClient) Start() error {
+	if ssr.NeedsPrivilege(p.conf.LocalPort()) {
+		return ErrNeedsPrivilege
+	}
+
+	return p.Supervisor.Start()
+}
+
+// Restart 重新启动客户端
+func (p *PySupervisedClient) Restart() error {
+	if err := p.Supervisor.Stop(); err != nil && err != ssr.ErrNotSupervising {
+		return err
+	}
+
+	return p.Start()
+}
+
+// ConnectionCheck 检查代理是否可用，不可用则返回error
+func (p *PySupervisedClient) ConnectionCheck(timeout time.Duration) error {
+	return ssr.CheckProxy(p.conf.LocalAddr(), p.conf.LocalPort(), timeout)
+}
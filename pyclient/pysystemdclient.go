@@ -0,0 +1,38 @@
+package pyclient
+
+import (
+	"log"
+	"os/exec"
+
+	"schannel-qt5/config"
+	"schannel-qt5/ssr"
+	"schannel-qt5/systemd"
+)
+
+func init() {
+	// 注册为可用的Launcher，name为python-systemd，与python使用相同的配置
+	ssr.SetLuancherMaker("python-systemd", ssr.LauncherMaker(newPySystemdClient))
+	ssr.SetClientConfigMaker("python-systemd", config.ClientConfigMaker(newClientConfig))
+	ssr.SetBinRequirement("python-systemd", pyRequirement)
+}
+
+// newPySystemdClient 这个函数供ssr.LauncherMaker调用，生成以systemd用户服务运行的ssr.Launcher
+func newPySystemdClient(c *config.UserConfig) ssr.Launcher {
+	args, conf, err := foregroundArgs(c)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	execStart := func() ([]string, error) {
+		// systemd要求使用绝对路径
+		python, err := exec.LookPath("python")
+		if err != nil {
+			return nil, err
+		}
+
+		return append([]string{python}, args...), nil
+	}
+
+	return systemd.NewLauncher("python", conf, execStart)
+}
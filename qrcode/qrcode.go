@@ -0,0 +1,71 @@
+package qrcode
+
+import (
+	"errors"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+
+	"github.com/makiuchi-d/gozxing"
+	zxingqr "github.com/makiuchi-d/gozxing/qrcode"
+	goqrcode "github.com/skip2/go-qrcode"
+)
+
+var (
+	// ErrEmptyContent 没有需要编码的内容
+	ErrEmptyContent = errors.New("empty qrcode content")
+)
+
+// Encode 将content编码为size*size像素的PNG格式QR code
+func Encode(content string, size int) ([]byte, error) {
+	if content == "" {
+		return nil, ErrEmptyContent
+	}
+
+	return goqrcode.Encode(content, goqrcode.Medium, size)
+}
+
+// WriteFile 将content编码为PNG格式的QR code并保存至path
+func WriteFile(content, path string, size int) error {
+	if content == "" {
+		return ErrEmptyContent
+	}
+
+	return goqrcode.WriteFile(content, goqrcode.Medium, size, path)
+}
+
+// Decode 从PNG或JPEG格式的图片中识别QR code，返回其中的文本
+func Decode(r io.Reader) (string, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return "", err
+	}
+
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", err
+	}
+
+	hints := map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+	}
+	result, err := zxingqr.NewQRCodeReader().Decode(bmp, hints)
+	if err != nil {
+		return "", err
+	}
+
+	return result.GetText(), nil
+}
+
+// DecodeFile 识别图片文件path中的QR code
+func DecodeFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return Decode(f)
+}
@@ -0,0 +1,82 @@
+package qrcode
+
+import (
+	"testing"
+
+	"bytes"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	testData := []string{
+		"ssr://MTI3LjAuMC4xOjEyMzQ6b3JpZ2luOmFlcy0yNTYtY2ZiOnBsYWluOllXSmovP3JlbWFya3M9NXJXTDZLLVY",
+		"hello world",
+		"节点1",
+	}
+
+	for _, v := range testData {
+		data, err := Encode(v, 256)
+		if err != nil {
+			t.Errorf("encode %s failed: %v\n", v, err)
+			continue
+		}
+
+		res, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("decode %s failed: %v\n", v, err)
+		} else if res != v {
+			t.Errorf("decode wrong:\n\twant: %s\n\thave: %s\n", v, res)
+		}
+	}
+
+	if _, err := Encode("", 256); err != ErrEmptyContent {
+		t.Errorf("encode empty content but didn't fail\n")
+	}
+}
+
+func TestDecodeFile(t *testing.T) {
+	content := "ssr://test"
+	dir, err := ioutil.TempDir("", "qrcode-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pngPath := filepath.Join(dir, "test.png")
+	if err := WriteFile(content, pngPath, 256); err != nil {
+		t.Fatalf("write png failed: %v\n", err)
+	}
+
+	// 将png转换为jpeg，测试对jpeg格式的支持
+	pngFile, err := os.Open(pngPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(pngFile)
+	pngFile.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jpegPath := filepath.Join(dir, "test.jpg")
+	jpegFile, err := os.Create(jpegPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(jpegFile, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	jpegFile.Close()
+
+	for _, path := range []string{pngPath, jpegPath} {
+		res, err := DecodeFile(path)
+		if err != nil {
+			t.Errorf("decode %s failed: %v\n", path, err)
+		} else if res != content {
+			t.Errorf("decode %s wrong: have %s; want %s\n", path, res, content)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package ssr
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"schannel-qt5/config"
+	"schannel-qt5/geoip"
+	"schannel-qt5/urls"
+)
+
+// ErrExitIP 无法解析出口ip
+var ErrExitIP = errors.New("wrong exit ip response")
+
+// CheckOptions 检查代理时使用的设置
+type CheckOptions struct {
+	// 需要访问的URL，为空时使用urls.ProxyTestPath
+	Endpoints []string
+	// 返回请求方ip的URL，为空时不检查出口ip
+	ExitIPEndpoint string
+	// 每个请求的超时时间
+	Timeout time.Duration
+	// 出口ip所在国家名称的语言，为空时使用zh-CN
+	Lang string
+}
+
+// EndpointResult 访问单个URL的结果
+type EndpointResult struct {
+	URL string
+	// 从发出请求到收到响应头的时间
+	Latency time.Duration
+	// HTTP状态码，请求失败时为0
+	StatusCode int
+	Err        error
+}
+
+// CheckResult 检查代理的结果
+type CheckResult struct {
+	// 顺序与CheckOptions.Endpoints相同
+	Endpoints []EndpointResult
+	// 代理的出口ip和所在国家/地区
+	ExitIP      string
+	ExitCountry string
+	// 获取出口ip或者查询国家失败时的错误
+	ExitErr error
+}
+
+// Err 所有URL都无法访问时返回第一个错误，否则返回nil
+func (r *CheckResult) Err() error {
+	for _, e := range r.Endpoints {
+		if e.Err == nil {
+			return nil
+		}
+	}
+
+	if len(r.Endpoints) == 0 {
+		return errors.New("no endpoint checked")
+	}
+	return r.Endpoints[0].Err
+}
+
+// Latency 返回可访问的URL中最低的延迟，都无法访问时返回0
+func (r *CheckResult) Latency() time.Duration {
+	var latency time.Duration
+	for _, e := range r.Endpoints {
+		if e.Err == nil && (latency == 0 || e.Latency < latency) {
+			latency = e.Latency
+		}
+	}
+
+	return latency
+}
+
+// dialHost 返回本机连接监听在addr上的服务时使用的地址
+// 监听在所有地址上时使用回环地址，"::"对应"::1"
+func dialHost(addr string) string {
+	ip := net.ParseIP(addr)
+	if addr == "" || (ip != nil && ip.IsUnspecified()) {
+		if ip != nil && ip.To4() == nil {
+			return "::1"
+		}
+		return "127.0.0.1"
+	}
+
+	return addr
+}
+
+// SocksAddr 返回本机访问ssr客户端socks5代理使用的host:port
+func SocksAddr(c config.ClientConfig) string {
+	return net.JoinHostPort(dialHost(c.LocalAddr()), c.LocalPort())
+}
+
+// CheckProxy 通过addr:port上的socks5代理访问urls.ProxyTestPath，不可用则返回error
+func CheckProxy(addr, port string, timeout time.Duration) error {
+	return CheckProxyDetail(addr, port, CheckOptions{Timeout: timeout}).Err()
+}
+
+// CheckProxyDetail 通过addr:port上的socks5代理并发访问所有URL并查询出口ip
+func CheckProxyDetail(addr, port string, opts CheckOptions) *CheckResult {
+	endpoints := opts.Endpoints
+	if len(endpoints) == 0 {
+		endpoints = []string{urls.ProxyTestPath}
+	}
+	res := &CheckResult{Endpoints: make([]EndpointResult, len(endpoints))}
+
+	client, err := proxyClient(addr, port, opts.Timeout)
+	if err != nil {
+		for i, u := range endpoints {
+			res.Endpoints[i] = EndpointResult{URL: u, Err: err}
+		}
+		res.ExitErr = err
+		return res
+	}
+
+	wg := &sync.WaitGroup{}
+	for i, u := range endpoints {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			res.Endpoints[i] = checkEndpoint(client, u)
+		}(i, u)
+	}
+	if opts.ExitIPEndpoint != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res.ExitIP, res.ExitCountry, res.ExitErr = checkExitIP(client, opts.ExitIPEndpoint, opts.Lang)
+		}()
+	}
+	wg.Wait()
+
+	return res
+}
+
+// proxyClient 生成使用addr:port上socks5代理的http.Client
+// 每次检查都会生成新的client，不保留空闲连接，延迟也包含建立连接的时间
+func proxyClient(addr, port string, timeout time.Duration) (*http.Client, error) {
+	proxyURL, err := url.Parse("socks5://" + net.JoinHostPort(dialHost(addr), port))
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Timeout: timeout,
+	}
+	client.Transport = &http.Transport{
+		Proxy:             http.ProxyURL(proxyURL),
+		DisableKeepAlives: true,
+	}
+	return client, nil
+}
+
+// checkEndpoint 访问u并记录延迟和状态码，状态码不为200时返回错误
+func checkEndpoint(client *http.Client, u string) EndpointResult {
+	res := EndpointResult{URL: u}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	start := time.Now()
+	resp, err := client.Do(request)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer resp.Body.Close()
+	res.Latency = time.Since(start)
+	res.StatusCode = resp.StatusCode
+
+	if resp.StatusCode != http.StatusOK {
+		info := fmt.Sprintf("Get a wrong status code: %v", resp.StatusCode)
+		res.Err = errors.New(info)
+	}
+
+	return res
+}
+
+// checkExitIP 访问返回请求方ip的URL获取出口ip，并使用geoip查询所在国家
+// 响应内容需要为纯文本的ip
+func checkExitIP(client *http.Client, u, lang string) (ip, country string, err error) {
+	resp, err := client.Get(u)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	// ip不会超过64字节，限制读取的长度
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return "", "", err
+	}
+	ip = strings.TrimSpace(string(data))
+	if net.ParseIP(ip) == nil {
+		return "", "", ErrExitIP
+	}
+
+	if lang == "" {
+		lang = "zh-CN"
+	}
+	country, _, err = geoip.GetCountryCity(ip, lang)
+	return ip, country, err
+}
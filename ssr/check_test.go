@@ -0,0 +1,49 @@
+package ssr
+
+import (
+	"testing"
+
+	"errors"
+	"time"
+)
+
+func TestCheckResult(t *testing.T) {
+	failed := errors.New("timeout")
+	res := &CheckResult{
+		Endpoints: []EndpointResult{
+			{URL: "https://a.example", Err: failed},
+			{URL: "https://b.example", Latency: 300 * time.Millisecond, StatusCode: 200},
+			{URL: "https://c.example", Latency: 100 * time.Millisecond, StatusCode: 200},
+		},
+	}
+	if err := res.Err(); err != nil {
+		t.Errorf("partly available proxy failed: %v\n", err)
+	}
+	if latency := res.Latency(); latency != 100*time.Millisecond {
+		t.Errorf("wrong latency: %v\n", latency)
+	}
+
+	res.Endpoints = res.Endpoints[:1]
+	if err := res.Err(); err != failed {
+		t.Errorf("unavailable proxy didn't fail: %v\n", err)
+	}
+	if latency := res.Latency(); latency != 0 {
+		t.Errorf("wrong latency of unavailable proxy: %v\n", latency)
+	}
+}
+
+func TestCheckProxyDetailNoProxy(t *testing.T) {
+	// 本地没有监听的端口，所有请求都会失败
+	opts := CheckOptions{
+		Endpoints:      []string{"http://a.example", "http://b.example"},
+		ExitIPEndpoint: "http://ip.example",
+		Timeout:        time.Second,
+	}
+	res := CheckProxyDetail("127.0.0.1", "1", opts)
+	if len(res.Endpoints) != 2 || res.Endpoints[1].URL != "http://b.example" {
+		t.Fatalf("wrong endpoints: %v\n", res.Endpoints)
+	}
+	if res.Err() == nil || res.ExitErr == nil {
+		t.Errorf("check without proxy didn't fail\n")
+	}
+}
@@ -0,0 +1,100 @@
+package ssr
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"schannel-qt5/config"
+)
+
+var (
+	// ErrNoRequirement 客户端没有注册需要的外部程序
+	ErrNoRequirement = errors.New("client requirement not registered")
+	// ErrInterpreterNotFound PATH中找不到客户端需要的解释器
+	ErrInterpreterNotFound = errors.New("interpreter not found in PATH")
+)
+
+// BinRequirement 客户端运行需要的外部程序，用于首次运行时查找ssr_bin
+type BinRequirement struct {
+	// Interpreters 运行ssr_bin需要的解释器，为空表示不需要
+	Interpreters []string
+	// Names ssr_bin可能的文件名，为空表示客户端运行在schannel-qt5内部
+	Names []string
+	// Dirs 除PATH以外查找Names的目录，以~开头的路径相对于$HOME
+	Dirs []string
+}
+
+// Detection 查找到的解释器和ssr_bin
+type Detection struct {
+	// Interpreter 找到的解释器，不需要或者没有找到时为空
+	Interpreter string
+	// Bins 找到的ssr_bin，PATH中的在前
+	Bins []string
+}
+
+// 保存注册的BinRequirement
+var requirements = make(map[string]BinRequirement)
+
+// SetBinRequirement 注册客户端需要的外部程序
+func SetBinRequirement(name string, r BinRequirement) {
+	if name == "" {
+		panic("SetBinRequirement error: wrong name")
+	}
+
+	requirements[name] = r
+}
+
+// DetectBins 在PATH和BinRequirement.Dirs中查找name客户端需要的解释器和ssr_bin
+// 运行在schannel-qt5内部的客户端返回schannel-qt5自身的路径
+// 找不到解释器时仍然返回找到的ssr_bin和ErrInterpreterNotFound
+func DetectBins(name string) (*Detection, error) {
+	r, ok := requirements[name]
+	if !ok {
+		return nil, ErrNoRequirement
+	}
+
+	d := &Detection{}
+	if len(r.Names) == 0 {
+		exe, err := os.Executable()
+		if err != nil {
+			return nil, err
+		}
+		d.Bins = []string{exe}
+		return d, nil
+	}
+
+	dirs := filepath.SplitList(os.Getenv("PATH"))
+	for _, dir := range r.Dirs {
+		jpath := config.JSONPath{Data: dir}
+		if abs, err := jpath.AbsPath(); err == nil {
+			dirs = append(dirs, abs)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		for _, bin := range r.Names {
+			path := filepath.Join(dir, bin)
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				d.Bins = append(d.Bins, path)
+			}
+		}
+	}
+
+	for _, interpreter := range r.Interpreters {
+		if path, err := exec.LookPath(interpreter); err == nil {
+			d.Interpreter = path
+			return d, nil
+		}
+	}
+	if len(r.Interpreters) != 0 {
+		return d, ErrInterpreterNotFound
+	}
+
+	return d, nil
+}
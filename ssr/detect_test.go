@@ -0,0 +1,76 @@
+package ssr
+
+import (
+	"testing"
+
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+func TestDetectBins(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schannel-qt5-detect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pathDir := filepath.Join(dir, "bin")
+	extraDir := filepath.Join(dir, "shadowsocksr")
+	for _, d := range []string{pathDir, extraDir, filepath.Join(extraDir, "local.py")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	interpreter := filepath.Join(pathDir, "test-python")
+	for _, f := range []string{interpreter, filepath.Join(pathDir, "ssr-local")} {
+		if err := ioutil.WriteFile(f, []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script := filepath.Join(extraDir, "run.py")
+	if err := ioutil.WriteFile(script, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", pathDir)
+
+	SetBinRequirement("test-py", BinRequirement{
+		Interpreters: []string{"test-python3", "test-python"},
+		// 目录local.py不是可用的ssr_bin
+		Names: []string{"local.py", "run.py"},
+		Dirs:  []string{extraDir, extraDir},
+	})
+	d, err := DetectBins("test-py")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Interpreter != interpreter || !reflect.DeepEqual(d.Bins, []string{script}) {
+		t.Errorf("wrong detection: %+v\n", d)
+	}
+
+	SetBinRequirement("test-missing", BinRequirement{
+		Interpreters: []string{"test-python3"},
+		Names:        []string{"ssr-local"},
+	})
+	d, err = DetectBins("test-missing")
+	if err != ErrInterpreterNotFound {
+		t.Errorf("缺少解释器时应该返回错误: %v\n", err)
+	}
+	if d == nil || len(d.Bins) != 1 {
+		t.Errorf("缺少解释器时仍然需要返回找到的程序: %+v\n", d)
+	}
+
+	// 运行在程序内部的客户端使用自身的路径
+	SetBinRequirement("test-inner", BinRequirement{})
+	exe, _ := os.Executable()
+	if d, err := DetectBins("test-inner"); err != nil || !reflect.DeepEqual(d.Bins, []string{exe}) {
+		t.Errorf("wrong inner client detection: %+v, %v\n", d, err)
+	}
+
+	if _, err := DetectBins("test-unknown"); err != ErrNoRequirement {
+		t.Errorf("未注册的客户端应该返回错误: %v\n", err)
+	}
+}
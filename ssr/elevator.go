@@ -0,0 +1,79 @@
+package ssr
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"schannel-qt5/config"
+)
+
+// access(2)检查写权限的mode
+const wOK = 0x2
+
+// Elevator 以更高的权限运行命令
+type Elevator interface {
+	// Command 生成以提升后的权限运行name的命令
+	Command(name string, args ...string) *exec.Cmd
+}
+
+// prefixElevator 在命令前加上前缀运行，前缀为空时直接运行
+type prefixElevator []string
+
+func (p prefixElevator) Command(name string, args ...string) *exec.Cmd {
+	if len(p) == 0 {
+		return exec.Command(name, args...)
+	}
+
+	cmdArgs := make([]string, 0, len(p)+len(args))
+	cmdArgs = append(cmdArgs, p[1:]...)
+	cmdArgs = append(cmdArgs, name)
+	cmdArgs = append(cmdArgs, args...)
+	return exec.Command(p[0], cmdArgs...)
+}
+
+// NoElevator 不提升权限，直接运行命令
+var NoElevator Elevator = prefixElevator(nil)
+
+// NewElevator 根据配置生成Elevator
+func NewElevator(conf config.ElevatorConfig) (Elevator, error) {
+	if err := conf.Valid(); err != nil {
+		return nil, err
+	}
+
+	switch conf.Type {
+	case config.ElevatorPkexec:
+		return prefixElevator{"pkexec"}, nil
+	case config.ElevatorSudo:
+		return prefixElevator{"sudo", "-A", "--"}, nil
+	case config.ElevatorCustom:
+		return prefixElevator(conf.Command), nil
+	}
+
+	return NoElevator, nil
+}
+
+// NeedsPrivilege 监听port或者写入paths需要root权限时返回true
+// paths中的文件不存在时检查其所在目录
+func NeedsPrivilege(port string, paths ...string) bool {
+	if os.Getuid() == 0 {
+		return false
+	}
+
+	if p, err := strconv.Atoi(port); err == nil && p < 1024 {
+		return true
+	}
+
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			path = filepath.Dir(path)
+		}
+		if syscall.Access(path, wOK) != nil {
+			return true
+		}
+	}
+
+	return false
+}
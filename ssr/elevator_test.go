@@ -0,0 +1,66 @@
+package ssr
+
+import (
+	"testing"
+
+	"os"
+	"reflect"
+
+	"schannel-qt5/config"
+)
+
+func TestNewElevator(t *testing.T) {
+	testData := []struct {
+		conf config.ElevatorConfig
+		args []string
+	}{
+		{
+			conf: config.ElevatorConfig{Type: config.ElevatorNone},
+			args: []string{"python", "local.py", "-d", "start"},
+		},
+		{
+			conf: config.ElevatorConfig{Type: config.ElevatorPkexec},
+			args: []string{"pkexec", "python", "local.py", "-d", "start"},
+		},
+		{
+			conf: config.ElevatorConfig{Type: config.ElevatorSudo},
+			args: []string{"sudo", "-A", "--", "python", "local.py", "-d", "start"},
+		},
+		{
+			conf: config.ElevatorConfig{Type: config.ElevatorCustom, Command: []string{"doas", "-n"}},
+			args: []string{"doas", "-n", "python", "local.py", "-d", "start"},
+		},
+	}
+
+	for _, v := range testData {
+		e, err := NewElevator(v.conf)
+		if err != nil {
+			t.Errorf("create elevator %v failed: %v\n", v.conf, err)
+			continue
+		}
+		cmd := e.Command("python", "local.py", "-d", "start")
+		if !reflect.DeepEqual(cmd.Args, v.args) {
+			t.Errorf("wrong args:\n\twant: %v\n\thave: %v\n", v.args, cmd.Args)
+		}
+	}
+
+	if _, err := NewElevator(config.ElevatorConfig{Type: config.ElevatorCustom}); err == nil {
+		t.Errorf("custom elevator without command didn't fail\n")
+	}
+}
+
+func TestNeedsPrivilege(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("root never needs privilege\n")
+	}
+
+	if !NeedsPrivilege("80") {
+		t.Errorf("privileged port doesn't need privilege\n")
+	}
+	if NeedsPrivilege("1080", os.TempDir()+"/ssr_test.pid") {
+		t.Errorf("normal config needs privilege\n")
+	}
+	if !NeedsPrivilege("1080", "/proc/ssr_test.pid") {
+		t.Errorf("unwritable pidfile doesn't need privilege\n")
+	}
+}
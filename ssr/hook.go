@@ -0,0 +1,187 @@
+package ssr
+
+import (
+	"sort"
+	"sync"
+
+	"schannel-qt5/config"
+)
+
+// Hook 随客户端一起启动和停止的附加服务，例如本地http代理
+type Hook interface {
+	// Start 在客户端启动后调用
+	Start() error
+	// Stop 在客户端停止前调用
+	Stop() error
+}
+
+// HookMaker 根据用户配置生成Hook，配置中没有启用时返回nil
+type HookMaker func(*config.UserConfig) Hook
+
+// 保存注册的HookMaker
+var hooks = make(map[string]HookMaker)
+
+// SetHookMaker 注册Hook生成器
+func SetHookMaker(name string, maker HookMaker) {
+	if name == "" || maker == nil {
+		panic("SetHookMaker error: wrong name or HookMaker")
+	}
+
+	hooks[name] = maker
+}
+
+// newHooks 生成所有启用的Hook，按名称排序
+func newHooks(conf *config.UserConfig) []Hook {
+	names := make([]string, 0, len(hooks))
+	for name := range hooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := make([]Hook, 0, len(names))
+	for _, name := range names {
+		if h := hooks[name](conf); h != nil {
+			res = append(res, h)
+		}
+	}
+
+	return res
+}
+
+// crashHandler 客户端崩溃和恢复时需要处理的Launcher
+type crashHandler interface {
+	// clientCrashed StateWatcher发送EventCrashed后调用
+	clientCrashed()
+	// clientRecovered 崩溃后客户端重新开始运行时调用
+	clientRecovered()
+}
+
+// hookedLauncher 在客户端启动和停止时同时启动和停止Hook
+// 客户端崩溃和恢复时由StateWatcher通知停止和重新启动Hook
+type hookedLauncher struct {
+	Launcher
+	hooks []Hook
+
+	// 保护started，崩溃通知来自StateWatcher的goroutine
+	lock sync.Mutex
+	// hooks是否已经启动
+	started bool
+}
+
+// withHooks 为launcher附加conf中启用的Hook，没有启用的Hook时返回launcher本身
+// 客户端已经在运行时立即启动Hook
+func withHooks(launcher Launcher, conf *config.UserConfig) Launcher {
+	h := newHooks(conf)
+	if len(h) == 0 {
+		return launcher
+	}
+
+	l := &hookedLauncher{Launcher: launcher, hooks: h}
+	if launcher.IsRunning() == nil {
+		if err := l.startHooks(); err != nil {
+			logger.Println("start hooks:", err)
+		}
+	}
+	return l
+}
+
+// startHooks 按顺序启动所有Hook，失败时停止已经启动的Hook
+func (l *hookedLauncher) startHooks() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.started {
+		return nil
+	}
+
+	for i, h := range l.hooks {
+		if err := h.Start(); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				l.hooks[j].Stop()
+			}
+			return err
+		}
+	}
+	l.started = true
+	return nil
+}
+
+// stopHooks 按相反的顺序停止所有Hook，返回第一个错误
+func (l *hookedLauncher) stopHooks() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if !l.started {
+		return nil
+	}
+
+	var res error
+	for i := len(l.hooks) - 1; i >= 0; i-- {
+		if err := l.hooks[i].Stop(); err != nil && res == nil {
+			res = err
+		}
+	}
+	l.started = false
+	return res
+}
+
+// Start 启动客户端和Hook，Hook启动失败时停止客户端
+func (l *hookedLauncher) Start() error {
+	if err := l.Launcher.Start(); err != nil {
+		return err
+	}
+
+	if err := l.startHooks(); err != nil {
+		l.Launcher.Stop()
+		return err
+	}
+	return nil
+}
+
+// Restart 重启客户端，Hook随之重启
+func (l *hookedLauncher) Restart() error {
+	if err := l.stopHooks(); err != nil {
+		logger.Println("stop hooks:", err)
+	}
+	if err := l.Launcher.Restart(); err != nil {
+		return err
+	}
+
+	return l.startHooks()
+}
+
+// Stop 停止Hook和客户端
+func (l *hookedLauncher) Stop() error {
+	if err := l.stopHooks(); err != nil {
+		logger.Println("stop hooks:", err)
+	}
+
+	return l.Launcher.Stop()
+}
+
+// clientCrashed 客户端崩溃后停止Hook，不再让http代理和系统代理指向已经退出的客户端
+// 之后通过Start或Restart启动客户端时Hook会重新启动
+func (l *hookedLauncher) clientCrashed() {
+	if err := l.stopHooks(); err != nil {
+		logger.Println("stop hooks:", err)
+	}
+}
+
+// clientRecovered 崩溃的客户端被supervisor或systemd重新启动后，重新启动Hook
+func (l *hookedLauncher) clientRecovered() {
+	if err := l.startHooks(); err != nil {
+		logger.Println("start hooks:", err)
+	}
+}
+
+// State 客户端实现了StateReporter时返回其状态
+func (l *hookedLauncher) State() (State, error) {
+	if r, ok := l.Launcher.(StateReporter); ok {
+		return r.State()
+	}
+
+	if err := l.IsRunning(); err != nil {
+		return StateStopped, nil
+	}
+	return StateRunning, nil
+}
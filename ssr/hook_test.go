@@ -0,0 +1,144 @@
+package ssr
+
+import (
+	"testing"
+
+	"errors"
+	"reflect"
+	"time"
+
+	"schannel-qt5/config"
+)
+
+// fakeHook 记录调用顺序的Hook
+type fakeHook struct {
+	name     string
+	calls    *[]string
+	startErr error
+}
+
+func (f *fakeHook) Start() error {
+	*f.calls = append(*f.calls, f.name+" start")
+	return f.startErr
+}
+
+func (f *fakeHook) Stop() error {
+	*f.calls = append(*f.calls, f.name+" stop")
+	return nil
+}
+
+func TestHookedLauncher(t *testing.T) {
+	calls := make([]string, 0)
+	launcher := &fakeLauncher{}
+	l := &hookedLauncher{
+		Launcher: launcher,
+		hooks: []Hook{
+			&fakeHook{name: "a", calls: &calls},
+			&fakeHook{name: "b", calls: &calls},
+		},
+	}
+
+	if err := l.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a start", "b start", "b stop", "a stop"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("wrong hook calls:\n\twant: %v\n\thave: %v\n", want, calls)
+	}
+
+	// 第二个Hook启动失败时停止第一个Hook和客户端
+	calls = calls[:0]
+	l.hooks[1].(*fakeHook).startErr = errors.New("address already in use")
+	if err := l.Start(); err == nil {
+		t.Errorf("hook failed but start didn't fail\n")
+	}
+	want = []string{"a start", "b start", "a stop"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("wrong hook calls:\n\twant: %v\n\thave: %v\n", want, calls)
+	}
+	if launcher.IsRunning() == nil {
+		t.Errorf("client is still running after hook failed\n")
+	}
+}
+
+func TestNewLauncherWithHooks(t *testing.T) {
+	calls := make([]string, 0)
+	SetLuancherMaker("test-hook", func(*config.UserConfig) Launcher { return &fakeLauncher{} })
+	SetHookMaker("test-disabled", func(*config.UserConfig) Hook { return nil })
+
+	if _, ok := NewLauncher("test-hook", &config.UserConfig{}).(*fakeLauncher); !ok {
+		t.Errorf("launcher without enabled hooks was wrapped\n")
+	}
+
+	SetHookMaker("test-enabled", func(*config.UserConfig) Hook {
+		return &fakeHook{name: "enabled", calls: &calls}
+	})
+	defer delete(hooks, "test-enabled")
+	l, ok := NewLauncher("test-hook", &config.UserConfig{}).(*hookedLauncher)
+	if !ok || len(l.hooks) != 1 {
+		t.Fatalf("enabled hook wasn't attached\n")
+	}
+}
+
+func TestHooksFollowCrash(t *testing.T) {
+	calls := make([]string, 0)
+	launcher := &fakeLauncher{}
+	l := &hookedLauncher{
+		Launcher: launcher,
+		hooks:    []Hook{&fakeHook{name: "a", calls: &calls}},
+	}
+	w := NewStateWatcher(l)
+	w.PollInterval = 10 * time.Millisecond
+	w.Watch()
+	defer w.Close()
+
+	nextEvent(t, w)
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for e := nextEvent(t, w); e.Type != EventRunning; e = nextEvent(t, w) {
+	}
+
+	// 客户端崩溃后Hook被停止
+	launcher.set(false, nil)
+	if e := nextEvent(t, w); e.Type != EventCrashed {
+		t.Fatalf("wrong event: %v\n", e.Type)
+	}
+	stopped := waitFor(5*time.Second, func() bool {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		return !l.started
+	})
+	if !stopped {
+		t.Fatalf("hooks are still running after crash\n")
+	}
+	l.lock.Lock()
+	want := []string{"a start", "a stop"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("wrong hook calls:\n\twant: %v\n\thave: %v\n", want, calls)
+	}
+	l.lock.Unlock()
+
+	// 客户端在外部被重新启动后Hook重新启动
+	launcher.set(true, nil)
+	if e := nextEvent(t, w); e.Type != EventRunning {
+		t.Fatalf("wrong event: %v\n", e.Type)
+	}
+	started := waitFor(5*time.Second, func() bool {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		return l.started
+	})
+	if !started {
+		t.Fatalf("hooks weren't started after the client recovered\n")
+	}
+	l.lock.Lock()
+	want = []string{"a start", "a stop", "a start"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("wrong hook calls:\n\twant: %v\n\thave: %v\n", want, calls)
+	}
+	l.lock.Unlock()
+}
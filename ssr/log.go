@@ -0,0 +1,23 @@
+package ssr
+
+import (
+	"log"
+	"os"
+)
+
+// logger 记录客户端输出和运行状态，默认输出至stderr
+var logger = log.New(os.Stderr, "", log.LstdFlags)
+
+// SetLogger 设置ssr包使用的日志记录器，一般为程序的主logger
+func SetLogger(l *log.Logger) {
+	if l == nil {
+		panic("SetLogger error: nil logger")
+	}
+
+	logger = l
+}
+
+// Logger 返回ssr包使用的日志记录器，随客户端运行的Hook也使用它记录日志
+func Logger() *log.Logger {
+	return logger
+}
@@ -0,0 +1,103 @@
+package ssr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"schannel-qt5/config"
+	"schannel-qt5/procfs"
+)
+
+const (
+	// 自动选择端口时最多尝试的端口数
+	maxPortTries = 100
+)
+
+// ErrNoFreePort 找不到可用的端口
+var ErrNoFreePort = errors.New("can't find a free local port")
+
+// PortConflict 本地端口已经被其他进程监听
+type PortConflict struct {
+	Addr, Port string
+	// 占用端口的进程，属于其他用户而无法读取时为nil
+	Process *procfs.Process
+}
+
+func (p *PortConflict) Error() string {
+	if p.Process == nil {
+		return fmt.Sprintf("%s:%s is used by a process of another user", p.Addr, p.Port)
+	}
+
+	return fmt.Sprintf("%s:%s is used by pid %d (%s)",
+		p.Addr, p.Port, p.Process.Pid, strings.Join(p.Process.Cmdline, " "))
+}
+
+// CheckPort 检查addr:port是否已被监听，被占用时返回*PortConflict
+func CheckPort(addr, port string) error {
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return err
+	}
+
+	listeners, err := procfs.DefaultFS.Listeners(p)
+	if err != nil {
+		return err
+	}
+	for _, l := range listeners {
+		if !l.Match(addr) {
+			continue
+		}
+
+		conflict := &PortConflict{Addr: addr, Port: port}
+		if proc, err := procfs.DefaultFS.FindOwner(l.Inode); err == nil {
+			conflict.Process = proc
+		}
+		return conflict
+	}
+
+	return nil
+}
+
+// NextFreePort 从port之后查找第一个可以使用的端口
+func NextFreePort(addr, port string) (string, error) {
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return "", err
+	}
+
+	for i := 1; i <= maxPortTries && p+i <= 65535; i++ {
+		next := strconv.Itoa(p + i)
+		err := CheckPort(addr, next)
+		if err == nil {
+			return next, nil
+		} else if _, ok := err.(*PortConflict); !ok {
+			return "", err
+		}
+	}
+
+	return "", ErrNoFreePort
+}
+
+// ResolvePortConflict 启动客户端前检查本地端口是否被占用
+// autoPick为true时将conf的端口修改为下一个空闲端口并返回新端口，否则返回*PortConflict
+// 端口未被占用时返回空字符串
+func ResolvePortConflict(conf config.ClientConfig, autoPick bool) (string, error) {
+	err := CheckPort(conf.LocalAddr(), conf.LocalPort())
+	if err == nil {
+		return "", nil
+	} else if _, ok := err.(*PortConflict); !ok || !autoPick {
+		return "", err
+	}
+
+	port, err := NextFreePort(conf.LocalAddr(), conf.LocalPort())
+	if err != nil {
+		return "", err
+	}
+	if err := conf.SetLocalPort(port); err != nil {
+		return "", err
+	}
+
+	return port, nil
+}
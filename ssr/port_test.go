@@ -0,0 +1,61 @@
+package ssr
+
+import (
+	"testing"
+
+	"net"
+	"os"
+	"strconv"
+)
+
+func TestCheckPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+
+	err = CheckPort("127.0.0.1", port)
+	conflict, ok := err.(*PortConflict)
+	if !ok {
+		t.Fatalf("used port didn't conflict: %v\n", err)
+	}
+	if conflict.Process == nil || conflict.Process.Pid != os.Getpid() {
+		t.Errorf("wrong conflict process: %v\n", conflict.Process)
+	}
+
+	// 监听127.0.0.1不会与其他地址冲突
+	if err := CheckPort("127.0.0.2", port); err != nil {
+		t.Errorf("different addr conflicted: %v\n", err)
+	}
+}
+
+func TestResolvePortConflict(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+
+	conf := &fakeConfig{addr: "127.0.0.1", port: port}
+	if _, err := ResolvePortConflict(conf, false); err == nil {
+		t.Errorf("conflict didn't fail\n")
+	}
+	if conf.port != port {
+		t.Errorf("port changed without auto pick\n")
+	}
+
+	newPort, err := ResolvePortConflict(conf, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newPort == "" || newPort == port || conf.port != newPort {
+		t.Errorf("wrong new port: %s, config: %s\n", newPort, conf.port)
+	}
+
+	if newPort, err := ResolvePortConflict(conf, true); err != nil || newPort != "" {
+		t.Errorf("free port changed: %s %v\n", newPort, err)
+	}
+}
@@ -1,6 +1,7 @@
 package ssr
 
 import (
+	"sort"
 	"time"
 
 	"schannel-qt5/config"
@@ -30,6 +31,11 @@ var (
 	configs = make(map[string]config.ClientConfigMaker)
 )
 
+func init() {
+	// 加载UserConfig时根据客户端类型生成ClientConfig
+	config.SetClientConfigLookup(NewClientConfig)
+}
+
 // SetLuancherMaker 注册Launcher生成器
 func SetLuancherMaker(name string, maker LauncherMaker) {
 	if name == "" || maker == nil {
@@ -49,13 +55,18 @@ func SetClientConfigMaker(name string, maker config.ClientConfigMaker) {
 }
 
 // NewLauncher 返回由name指定的Launcher生成器使用config.UserConfig生成的Launcher
+// 配置中启用的Hook会随Launcher一起启动和停止
 func NewLauncher(name string, conf *config.UserConfig) Launcher {
 	maker, ok := launchers[name]
 	if !ok {
 		return nil
 	}
 
-	return maker(conf)
+	launcher := maker(conf)
+	if launcher == nil {
+		return nil
+	}
+	return withHooks(launcher, conf)
 }
 
 // NewClientConfig 根据名字返回默认值的ClientConfig
@@ -67,3 +78,35 @@ func NewClientConfig(name string) config.ClientConfig {
 
 	return maker()
 }
+
+// Launchers 返回同时注册了Launcher和ClientConfig生成器的客户端名称，按名称排序
+func Launchers() []string {
+	names := make([]string, 0, len(launchers))
+	for name := range launchers {
+		if _, ok := configs[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// MigrateClientConfig 将src中各个客户端共有的设置迁移至dst
+// pidfile和日志文件等客户端独有的设置不会迁移
+func MigrateClientConfig(dst, src config.ClientConfig) error {
+	if err := dst.SetLocalAddr(src.LocalAddr()); err != nil {
+		return err
+	}
+	if err := dst.SetLocalPort(src.LocalPort()); err != nil {
+		return err
+	}
+	if err := dst.SetTimeout(src.Timeout()); err != nil {
+		return err
+	}
+	dst.SetFastOpen(src.FastOpen())
+	dst.SetUDPRelay(src.UDPRelay())
+	dst.SetVerbose(src.Verbose())
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package ssr
+
+import (
+	"testing"
+
+	"reflect"
+
+	"schannel-qt5/config"
+)
+
+// fakeConfig 用于测试的ClientConfig
+type fakeConfig struct {
+	addr, port, pidFile, logFile string
+	fastOpen, udpRelay, verbose  bool
+	timeout                      int
+}
+
+func (f *fakeConfig) LocalPort() string   { return f.port }
+func (f *fakeConfig) LocalAddr() string   { return f.addr }
+func (f *fakeConfig) FastOpen() bool      { return f.fastOpen }
+func (f *fakeConfig) PidFilePath() string { return f.pidFile }
+func (f *fakeConfig) UDPRelay() bool      { return f.udpRelay }
+func (f *fakeConfig) Timeout() int        { return f.timeout }
+func (f *fakeConfig) LogFilePath() string { return f.logFile }
+func (f *fakeConfig) Verbose() bool       { return f.verbose }
+
+func (f *fakeConfig) SetLocalPort(port string) error { f.port = port; return nil }
+func (f *fakeConfig) SetLocalAddr(addr string) error { f.addr = addr; return nil }
+func (f *fakeConfig) SetFastOpen(fop bool)           { f.fastOpen = fop }
+func (f *fakeConfig) SetPidFilePath(path string) error {
+	f.pidFile = path
+	return nil
+}
+func (f *fakeConfig) SetUDPRelay(udp bool)          { f.udpRelay = udp }
+func (f *fakeConfig) SetTimeout(seconds int) error  { f.timeout = seconds; return nil }
+func (f *fakeConfig) SetLogFilePath(p string) error { f.logFile = p; return nil }
+func (f *fakeConfig) SetVerbose(verbose bool)       { f.verbose = verbose }
+
+func (f *fakeConfig) Load(path string) error  { return nil }
+func (f *fakeConfig) Store(path string) error { return nil }
+
+func TestLaunchers(t *testing.T) {
+	maker := func() config.ClientConfig { return &fakeConfig{} }
+	launcher := func(*config.UserConfig) Launcher { return nil }
+	SetClientConfigMaker("test-b", maker)
+	SetLuancherMaker("test-b", launcher)
+	SetClientConfigMaker("test-a", maker)
+	SetLuancherMaker("test-a", launcher)
+	// 只注册了Launcher的客户端不应该出现
+	SetLuancherMaker("test-c", launcher)
+
+	want := []string{"test-a", "test-b"}
+	if names := Launchers(); !reflect.DeepEqual(names, want) {
+		t.Errorf("wrong launchers:\n\twant: %v\n\thave: %v\n", want, names)
+	}
+}
+
+func TestMigrateClientConfig(t *testing.T) {
+	src := &fakeConfig{
+		addr:     "0.0.0.0",
+		port:     "1081",
+		pidFile:  "/tmp/src.pid",
+		logFile:  "/tmp/src.log",
+		fastOpen: true,
+		udpRelay: true,
+		verbose:  true,
+		timeout:  120,
+	}
+	dst := &fakeConfig{pidFile: "/tmp/dst.pid"}
+
+	if err := MigrateClientConfig(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	want := &fakeConfig{
+		addr:     "0.0.0.0",
+		port:     "1081",
+		pidFile:  "/tmp/dst.pid",
+		fastOpen: true,
+		udpRelay: true,
+		verbose:  true,
+		timeout:  120,
+	}
+	if *dst != *want {
+		t.Errorf("wrong migrated config:\n\twant: %v\n\thave: %v\n", *want, *dst)
+	}
+}
@@ -0,0 +1,189 @@
+package ssr
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"schannel-qt5/procfs"
+)
+
+// State 客户端进程的状态
+type State int
+
+const (
+	// StateStopped 客户端没有运行
+	StateStopped State = iota
+	// StateRunning 客户端正在运行且监听了配置的地址和端口
+	StateRunning
+	// StateStale pidfile记录的进程已经退出、pid被其他进程复用或者进程没有监听配置的端口
+	StateStale
+	// StateForeign 配置的端口被其他进程占用
+	StateForeign
+)
+
+var (
+	// ErrStopped 客户端没有运行
+	ErrStopped = errors.New("client is stopped")
+	// ErrStale pidfile已经失效
+	ErrStale = errors.New("client pidfile is stale")
+	// ErrForeignProcess 端口被其他进程占用
+	ErrForeignProcess = errors.New("local port is used by another process")
+)
+
+func (s State) String() string {
+	switch s {
+	case StateStopped:
+		return "stopped"
+	case StateRunning:
+		return "running"
+	case StateStale:
+		return "stale"
+	case StateForeign:
+		return "foreign-process-on-port"
+	}
+
+	return "unknown"
+}
+
+// Err 客户端正在运行时返回nil，否则返回对应的错误，用于实现Launcher.IsRunning
+func (s State) Err() error {
+	switch s {
+	case StateRunning:
+		return nil
+	case StateStale:
+		return ErrStale
+	case StateForeign:
+		return ErrForeignProcess
+	}
+
+	return ErrStopped
+}
+
+// StateReporter 可以报告进程状态的Launcher
+type StateReporter interface {
+	State() (State, error)
+}
+
+// ProcessChecker 根据pidfile、/proc和端口占用情况判断客户端状态
+type ProcessChecker struct {
+	// 客户端的pidfile
+	PidFile string
+	// 客户端监听的地址和端口
+	Addr, Port string
+	// Match 判断进程的命令行参数是否属于客户端
+	Match func(cmdline []string) bool
+
+	fs procfs.FS
+}
+
+// NewProcessChecker 生成使用系统/proc的ProcessChecker
+func NewProcessChecker(pidFile, addr, port string, match func(cmdline []string) bool) *ProcessChecker {
+	return &ProcessChecker{
+		PidFile: pidFile,
+		Addr:    addr,
+		Port:    port,
+		Match:   match,
+		fs:      procfs.DefaultFS,
+	}
+}
+
+// State 返回客户端的状态，pidfile失效时会将其删除
+func (c *ProcessChecker) State() (State, error) {
+	port, err := strconv.Atoi(c.Port)
+	if err != nil {
+		return StateStopped, err
+	}
+	listeners, err := c.fs.Listeners(port)
+	if err != nil {
+		return StateStopped, err
+	}
+	matched := make([]procfs.Listener, 0, len(listeners))
+	for _, l := range listeners {
+		if l.Match(c.Addr) {
+			matched = append(matched, l)
+		}
+	}
+
+	proc, stale, err := c.readProcess()
+	if err != nil {
+		return StateStopped, err
+	} else if proc == nil {
+		if len(matched) != 0 {
+			return StateForeign, nil
+		} else if stale {
+			return StateStale, nil
+		}
+		return StateStopped, nil
+	}
+
+	inodes, err := c.fs.SocketInodes(proc.Pid)
+	if err != nil && !os.IsPermission(err) {
+		return StateStopped, err
+	}
+	for _, l := range matched {
+		if inodes != nil {
+			if inodes[l.Inode] {
+				return StateRunning, nil
+			}
+			continue
+		}
+
+		// 以root运行的客户端无法读取fd，此时只要端口不属于其他可读取的进程就认为属于客户端
+		if _, err := c.fs.FindOwner(l.Inode); err == procfs.ErrNoProcess {
+			return StateRunning, nil
+		}
+	}
+
+	if len(matched) != 0 {
+		return StateForeign, nil
+	}
+	// 进程存在但是没有监听端口，不删除仍然有效的pidfile
+	return StateStale, nil
+}
+
+// readProcess 读取pidfile对应的客户端进程，pidfile不存在时返回nil
+// 进程已经退出或者pid被复用时删除pidfile，返回nil和true
+func (c *ProcessChecker) readProcess() (*procfs.Process, bool, error) {
+	info, err := os.Stat(c.PidFile)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	data, err := ioutil.ReadFile(c.PidFile)
+	if err != nil {
+		return nil, false, err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, true, c.removeStale()
+	}
+	proc, err := c.fs.Process(pid)
+	if err == procfs.ErrNoProcess {
+		return nil, true, c.removeStale()
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	// 进程启动于pidfile写入之后说明pid被复用，系统启动时间只精确到秒
+	if proc.StartTime.After(info.ModTime().Add(time.Second)) || (c.Match != nil && !c.Match(proc.Cmdline)) {
+		return nil, true, c.removeStale()
+	}
+
+	return proc, false, nil
+}
+
+// removeStale 删除失效的pidfile
+// pidfile可能属于root，无法删除时只记录日志
+func (c *ProcessChecker) removeStale() error {
+	if err := os.Remove(c.PidFile); err != nil && !os.IsNotExist(err) {
+		logger.Printf("remove stale pidfile %s: %v\n", c.PidFile, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package ssr
+
+import (
+	"testing"
+
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+func TestProcessCheckerState(t *testing.T) {
+	SetLogger(log.New(ioutil.Discard, "", 0))
+	dir, err := ioutil.TempDir("", "ssr-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pidFile := filepath.Join(dir, "client.pid")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+	match := true
+	checker := NewProcessChecker(pidFile, "127.0.0.1", port, func([]string) bool { return match })
+
+	checkState := func(want State) {
+		t.Helper()
+		state, err := checker.State()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state != want {
+			t.Errorf("wrong state:\n\twant: %v\n\thave: %v\n", want, state)
+		}
+	}
+
+	// 没有pidfile但端口被本进程占用
+	checkState(StateForeign)
+
+	ioutil.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())), 0644)
+	checkState(StateRunning)
+
+	// 命令行不匹配时认为pid被复用，端口属于其他进程
+	match = false
+	checkState(StateForeign)
+	if _, err := os.Stat(pidFile); !os.IsNotExist(err) {
+		t.Errorf("stale pidfile wasn't removed\n")
+	}
+	match = true
+
+	l.Close()
+	ioutil.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())), 0644)
+	// 进程存在但没有监听端口
+	checkState(StateStale)
+
+	// 不存在的进程
+	ioutil.WriteFile(pidFile, []byte("999999999"), 0644)
+	checkState(StateStale)
+	checkState(StateStopped)
+}
@@ -0,0 +1,309 @@
+package ssr
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrSupervising 客户端已经在监管下运行
+	ErrSupervising = errors.New("client is already supervised")
+	// ErrNotSupervising 客户端没有在运行
+	ErrNotSupervising = errors.New("client is not supervised")
+	// ErrRestarting 客户端退出后正在等待重启
+	ErrRestarting = errors.New("client exited and is waiting for restart")
+)
+
+const (
+	// 默认的重启退避时间
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = time.Minute
+	// 子进程运行超过这个时间后退出，退避时间重置为最小值
+	backoffResetAfter = 30 * time.Second
+	// 发送SIGTERM后等待子进程退出的时间，超时后使用SIGKILL
+	stopTimeout = 5 * time.Second
+	// 最多保存的退出记录
+	maxExitRecords = 32
+)
+
+// ExitRecord 子进程的一次退出记录
+type ExitRecord struct {
+	// 退出时间
+	Time time.Time
+	// 进程运行的时间
+	Uptime time.Duration
+	// 退出码，被信号终止或者无法启动时为-1
+	Code int
+	// 进程无法启动或者异常退出时的错误
+	Err error
+}
+
+// Supervisor 将客户端作为前台子进程运行
+// 子进程意外退出后按指数退避时间重启，输出按行写入logger
+type Supervisor struct {
+	// 日志前缀，一般为客户端名称
+	name string
+	// 每次启动时生成新的子进程命令
+	newCmd func() *exec.Cmd
+
+	// 重启的退避时间范围
+	MinBackoff, MaxBackoff time.Duration
+
+	lock sync.Mutex
+	// 当前运行的子进程，等待重启时为nil
+	cmd *exec.Cmd
+	// 关闭stop通知监管goroutine结束，done在其退出后关闭
+	stop chan struct{}
+	done chan struct{}
+	// 最近的退出记录
+	records []ExitRecord
+}
+
+// NewSupervisor 生成Supervisor，newCmd在每次(重新)启动时调用
+func NewSupervisor(name string, newCmd func() *exec.Cmd) *Supervisor {
+	return &Supervisor{
+		name:       name,
+		newCmd:     newCmd,
+		MinBackoff: defaultMinBackoff,
+		MaxBackoff: defaultMaxBackoff,
+	}
+}
+
+// Start 启动子进程并开始监管，首次启动失败时直接返回错误
+func (s *Supervisor) Start() error {
+	s.lock.Lock()
+	if s.stop != nil {
+		s.lock.Unlock()
+		return ErrSupervising
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	s.stop, s.done = stop, done
+	s.lock.Unlock()
+
+	started := make(chan error, 1)
+	go s.supervise(started, stop, done)
+	if err := <-started; err != nil {
+		s.lock.Lock()
+		if s.stop == stop {
+			s.stop, s.done = nil, nil
+		}
+		s.lock.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// Restart 重新启动子进程
+func (s *Supervisor) Restart() error {
+	if err := s.Stop(); err != nil && err != ErrNotSupervising {
+		return err
+	}
+
+	return s.Start()
+}
+
+// Stop 停止监管并结束子进程
+func (s *Supervisor) Stop() error {
+	s.lock.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.lock.Unlock()
+
+	if stop == nil {
+		return ErrNotSupervising
+	}
+	close(stop)
+	<-done
+
+	return nil
+}
+
+// IsRunning 子进程正在运行返回nil
+func (s *Supervisor) IsRunning() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stop == nil {
+		return ErrNotSupervising
+	} else if s.cmd == nil {
+		return ErrRestarting
+	}
+
+	return nil
+}
+
+// ExitRecords 返回最近的子进程退出记录，按时间排序
+func (s *Supervisor) ExitRecords() []ExitRecord {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	records := make([]ExitRecord, len(s.records))
+	copy(records, s.records)
+	return records
+}
+
+// record 保存退出记录，需要持有lock
+func (s *Supervisor) record(r ExitRecord) {
+	s.records = append(s.records, r)
+	if len(s.records) > maxExitRecords {
+		s.records = s.records[len(s.records)-maxExitRecords:]
+	}
+}
+
+// spawn 启动新的子进程，stdout和stderr写入logger
+func (s *Supervisor) spawn() (*exec.Cmd, error) {
+	cmd := s.newCmd()
+	cmd.Stdout = newLineLogger(s.name + " stdout: ")
+	cmd.Stderr = newLineLogger(s.name + " stderr: ")
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	// 程序意外退出时不留下孤儿进程
+	cmd.SysProcAttr.Pdeathsig = syscall.SIGTERM
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	logger.Printf("%s: started, pid %d\n", s.name, cmd.Process.Pid)
+
+	return cmd, nil
+}
+
+// wait 等待子进程退出，退出后输出最后不完整的一行
+func wait(cmd *exec.Cmd) <-chan error {
+	exited := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		for _, w := range []interface{}{cmd.Stdout, cmd.Stderr} {
+			if l, ok := w.(*lineLogger); ok {
+				l.flush()
+			}
+		}
+		exited <- err
+	}()
+
+	return exited
+}
+
+// supervise 启动子进程并监管直到stop被关闭，首次启动的结果发送到started
+func (s *Supervisor) supervise(started chan<- error, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	// Pdeathsig在创建子进程的线程退出时触发，所以所有子进程都在这个锁定的线程中启动
+	// 不解除锁定，goroutine结束时子进程已经退出，线程随之销毁
+	runtime.LockOSThread()
+	cmd, err := s.spawn()
+	s.lock.Lock()
+	if err != nil {
+		s.record(ExitRecord{Time: time.Now(), Code: -1, Err: err})
+	} else {
+		s.cmd = cmd
+	}
+	s.lock.Unlock()
+	started <- err
+	if err != nil {
+		return
+	}
+
+	backoff := s.MinBackoff
+	for {
+		startTime := time.Now()
+		exited := wait(cmd)
+
+		stopped := false
+		select {
+		case err = <-exited:
+		case <-stop:
+			stopped = true
+			cmd.Process.Signal(syscall.SIGTERM)
+			select {
+			case err = <-exited:
+			case <-time.After(stopTimeout):
+				cmd.Process.Kill()
+				err = <-exited
+			}
+		}
+
+		code := cmd.ProcessState.ExitCode()
+		if code == 0 {
+			// 正常退出时不记录错误
+			err = nil
+		}
+		s.lock.Lock()
+		s.cmd = nil
+		s.record(ExitRecord{Time: time.Now(), Uptime: time.Since(startTime), Code: code, Err: err})
+		s.lock.Unlock()
+		logger.Printf("%s: exited with code %d: %v\n", s.name, code, err)
+		if stopped {
+			return
+		}
+
+		if time.Since(startTime) > backoffResetAfter {
+			backoff = s.MinBackoff
+		}
+		// 等待重启，启动失败时继续退避
+		for {
+			logger.Printf("%s: restart in %v\n", s.name, backoff)
+			select {
+			case <-stop:
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > s.MaxBackoff {
+				backoff = s.MaxBackoff
+			}
+
+			cmd, err = s.spawn()
+			s.lock.Lock()
+			if err != nil {
+				s.record(ExitRecord{Time: time.Now(), Code: -1, Err: err})
+			} else {
+				s.cmd = cmd
+			}
+			s.lock.Unlock()
+			if err == nil {
+				break
+			}
+			logger.Printf("%s: restart failed: %v\n", s.name, err)
+		}
+	}
+}
+
+// lineLogger 将写入的数据按行加上前缀写入logger
+type lineLogger struct {
+	prefix string
+	buf    []byte
+}
+
+func newLineLogger(prefix string) *lineLogger {
+	return &lineLogger{prefix: prefix}
+}
+
+// flush 输出缓冲中没有换行符结尾的数据，子进程退出后调用
+func (l *lineLogger) flush() {
+	if len(l.buf) != 0 {
+		logger.Printf("%s%s\n", l.prefix, bytes.TrimRight(l.buf, "\r"))
+		l.buf = nil
+	}
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+		logger.Printf("%s%s\n", l.prefix, bytes.TrimRight(l.buf[:i], "\r"))
+		l.buf = l.buf[i+1:]
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,129 @@
+package ssr
+
+import (
+	"testing"
+
+	"bytes"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syncBuffer 可以在多个goroutine中使用的Buffer
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+// waitFor 在timeout内等待cond为true
+func waitFor(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return cond()
+}
+
+func TestSupervisorRestart(t *testing.T) {
+	out := &syncBuffer{}
+	SetLogger(log.New(out, "", 0))
+
+	s := NewSupervisor("test", func() *exec.Cmd {
+		return exec.Command("sh", "-c", "echo hello; echo oops >&2; printf tail; exit 3")
+	})
+	s.MinBackoff = 10 * time.Millisecond
+	s.MaxBackoff = 20 * time.Millisecond
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != ErrSupervising {
+		t.Errorf("start twice didn't fail\n")
+	}
+
+	// 至少重启两次
+	if !waitFor(5*time.Second, func() bool { return len(s.ExitRecords()) >= 3 }) {
+		t.Fatalf("client didn't restart, records: %v\n", s.ExitRecords())
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.IsRunning(); err != ErrNotSupervising {
+		t.Errorf("client is running after stop\n")
+	}
+
+	for _, r := range s.ExitRecords() {
+		if r.Code != 3 {
+			t.Errorf("wrong exit code: %v\n", r.Code)
+		}
+	}
+	logs := out.String()
+	// 没有换行符结尾的输出在退出后写入
+	for _, line := range []string{"test stdout: hello\n", "test stderr: oops\n", "test stdout: tail\n"} {
+		if !strings.Contains(logs, line) {
+			t.Errorf("output not logged: %s\nlogs:\n%s\n", line, logs)
+		}
+	}
+}
+
+func TestSupervisorStop(t *testing.T) {
+	SetLogger(log.New(&syncBuffer{}, "", 0))
+
+	s := NewSupervisor("test", func() *exec.Cmd {
+		return exec.Command("sleep", "100")
+	})
+	if err := s.Stop(); err != ErrNotSupervising {
+		t.Errorf("stop before start didn't fail\n")
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.IsRunning(); err != nil {
+		t.Errorf("client is not running: %v\n", err)
+	}
+	if err := s.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	records := s.ExitRecords()
+	if len(records) != 2 {
+		t.Fatalf("wrong records: %v\n", records)
+	}
+	for _, r := range records {
+		if r.Code != -1 || r.Err == nil {
+			t.Errorf("stopped client should be killed by signal: %v\n", r)
+		}
+	}
+}
+
+func TestSupervisorStartFailed(t *testing.T) {
+	s := NewSupervisor("test", func() *exec.Cmd {
+		return exec.Command("/nonexistent/ssr-client")
+	})
+	if err := s.Start(); err == nil {
+		t.Errorf("start nonexistent bin didn't fail\n")
+	}
+	if err := s.IsRunning(); err != ErrNotSupervising {
+		t.Errorf("failed client is running\n")
+	}
+}
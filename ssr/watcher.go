@@ -0,0 +1,298 @@
+package ssr
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType 客户端状态事件的类型
+type EventType int
+
+const (
+	// EventStarting 正在启动客户端
+	EventStarting EventType = iota
+	// EventRunning 客户端正在运行且代理可用
+	EventRunning
+	// EventDegraded 客户端正在运行但代理不可用，或者客户端退出后正在等待重启
+	EventDegraded
+	// EventStopped 客户端已停止
+	EventStopped
+	// EventCrashed 客户端在没有调用Stop的情况下退出，或者启动失败
+	EventCrashed
+)
+
+func (e EventType) String() string {
+	switch e {
+	case EventStarting:
+		return "starting"
+	case EventRunning:
+		return "running"
+	case EventDegraded:
+		return "degraded"
+	case EventStopped:
+		return "stopped"
+	case EventCrashed:
+		return "crashed"
+	}
+
+	return "unknown"
+}
+
+// Event 客户端状态事件
+type Event struct {
+	Type EventType
+	Time time.Time
+	// Degraded和Crashed时的错误信息
+	Err error
+	// 设置了Checker时Running和Degraded附带的检查结果
+	Result *CheckResult
+}
+
+const (
+	// 默认检查客户端是否运行的间隔
+	defaultPollInterval = 2 * time.Second
+	// 默认检查代理是否可用的间隔
+	defaultCheckInterval = time.Minute
+	// 检查代理是否可用的超时时间
+	defaultCheckTimeout = 5 * time.Second
+	// 启动后等待客户端开始运行的时间，daemon模式的客户端在pidfile写入和端口监听前就会返回
+	defaultStartTimeout = 10 * time.Second
+	// 未被读取的事件数量超过这个值后丢弃新的事件
+	eventBufferSize = 16
+)
+
+// StateWatcher 监视Launcher的状态，状态改变时通过channel发送事件
+// 通过StateWatcher启动和停止客户端以区分正常停止和崩溃
+type StateWatcher struct {
+	launcher Launcher
+
+	// PollInterval 检查客户端是否运行的间隔
+	PollInterval time.Duration
+	// CheckInterval 客户端运行时检查代理是否可用的间隔
+	CheckInterval time.Duration
+	// CheckTimeout 检查代理是否可用的超时时间
+	CheckTimeout time.Duration
+	// StartTimeout Start之后客户端没有运行时保持Starting状态的时间，超时后视为崩溃
+	StartTimeout time.Duration
+	// Checker 不为nil时代替Launcher.ConnectionCheck检查代理，结果附带在事件中
+	Checker func(timeout time.Duration) *CheckResult
+
+	events chan Event
+
+	lock sync.Mutex
+	// 上一次发送的事件类型和错误信息
+	last    EventType
+	lastErr string
+	// 客户端应当处于运行状态
+	expectRunning bool
+	// 启动中的客户端需要在这个时间前开始运行，为零值时不在启动中
+	startDeadline time.Time
+	// Close之后不再发送事件
+	closed bool
+	// 上一次检查代理的时间
+	lastCheck time.Time
+	// 关闭stop通知watch goroutine结束
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewStateWatcher 生成监视launcher的StateWatcher，需要调用Watch开始监视
+func NewStateWatcher(launcher Launcher) *StateWatcher {
+	return &StateWatcher{
+		launcher:      launcher,
+		PollInterval:  defaultPollInterval,
+		CheckInterval: defaultCheckInterval,
+		CheckTimeout:  defaultCheckTimeout,
+		StartTimeout:  defaultStartTimeout,
+		events:        make(chan Event, eventBufferSize),
+		last:          -1,
+	}
+}
+
+// Events 返回接收事件的channel，Close后channel被关闭
+func (w *StateWatcher) Events() <-chan Event {
+	return w.events
+}
+
+// Watch 开始在后台监视客户端，并立即发送一次当前状态
+func (w *StateWatcher) Watch() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.stop != nil || w.closed {
+		return
+	}
+	w.expectRunning = w.launcher.IsRunning() == nil
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.watch(w.stop, w.done)
+}
+
+// Close 停止监视并关闭事件channel，不会停止客户端
+// 之后的Start、Stop和Refresh只操作客户端，不再发送事件；多次调用时不做任何操作
+func (w *StateWatcher) Close() {
+	w.lock.Lock()
+	if w.closed {
+		w.lock.Unlock()
+		return
+	}
+	stop, done := w.stop, w.done
+	w.stop = nil
+	w.lock.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	w.lock.Lock()
+	w.closed = true
+	close(w.events)
+	w.lock.Unlock()
+}
+
+// Start 启动客户端，依次发送Starting和启动结果
+// 客户端在StartTimeout内开始运行时发送Running或Degraded，否则发送Crashed
+func (w *StateWatcher) Start() error {
+	w.lock.Lock()
+	w.expectRunning = true
+	w.startDeadline = time.Time{}
+	w.emit(Event{Type: EventStarting})
+	w.lock.Unlock()
+
+	if err := w.launcher.Start(); err != nil {
+		w.lock.Lock()
+		w.expectRunning = false
+		w.emit(Event{Type: EventCrashed, Err: err})
+		w.lock.Unlock()
+		return err
+	}
+
+	w.lock.Lock()
+	w.startDeadline = time.Now().Add(w.StartTimeout)
+	w.lock.Unlock()
+	w.Refresh()
+	return nil
+}
+
+// Stop 停止客户端并发送Stopped
+func (w *StateWatcher) Stop() error {
+	w.lock.Lock()
+	w.expectRunning = false
+	w.startDeadline = time.Time{}
+	w.lock.Unlock()
+
+	err := w.launcher.Stop()
+	w.Refresh()
+	return err
+}
+
+// Refresh 立即检查客户端状态和代理是否可用
+func (w *StateWatcher) Refresh() {
+	w.poll(true)
+}
+
+// watch 定时检查客户端状态直到stop被关闭
+func (w *StateWatcher) watch(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	w.poll(true)
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			w.poll(false)
+		}
+	}
+}
+
+// poll 检查客户端状态，状态改变时发送事件
+// check为true或者距离上次检查超过CheckInterval时检查代理是否可用
+func (w *StateWatcher) poll(check bool) {
+	if err := w.launcher.IsRunning(); err != nil {
+		w.lock.Lock()
+		crashed := false
+		switch {
+		case w.expectRunning && time.Now().Before(w.startDeadline):
+			// 客户端还在启动中，保持Starting状态
+		case w.expectRunning && err == ErrRestarting:
+			// supervisor或systemd正在等待重启客户端，不视为崩溃
+			w.emit(Event{Type: EventDegraded, Err: err})
+		case w.expectRunning:
+			// 客户端意外退出或者没有在StartTimeout内启动，之后的检查视为停止状态
+			w.expectRunning = false
+			w.startDeadline = time.Time{}
+			w.emit(Event{Type: EventCrashed, Err: err})
+			crashed = true
+		case w.last != EventCrashed:
+			w.emit(Event{Type: EventStopped})
+		}
+		w.lock.Unlock()
+
+		// 停止Hook可能需要运行外部命令，不持有lock
+		if h, ok := w.launcher.(crashHandler); ok && crashed {
+			h.clientCrashed()
+		}
+		return
+	}
+
+	w.lock.Lock()
+	// 客户端可能在程序外部启动
+	w.expectRunning = true
+	w.startDeadline = time.Time{}
+	recovered := w.last == EventCrashed
+	needCheck := check || w.last == EventStarting || w.last == EventStopped ||
+		w.last == EventCrashed || time.Since(w.lastCheck) >= w.CheckInterval
+	w.lock.Unlock()
+
+	// 崩溃的客户端被重新启动，恢复崩溃时停止的Hook
+	if h, ok := w.launcher.(crashHandler); ok && recovered {
+		h.clientRecovered()
+	}
+	if !needCheck {
+		return
+	}
+
+	var result *CheckResult
+	var err error
+	if w.Checker != nil {
+		result = w.Checker(w.CheckTimeout)
+		err = result.Err()
+	} else {
+		err = w.launcher.ConnectionCheck(w.CheckTimeout)
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.lastCheck = time.Now()
+	if err != nil {
+		w.emit(Event{Type: EventDegraded, Err: err, Result: result})
+		return
+	}
+	w.emit(Event{Type: EventRunning, Result: result})
+}
+
+// emit 状态改变时发送事件，需要持有lock
+// Degraded的错误信息改变或者带有新的检查结果时也会发送，Close之后不发送
+func (w *StateWatcher) emit(e Event) {
+	if w.closed {
+		return
+	}
+	errInfo := ""
+	if e.Err != nil {
+		errInfo = e.Err.Error()
+	}
+	if e.Type == w.last && e.Type != EventStarting && errInfo == w.lastErr && e.Result == nil {
+		return
+	}
+	w.last, w.lastErr = e.Type, errInfo
+	e.Time = time.Now()
+
+	select {
+	case w.events <- e:
+	default:
+		logger.Printf("state watcher: drop event %v\n", e.Type)
+	}
+}
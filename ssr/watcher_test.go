@@ -0,0 +1,183 @@
+package ssr
+
+import (
+	"testing"
+
+	"errors"
+	"sync"
+	"time"
+)
+
+// fakeLauncher 用于测试的Launcher，可以模拟崩溃和代理不可用
+type fakeLauncher struct {
+	lock     sync.Mutex
+	running  bool
+	checkErr error
+	// 模拟supervisor等待重启客户端
+	restarting bool
+}
+
+func (f *fakeLauncher) Start() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.running = true
+	return nil
+}
+
+func (f *fakeLauncher) Restart() error { return f.Start() }
+
+func (f *fakeLauncher) Stop() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.running = false
+	return nil
+}
+
+func (f *fakeLauncher) IsRunning() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.restarting {
+		return ErrRestarting
+	}
+	if !f.running {
+		return ErrStopped
+	}
+	return nil
+}
+
+func (f *fakeLauncher) ConnectionCheck(timeout time.Duration) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.checkErr
+}
+
+func (f *fakeLauncher) set(running bool, checkErr error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.running = running
+	f.checkErr = checkErr
+}
+
+// nextEvent 读取下一个事件，超时后测试失败
+func nextEvent(t *testing.T, w *StateWatcher) Event {
+	t.Helper()
+	select {
+	case e := <-w.Events():
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatalf("wait event timeout\n")
+	}
+	return Event{}
+}
+
+func TestStateWatcher(t *testing.T) {
+	launcher := &fakeLauncher{}
+	w := NewStateWatcher(launcher)
+	w.PollInterval = 10 * time.Millisecond
+	w.CheckInterval = 10 * time.Millisecond
+	w.Watch()
+	defer w.Close()
+
+	expect := func(want EventType) {
+		t.Helper()
+		if e := nextEvent(t, w); e.Type != want {
+			t.Errorf("wrong event:\n\twant: %v\n\thave: %v\n", want, e.Type)
+		}
+	}
+
+	expect(EventStopped)
+
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	expect(EventStarting)
+	expect(EventRunning)
+
+	launcher.set(true, errors.New("timeout"))
+	expect(EventDegraded)
+
+	// supervisor等待重启时不视为崩溃
+	launcher.lock.Lock()
+	launcher.restarting = true
+	launcher.lock.Unlock()
+	expect(EventDegraded)
+	launcher.lock.Lock()
+	launcher.restarting = false
+	launcher.lock.Unlock()
+
+	// 客户端意外退出
+	launcher.set(false, nil)
+	expect(EventCrashed)
+
+	// 客户端被重新启动
+	launcher.set(true, nil)
+	expect(EventRunning)
+
+	if err := w.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	expect(EventStopped)
+}
+
+// daemonLauncher 模拟daemon模式的客户端，Start返回时还没有开始运行
+type daemonLauncher struct {
+	fakeLauncher
+}
+
+func (d *daemonLauncher) Start() error { return nil }
+
+func TestStateWatcherStartTimeout(t *testing.T) {
+	launcher := &daemonLauncher{}
+	w := NewStateWatcher(launcher)
+	w.PollInterval = 10 * time.Millisecond
+	w.StartTimeout = 200 * time.Millisecond
+	w.Watch()
+	defer w.Close()
+
+	if e := nextEvent(t, w); e.Type != EventStopped {
+		t.Fatalf("wrong event: %v\n", e.Type)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, w); e.Type != EventStarting {
+		t.Fatalf("wrong event: %v\n", e.Type)
+	}
+	// 启动中的客户端没有运行时不视为崩溃
+	time.Sleep(50 * time.Millisecond)
+	launcher.set(true, nil)
+	if e := nextEvent(t, w); e.Type != EventRunning {
+		t.Errorf("client started in time should be running: %v %v\n", e.Type, e.Err)
+	}
+
+	// 超过StartTimeout仍然没有运行
+	launcher.set(false, nil)
+	nextEvent(t, w)
+	start := time.Now()
+	w.Start()
+	if e := nextEvent(t, w); e.Type != EventStarting {
+		t.Fatalf("wrong event: %v\n", e.Type)
+	}
+	if e := nextEvent(t, w); e.Type != EventCrashed || time.Since(start) < w.StartTimeout {
+		t.Errorf("client should crash after StartTimeout: %v %v\n", e.Type, time.Since(start))
+	}
+}
+
+func TestStateWatcherClose(t *testing.T) {
+	launcher := &fakeLauncher{}
+	w := NewStateWatcher(launcher)
+	w.Watch()
+	w.Close()
+	w.Close()
+
+	// Close之后操作客户端不会向已关闭的channel发送事件
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	w.Refresh()
+	for range w.Events() {
+	}
+}
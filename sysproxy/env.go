@@ -0,0 +1,96 @@
+package sysproxy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"schannel-qt5/atomicfile"
+	"schannel-qt5/config"
+)
+
+const (
+	// Snapshot中保存文件内容的key
+	envContentKey = "content"
+	// Snapshot中记录文件是否存在的key
+	envExistsKey = "exists"
+)
+
+func init() {
+	SetBackendMaker(config.SystemProxyEnv, func(c *config.SystemProxyConfig) (Backend, error) {
+		path, err := c.EnvFileAbsPath()
+		if err != nil {
+			return nil, err
+		}
+		return NewEnvBackend(path), nil
+	})
+}
+
+// EnvBackend 将代理写入可以被shell source的环境变量文件
+// 环境变量无法使用PAC，只支持none和manual
+type EnvBackend struct {
+	path string
+}
+
+// NewEnvBackend 代理设置写入path
+func NewEnvBackend(path string) *EnvBackend {
+	return &EnvBackend{path: path}
+}
+
+// Save 保存文件原来的内容
+func (e *EnvBackend) Save() (Snapshot, error) {
+	data, err := ioutil.ReadFile(e.path)
+	if os.IsNotExist(err) {
+		return Snapshot{envExistsKey: "false"}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return Snapshot{envExistsKey: "true", envContentKey: string(data)}, nil
+}
+
+// Restore 恢复文件原来的内容，原来不存在时删除文件
+func (e *EnvBackend) Restore(snapshot Snapshot) error {
+	if snapshot[envExistsKey] != "true" {
+		err := os.Remove(e.path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	return atomicfile.WriteFile(e.path, []byte(snapshot[envContentKey]), 0644)
+}
+
+// Apply 写入代理使用的环境变量
+func (e *EnvBackend) Apply(s *Settings) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString("# generated by schannel-qt5\n")
+
+	switch s.Mode {
+	case ModeNone:
+		buf.WriteString("unset all_proxy ALL_PROXY http_proxy HTTP_PROXY https_proxy HTTPS_PROXY no_proxy NO_PROXY\n")
+	case ModeManual:
+		if s.SOCKS != "" {
+			writeEnv(buf, "socks5://"+s.SOCKS, "all_proxy", "ALL_PROXY")
+		}
+		if s.HTTP != "" {
+			writeEnv(buf, "http://"+s.HTTP, "http_proxy", "HTTP_PROXY", "https_proxy", "HTTPS_PROXY")
+		}
+		if len(s.IgnoreHosts) != 0 {
+			writeEnv(buf, strings.Join(s.IgnoreHosts, ","), "no_proxy", "NO_PROXY")
+		}
+	default:
+		return ErrUnsupportedMode
+	}
+
+	return atomicfile.WriteFile(e.path, buf.Bytes(), 0644)
+}
+
+// writeEnv 将value导出为names中的每个环境变量
+func writeEnv(buf *bytes.Buffer, value string, names ...string) {
+	for _, name := range names {
+		buf.WriteString("export " + name + "='" + value + "'\n")
+	}
+}
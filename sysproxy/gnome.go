@@ -0,0 +1,134 @@
+package sysproxy
+
+import (
+	"strings"
+
+	"schannel-qt5/config"
+)
+
+const (
+	gnomeProxySchema = "org.gnome.system.proxy"
+)
+
+// gnomeKeys 需要保存和恢复的gsettings，格式为"schema key"
+// mode放在最后，恢复时最后切换代理模式
+var gnomeKeys = []string{
+	gnomeProxySchema + " autoconfig-url",
+	gnomeProxySchema + " ignore-hosts",
+	gnomeProxySchema + ".http host",
+	gnomeProxySchema + ".http port",
+	gnomeProxySchema + ".https host",
+	gnomeProxySchema + ".https port",
+	gnomeProxySchema + ".socks host",
+	gnomeProxySchema + ".socks port",
+	gnomeProxySchema + " mode",
+}
+
+func init() {
+	SetBackendMaker(config.SystemProxyGnome, func(*config.SystemProxyConfig) (Backend, error) {
+		return NewGnomeBackend(execRunner), nil
+	})
+}
+
+// GnomeBackend 使用gsettings设置GNOME及其衍生桌面的系统代理
+type GnomeBackend struct {
+	run Runner
+}
+
+// NewGnomeBackend 使用run运行gsettings
+func NewGnomeBackend(run Runner) *GnomeBackend {
+	return &GnomeBackend{run: run}
+}
+
+// Save 保存gnomeKeys的值，值为gsettings输出的GVariant文本
+func (g *GnomeBackend) Save() (Snapshot, error) {
+	snapshot := make(Snapshot, len(gnomeKeys))
+	for _, key := range gnomeKeys {
+		args := append([]string{"get"}, strings.Fields(key)...)
+		out, err := g.run("gsettings", args...)
+		if err != nil {
+			return nil, err
+		}
+		snapshot[key] = strings.TrimSpace(string(out))
+	}
+
+	return snapshot, nil
+}
+
+// Restore 按照gnomeKeys的顺序恢复设置
+func (g *GnomeBackend) Restore(snapshot Snapshot) error {
+	for _, key := range gnomeKeys {
+		value, ok := snapshot[key]
+		if !ok {
+			continue
+		}
+		if err := g.set(key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Apply 设置系统代理，没有使用的代理类型会被清空
+func (g *GnomeBackend) Apply(s *Settings) error {
+	switch s.Mode {
+	case ModeNone, ModeManual, ModeAuto:
+	default:
+		return ErrUnsupportedMode
+	}
+
+	values := make(map[string]string, len(gnomeKeys))
+	values[gnomeProxySchema+" mode"] = gvariantString(s.Mode)
+	values[gnomeProxySchema+" autoconfig-url"] = gvariantString(s.PACURL)
+	values[gnomeProxySchema+" ignore-hosts"] = gvariantStrings(s.IgnoreHosts)
+
+	proxies := map[string]string{
+		".http":  s.HTTP,
+		".https": s.HTTP,
+		".socks": s.SOCKS,
+	}
+	for schema, hostport := range proxies {
+		host, port := splitHostPort(hostport)
+		if port == "" {
+			port = "0"
+		}
+		values[gnomeProxySchema+schema+" host"] = gvariantString(host)
+		values[gnomeProxySchema+schema+" port"] = port
+	}
+
+	for _, key := range gnomeKeys {
+		if err := g.set(key, values[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// set 运行gsettings set schema key value
+func (g *GnomeBackend) set(key, value string) error {
+	args := append([]string{"set"}, strings.Fields(key)...)
+	args = append(args, value)
+	_, err := g.run("gsettings", args...)
+	return err
+}
+
+// gvariantString 将s转换为GVariant字符串
+func gvariantString(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `'`, `\'`, -1)
+	return "'" + s + "'"
+}
+
+// gvariantStrings 将list转换为GVariant字符串数组
+func gvariantStrings(list []string) string {
+	if len(list) == 0 {
+		return "@as []"
+	}
+
+	res := make([]string, len(list))
+	for i, s := range list {
+		res[i] = gvariantString(s)
+	}
+	return "[" + strings.Join(res, ", ") + "]"
+}
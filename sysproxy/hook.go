@@ -0,0 +1,106 @@
+package sysproxy
+
+import (
+	"errors"
+
+	"schannel-qt5/config"
+	"schannel-qt5/ssr"
+)
+
+var (
+	// ErrHTTPProxyDisabled 使用http类型的系统代理时没有启用内置http代理
+	ErrHTTPProxyDisabled = errors.New("built-in http proxy is not enabled")
+	// ErrPACDisabled 使用pac类型的系统代理时没有启用本地PAC服务
+	ErrPACDisabled = errors.New("local pac server is not enabled")
+)
+
+// 没有设置ignore_hosts时不使用代理的主机
+var defaultIgnoreHosts = []string{"localhost", "127.0.0.0/8", "::1"}
+
+func init() {
+	// 设置了system_proxy.backend时随客户端一起设置和恢复
+	ssr.SetHookMaker("system-proxy", ssr.HookMaker(newHook))
+}
+
+// Hook 客户端启动时设置系统代理，停止时恢复
+type Hook struct {
+	conf    *config.UserConfig
+	manager *Manager
+}
+
+// newHook 这个函数供ssr.HookMaker调用，没有启用系统代理时返回nil
+func newHook(c *config.UserConfig) ssr.Hook {
+	if !c.SystemProxy.Enabled() {
+		return nil
+	}
+
+	return &Hook{conf: c}
+}
+
+// Start 设置系统代理
+func (h *Hook) Start() error {
+	if h.manager != nil {
+		return nil
+	}
+
+	settings, err := NewSettings(h.conf)
+	if err != nil {
+		return err
+	}
+	backend, err := NewBackend(h.conf.SystemProxy.Backend, &h.conf.SystemProxy)
+	if err != nil {
+		return err
+	}
+
+	manager := NewManager(backend, settings)
+	// 程序异常退出后，下次启动时恢复之前的系统代理
+	manager.Persist(&h.conf.SystemProxy)
+	if err := manager.Apply(); err != nil {
+		return err
+	}
+	h.manager = manager
+	return nil
+}
+
+// Stop 恢复设置之前的系统代理
+func (h *Hook) Stop() error {
+	if h.manager == nil {
+		return nil
+	}
+
+	err := h.manager.Restore()
+	h.manager = nil
+	return err
+}
+
+// NewSettings 根据用户配置中的代理类型生成系统代理设置
+func NewSettings(c *config.UserConfig) (*Settings, error) {
+	if err := c.SystemProxy.Valid(); err != nil {
+		return nil, err
+	}
+
+	s := &Settings{IgnoreHosts: c.SystemProxy.IgnoreHosts}
+	if len(s.IgnoreHosts) == 0 {
+		s.IgnoreHosts = defaultIgnoreHosts
+	}
+
+	switch c.SystemProxy.ProxyMode() {
+	case config.SystemProxySOCKS:
+		s.Mode = ModeManual
+		s.SOCKS = ssr.SocksAddr(c.SSRClientConfig)
+	case config.SystemProxyHTTP:
+		if !c.HTTPProxyEnabled() {
+			return nil, ErrHTTPProxyDisabled
+		}
+		s.Mode = ModeManual
+		s.HTTP = c.HTTPProxyHostPort()
+	case config.SystemProxyPAC:
+		if !c.PAC.Enabled() {
+			return nil, ErrPACDisabled
+		}
+		s.Mode = ModeAuto
+		s.PACURL = c.PAC.URL()
+	}
+
+	return s, nil
+}
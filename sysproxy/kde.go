@@ -0,0 +1,121 @@
+package sysproxy
+
+import (
+	"strings"
+
+	"schannel-qt5/config"
+)
+
+const (
+	kdeConfigFile  = "kioslaverc"
+	kdeConfigGroup = "Proxy Settings"
+)
+
+// kdeKeys 需要保存和恢复的kioslaverc设置，ProxyType放在最后
+var kdeKeys = []string{
+	"Proxy Config Script",
+	"NoProxyFor",
+	"httpProxy",
+	"httpsProxy",
+	"socksProxy",
+	"ProxyType",
+}
+
+// KDE的ProxyType
+var kdeProxyTypes = map[string]string{
+	ModeNone:   "0",
+	ModeManual: "1",
+	ModeAuto:   "2",
+}
+
+func init() {
+	SetBackendMaker(config.SystemProxyKDE, func(*config.SystemProxyConfig) (Backend, error) {
+		return NewKDEBackend(execRunner), nil
+	})
+}
+
+// KDEBackend 使用kreadconfig5和kwriteconfig5设置KDE的系统代理
+type KDEBackend struct {
+	run Runner
+}
+
+// NewKDEBackend 使用run运行kreadconfig5、kwriteconfig5和dbus-send
+func NewKDEBackend(run Runner) *KDEBackend {
+	return &KDEBackend{run: run}
+}
+
+// Save 保存kdeKeys的值
+func (k *KDEBackend) Save() (Snapshot, error) {
+	snapshot := make(Snapshot, len(kdeKeys))
+	for _, key := range kdeKeys {
+		out, err := k.run("kreadconfig5", "--file", kdeConfigFile, "--group", kdeConfigGroup, "--key", key)
+		if err != nil {
+			return nil, err
+		}
+		snapshot[key] = strings.TrimSpace(string(out))
+	}
+
+	return snapshot, nil
+}
+
+// Restore 按照kdeKeys的顺序恢复设置
+func (k *KDEBackend) Restore(snapshot Snapshot) error {
+	for _, key := range kdeKeys {
+		value, ok := snapshot[key]
+		if !ok {
+			continue
+		}
+		if err := k.set(key, value); err != nil {
+			return err
+		}
+	}
+
+	k.notify()
+	return nil
+}
+
+// Apply 设置系统代理，没有使用的代理类型会被清空
+func (k *KDEBackend) Apply(s *Settings) error {
+	values := map[string]string{
+		"Proxy Config Script": s.PACURL,
+		"NoProxyFor":          strings.Join(s.IgnoreHosts, ","),
+		"httpProxy":           kdeProxy("http", s.HTTP),
+		"httpsProxy":          kdeProxy("http", s.HTTP),
+		"socksProxy":          kdeProxy("socks", s.SOCKS),
+		"ProxyType":           kdeProxyTypes[s.Mode],
+	}
+	if values["ProxyType"] == "" {
+		return ErrUnsupportedMode
+	}
+
+	for _, key := range kdeKeys {
+		if err := k.set(key, values[key]); err != nil {
+			return err
+		}
+	}
+
+	k.notify()
+	return nil
+}
+
+// set 运行kwriteconfig5写入设置
+func (k *KDEBackend) set(key, value string) error {
+	_, err := k.run("kwriteconfig5", "--file", kdeConfigFile, "--group", kdeConfigGroup, "--key", key, value)
+	return err
+}
+
+// notify 通知KIO重新读取代理设置，失败时新的设置在下次启动程序时生效
+func (k *KDEBackend) notify() {
+	k.run("dbus-send", "--type=signal", "/KIO/Scheduler",
+		"org.kde.KIO.Scheduler.reparseSlaveConfiguration", "string:")
+}
+
+// kdeProxy 返回kioslaverc中的代理格式"scheme://host port"
+func kdeProxy(scheme, hostport string) string {
+	if hostport == "" {
+		return ""
+	}
+
+	host, port := splitHostPort(hostport)
+	return scheme + "://" + host + " " + port
+}
@@ -0,0 +1,128 @@
+package sysproxy
+
+import (
+	"sync"
+
+	"schannel-qt5/config"
+	"schannel-qt5/ssr"
+)
+
+var (
+	// 已经设置了系统代理的Manager，程序退出时需要恢复
+	activeLock sync.Mutex
+	active     = make(map[*Manager]struct{})
+)
+
+// Manager 设置系统代理并保存之前的设置，用于恢复
+type Manager struct {
+	backend  Backend
+	settings *Settings
+
+	// 不为nil时将之前的系统代理记录到状态文件
+	persist *config.SystemProxyConfig
+
+	lock    sync.Mutex
+	saved   Snapshot
+	applied bool
+}
+
+// NewManager 使用backend设置settings给出的系统代理
+func NewManager(backend Backend, settings *Settings) *Manager {
+	return &Manager{
+		backend:  backend,
+		settings: settings,
+	}
+}
+
+// Persist 设置后Apply会将之前的系统代理记录到状态文件，Restore后删除记录
+// 程序没有恢复系统代理就退出时，下次启动由RestoreSaved根据conf恢复
+func (m *Manager) Persist(conf *config.SystemProxyConfig) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	c := *conf
+	m.persist = &c
+}
+
+// Apply 保存当前的系统代理后进行设置，已经设置时不做任何操作
+// 设置失败时会尝试恢复之前的设置
+func (m *Manager) Apply() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.applied {
+		return nil
+	}
+
+	saved, err := m.backend.Save()
+	if err != nil {
+		return err
+	}
+	if err := m.backend.Apply(m.settings); err != nil {
+		m.backend.Restore(saved)
+		return err
+	}
+	m.saved = saved
+	m.applied = true
+	if m.persist != nil {
+		if err := saveSnapshot(m.persist, saved); err != nil {
+			ssr.Logger().Printf("sysproxy: save snapshot: %v\n", err)
+		}
+	}
+
+	activeLock.Lock()
+	active[m] = struct{}{}
+	activeLock.Unlock()
+	return nil
+}
+
+// Restore 恢复Apply之前的系统代理，没有设置时不做任何操作
+func (m *Manager) Restore() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if !m.applied {
+		return nil
+	}
+
+	if err := m.backend.Restore(m.saved); err != nil {
+		return err
+	}
+	m.saved = nil
+	m.applied = false
+	if m.persist != nil {
+		if err := removeSnapshot(m.persist); err != nil {
+			ssr.Logger().Printf("sysproxy: remove snapshot: %v\n", err)
+		}
+	}
+
+	activeLock.Lock()
+	delete(active, m)
+	activeLock.Unlock()
+	return nil
+}
+
+// Applied 系统代理是否已经被设置
+func (m *Manager) Applied() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.applied
+}
+
+// RestoreAll 恢复所有Manager设置的系统代理，在程序退出前调用，返回第一个错误
+func RestoreAll() error {
+	activeLock.Lock()
+	managers := make([]*Manager, 0, len(active))
+	for m := range active {
+		managers = append(managers, m)
+	}
+	activeLock.Unlock()
+
+	var res error
+	for _, m := range managers {
+		if err := m.Restore(); err != nil && res == nil {
+			res = err
+		}
+	}
+
+	return res
+}
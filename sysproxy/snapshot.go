@@ -0,0 +1,127 @@
+package sysproxy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"schannel-qt5/atomicfile"
+	"schannel-qt5/config"
+	"schannel-qt5/xdg"
+)
+
+// 记录设置之前的系统代理的状态文件
+const snapshotFileName = "sysproxy-snapshot.json"
+
+// 保护状态文件的读写
+var snapshotLock sync.Mutex
+
+// savedProxy 状态文件中的一项，包含恢复系统代理需要的Backend配置
+type savedProxy struct {
+	Config   config.SystemProxyConfig `json:"config"`
+	Snapshot Snapshot                 `json:"snapshot"`
+}
+
+// snapshotPath 返回状态文件的路径：$XDG_STATE_HOME/schannel-qt5/sysproxy-snapshot.json
+func snapshotPath() (string, error) {
+	return xdg.StateFile(snapshotFileName)
+}
+
+// loadSaved 读取状态文件，key为Backend的名称，文件不存在时返回空的map
+func loadSaved(path string) (map[string]*savedProxy, error) {
+	saved := make(map[string]*savedProxy)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return saved, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// storeSaved 写入状态文件，没有记录时删除文件
+func storeSaved(path string, saved map[string]*savedProxy) error {
+	if len(saved) == 0 {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	data, err := json.MarshalIndent(saved, "", "\t")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0600)
+}
+
+// updateSaved 在锁的保护下读取、修改并写回状态文件
+func updateSaved(update func(saved map[string]*savedProxy)) error {
+	path, err := snapshotPath()
+	if err != nil {
+		return err
+	}
+
+	snapshotLock.Lock()
+	defer snapshotLock.Unlock()
+	saved, err := loadSaved(path)
+	if err != nil {
+		return err
+	}
+	update(saved)
+	return storeSaved(path, saved)
+}
+
+// saveSnapshot 记录conf对应的Backend设置之前的系统代理
+// 已经有记录时保留原来的记录，它是更早的系统代理
+func saveSnapshot(conf *config.SystemProxyConfig, snapshot Snapshot) error {
+	return updateSaved(func(saved map[string]*savedProxy) {
+		if _, ok := saved[conf.Backend]; !ok {
+			saved[conf.Backend] = &savedProxy{Config: *conf, Snapshot: snapshot}
+		}
+	})
+}
+
+// removeSnapshot 删除conf对应的Backend的记录
+func removeSnapshot(conf *config.SystemProxyConfig) error {
+	return updateSaved(func(saved map[string]*savedProxy) {
+		delete(saved, conf.Backend)
+	})
+}
+
+// RestoreSaved 恢复状态文件中记录的系统代理，在程序启动时调用
+// 上次运行时程序崩溃或者被杀死，没有恢复系统代理时状态文件中会留有记录
+// 恢复成功的记录被删除，返回恢复的数量和第一个错误
+func RestoreSaved() (int, error) {
+	var (
+		count int
+		res   error
+	)
+	err := updateSaved(func(saved map[string]*savedProxy) {
+		for name, s := range saved {
+			backend, err := NewBackend(name, &s.Config)
+			if err == nil {
+				err = backend.Restore(s.Snapshot)
+			}
+			if err != nil {
+				if res == nil {
+					res = err
+				}
+				continue
+			}
+			delete(saved, name)
+			count++
+		}
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, res
+}
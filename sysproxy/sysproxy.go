@@ -0,0 +1,115 @@
+package sysproxy
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+
+	"schannel-qt5/config"
+)
+
+// 系统代理的模式
+const (
+	// ModeNone 不使用代理
+	ModeNone = "none"
+	// ModeManual 使用Settings中的SOCKS和HTTP代理
+	ModeManual = "manual"
+	// ModeAuto 使用PACURL
+	ModeAuto = "auto"
+)
+
+var (
+	// ErrBackend 没有注册对应的Backend
+	ErrBackend = errors.New("system proxy backend not registered")
+	// ErrUnsupportedMode Backend不支持的代理模式
+	ErrUnsupportedMode = errors.New("proxy mode is not supported by the backend")
+)
+
+// Settings 需要设置的系统代理
+type Settings struct {
+	// none, manual或者auto
+	Mode string
+	// host:port，为空时不设置
+	SOCKS string
+	HTTP  string
+	// auto模式使用的PAC地址
+	PACURL string
+	// 不使用代理的主机
+	IgnoreHosts []string
+}
+
+// Snapshot 设置之前的系统代理，key和value的含义由Backend决定
+type Snapshot map[string]string
+
+// Backend 读取和修改系统代理的方式
+type Backend interface {
+	// Save 返回当前的系统代理，用于之后的恢复
+	Save() (Snapshot, error)
+	// Apply 设置系统代理
+	Apply(*Settings) error
+	// Restore 恢复Save保存的系统代理
+	Restore(Snapshot) error
+}
+
+// BackendMaker 根据配置生成Backend
+type BackendMaker func(*config.SystemProxyConfig) (Backend, error)
+
+// 保存注册的BackendMaker
+var backends = make(map[string]BackendMaker)
+
+// SetBackendMaker 注册Backend生成器
+func SetBackendMaker(name string, maker BackendMaker) {
+	if name == "" || maker == nil {
+		panic("SetBackendMaker error: wrong name or BackendMaker")
+	}
+
+	backends[name] = maker
+}
+
+// NewBackend 生成名为name的Backend，name为auto时根据桌面环境选择
+func NewBackend(name string, conf *config.SystemProxyConfig) (Backend, error) {
+	if name == config.SystemProxyAuto {
+		name = DetectBackend()
+	}
+
+	maker, ok := backends[name]
+	if !ok {
+		return nil, ErrBackend
+	}
+	return maker(conf)
+}
+
+// DetectBackend 根据$XDG_CURRENT_DESKTOP返回适合的Backend名称
+// 无法识别的桌面环境使用env
+func DetectBackend() string {
+	for _, desktop := range strings.Split(os.Getenv("XDG_CURRENT_DESKTOP"), ":") {
+		switch strings.TrimPrefix(strings.ToUpper(desktop), "X-") {
+		case "KDE":
+			return config.SystemProxyKDE
+		case "GNOME", "UNITY", "CINNAMON", "MATE", "BUDGIE", "PANTHEON":
+			return config.SystemProxyGnome
+		}
+	}
+
+	return config.SystemProxyEnv
+}
+
+// Runner 运行外部命令并返回标准输出，测试时可以替换
+type Runner func(name string, args ...string) ([]byte, error)
+
+// execRunner 使用os/exec运行命令
+func execRunner(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).Output()
+}
+
+// splitHostPort 分割host:port，不含端口时返回空的port
+func splitHostPort(hostport string) (host, port string) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return hostport, ""
+	}
+
+	return host, port
+}
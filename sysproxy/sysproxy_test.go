@@ -0,0 +1,260 @@
+package sysproxy
+
+import (
+	"testing"
+
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"schannel-qt5/config"
+)
+
+// fakeBackend 在内存中保存系统代理
+type fakeBackend struct {
+	current  *Settings
+	applyErr error
+}
+
+func (f *fakeBackend) Save() (Snapshot, error) {
+	return Snapshot{"mode": f.current.Mode, "socks": f.current.SOCKS}, nil
+}
+
+func (f *fakeBackend) Apply(s *Settings) error {
+	if f.applyErr != nil {
+		f.current = &Settings{Mode: "broken"}
+		return f.applyErr
+	}
+	f.current = s
+	return nil
+}
+
+func (f *fakeBackend) Restore(s Snapshot) error {
+	f.current = &Settings{Mode: s["mode"], SOCKS: s["socks"]}
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	backend := &fakeBackend{current: &Settings{Mode: ModeNone}}
+	settings := &Settings{Mode: ModeManual, SOCKS: "127.0.0.1:1080"}
+	m := NewManager(backend, settings)
+
+	if err := m.Apply(); err != nil || !m.Applied() {
+		t.Fatalf("设置系统代理出错: %v\n", err)
+	}
+	if backend.current != settings {
+		t.Errorf("系统代理没有被设置: %+v\n", backend.current)
+	}
+	// 重复设置不会覆盖保存的设置
+	m.Apply()
+
+	if err := RestoreAll(); err != nil {
+		t.Fatalf("恢复系统代理出错: %v\n", err)
+	}
+	if m.Applied() || backend.current.Mode != ModeNone {
+		t.Errorf("系统代理没有被恢复: %+v\n", backend.current)
+	}
+	if len(active) != 0 {
+		t.Errorf("恢复后没有从active中删除\n")
+	}
+
+	backend.applyErr = errors.New("apply error")
+	if err := m.Apply(); err != backend.applyErr || m.Applied() {
+		t.Errorf("设置失败时应该返回错误: %v\n", err)
+	}
+	if backend.current.Mode != ModeNone {
+		t.Errorf("设置失败时没有恢复之前的设置: %+v\n", backend.current)
+	}
+}
+
+func TestRestoreSaved(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sysproxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("XDG_STATE_HOME", dir)
+	defer os.Unsetenv("XDG_STATE_HOME")
+
+	backend := &fakeBackend{current: &Settings{Mode: ModeNone}}
+	SetBackendMaker("fake", func(*config.SystemProxyConfig) (Backend, error) {
+		return backend, nil
+	})
+	defer delete(backends, "fake")
+	path, _ := snapshotPath()
+
+	m := NewManager(backend, &Settings{Mode: ModeManual, SOCKS: "127.0.0.1:1080"})
+	m.Persist(&config.SystemProxyConfig{Backend: "fake"})
+	if err := m.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("设置系统代理后没有写入状态文件: %v\n", err)
+	}
+	if err := m.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("恢复系统代理后没有删除状态文件\n")
+	}
+
+	// 模拟没有恢复系统代理就退出
+	m.Apply()
+	activeLock.Lock()
+	delete(active, m)
+	activeLock.Unlock()
+	n, err := RestoreSaved()
+	if err != nil || n != 1 {
+		t.Fatalf("恢复状态文件中的系统代理出错: %d %v\n", n, err)
+	}
+	if backend.current.Mode != ModeNone {
+		t.Errorf("系统代理没有被恢复: %+v\n", backend.current)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("恢复后没有删除状态文件中的记录\n")
+	}
+}
+
+func TestGnomeBackend(t *testing.T) {
+	gsettings := make(map[string]string)
+	run := func(name string, args ...string) ([]byte, error) {
+		if name != "gsettings" {
+			t.Fatalf("运行了错误的命令: %s\n", name)
+		}
+		key := args[1] + " " + args[2]
+		if args[0] == "get" {
+			return []byte(gsettings[key] + "\n"), nil
+		}
+		gsettings[key] = args[3]
+		return nil, nil
+	}
+	for _, key := range gnomeKeys {
+		gsettings[key] = "''"
+	}
+	gsettings[gnomeProxySchema+" mode"] = "'none'"
+
+	g := NewGnomeBackend(run)
+	saved, err := g.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := &Settings{
+		Mode:        ModeManual,
+		SOCKS:       "127.0.0.1:1080",
+		IgnoreHosts: []string{"localhost", "it's"},
+	}
+	if err := g.Apply(settings); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		gnomeProxySchema + " mode":         "'manual'",
+		gnomeProxySchema + ".socks host":   "'127.0.0.1'",
+		gnomeProxySchema + ".socks port":   "1080",
+		gnomeProxySchema + ".http host":    "''",
+		gnomeProxySchema + ".http port":    "0",
+		gnomeProxySchema + " ignore-hosts": `['localhost', 'it\'s']`,
+	}
+	for key, value := range want {
+		if gsettings[key] != value {
+			t.Errorf("%s错误:\n\twant: %s\n\thave: %s\n", key, value, gsettings[key])
+		}
+	}
+
+	if err := g.Restore(saved); err != nil {
+		t.Fatal(err)
+	}
+	if gsettings[gnomeProxySchema+" mode"] != "'none'" || gsettings[gnomeProxySchema+".socks port"] != "''" {
+		t.Errorf("gsettings没有被恢复: %v\n", gsettings)
+	}
+	if err := g.Apply(&Settings{Mode: "direct"}); err != ErrUnsupportedMode {
+		t.Errorf("不支持的模式没有返回错误: %v\n", err)
+	}
+}
+
+func TestEnvBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sysproxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "proxy.env")
+
+	e := NewEnvBackend(path)
+	saved, err := e.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := &Settings{Mode: ModeManual, SOCKS: "127.0.0.1:1080", HTTP: "127.0.0.1:8118"}
+	if err := e.Apply(settings); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(path)
+	for _, s := range []string{"export all_proxy='socks5://127.0.0.1:1080'", "export https_proxy='http://127.0.0.1:8118'"} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("环境变量文件中没有%s\n", s)
+		}
+	}
+	if err := e.Apply(&Settings{Mode: ModeAuto}); err != ErrUnsupportedMode {
+		t.Errorf("env不应该支持PAC: %v\n", err)
+	}
+
+	if err := e.Restore(saved); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("原来不存在的文件没有被删除\n")
+	}
+}
+
+func TestNewSettings(t *testing.T) {
+	c := &config.UserConfig{}
+	c.SystemProxy.Backend = config.SystemProxyEnv
+	c.SystemProxy.Mode = config.SystemProxyHTTP
+	if _, err := NewSettings(c); err != ErrHTTPProxyDisabled {
+		t.Errorf("没有启用http代理时应该返回错误: %v\n", err)
+	}
+	c.HTTPProxyPort = "8118"
+	s, err := NewSettings(c)
+	if err != nil || s.Mode != ModeManual || s.HTTP != "127.0.0.1:8118" {
+		t.Errorf("http代理设置错误: %+v, %v\n", s, err)
+	}
+	if !reflect.DeepEqual(s.IgnoreHosts, defaultIgnoreHosts) {
+		t.Errorf("默认的ignore hosts错误: %v\n", s.IgnoreHosts)
+	}
+
+	c.SystemProxy.Mode = config.SystemProxyPAC
+	if _, err := NewSettings(c); err != ErrPACDisabled {
+		t.Errorf("没有启用PAC服务时应该返回错误: %v\n", err)
+	}
+	c.PAC.Port = "1090"
+	s, err = NewSettings(c)
+	if err != nil || s.Mode != ModeAuto || s.PACURL != "http://127.0.0.1:1090/proxy.pac" {
+		t.Errorf("PAC设置错误: %+v, %v\n", s, err)
+	}
+	c.PAC.Addr = "::1"
+	s, err = NewSettings(c)
+	if err != nil || s.PACURL != "http://[::1]:1090/proxy.pac" {
+		t.Errorf("IPv6的PAC地址错误: %+v, %v\n", s, err)
+	}
+}
+
+func TestDetectBackend(t *testing.T) {
+	defer os.Setenv("XDG_CURRENT_DESKTOP", os.Getenv("XDG_CURRENT_DESKTOP"))
+
+	testData := map[string]string{
+		"KDE":          config.SystemProxyKDE,
+		"ubuntu:GNOME": config.SystemProxyGnome,
+		"X-Cinnamon":   config.SystemProxyGnome,
+		"XFCE":         config.SystemProxyEnv,
+		"":             config.SystemProxyEnv,
+	}
+	for desktop, want := range testData {
+		os.Setenv("XDG_CURRENT_DESKTOP", desktop)
+		if have := DetectBackend(); have != want {
+			t.Errorf("%s:\n\twant: %s\n\thave: %s\n", desktop, want, have)
+		}
+	}
+}
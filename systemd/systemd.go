@@ -0,0 +1,236 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	sddbus "github.com/coreos/go-systemd/v22/dbus"
+
+	"schannel-qt5/atomicfile"
+	"schannel-qt5/config"
+	"schannel-qt5/ssr"
+	"schannel-qt5/xdg"
+)
+
+const (
+	// unit名称前缀
+	unitPrefix = "schannel-qt5-"
+	// 等待systemd完成操作的时间
+	jobTimeout = 30 * time.Second
+)
+
+// Launcher 通过systemd --user服务运行ssr客户端
+// 服务在程序退出后继续运行，Start时启用服务使其在登录时自动启动，Stop时禁用
+type Launcher struct {
+	// systemd unit名称
+	unit string
+	// 服务的描述
+	description string
+	// 生成服务的启动命令，第一个元素为可执行文件的绝对路径
+	execStart func() ([]string, error)
+	// 程序需要的配置
+	conf config.ClientConfig
+}
+
+// NewLauncher 生成名为name的服务的Launcher
+// execStart在每次启动前调用，可以在其中生成客户端需要的文件
+func NewLauncher(name string, conf config.ClientConfig, execStart func() ([]string, error)) *Launcher {
+	return &Launcher{
+		unit:        unitPrefix + name + ".service",
+		description: "schannel-qt5 ssr client (" + name + ")",
+		execStart:   execStart,
+		conf:        conf,
+	}
+}
+
+// Unit 返回systemd unit名称
+func (l *Launcher) Unit() string {
+	return l.unit
+}
+
+// unitDir 返回用户unit文件的存放目录
+func unitDir() (string, error) {
+	dir, err := xdg.ConfigHome()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "systemd", "user"), nil
+}
+
+// writeUnit 生成并写入unit文件，返回文件路径
+func (l *Launcher) writeUnit() (string, error) {
+	args, err := l.execStart()
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := unitDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, l.unit)
+	if err := atomicfile.WriteFile(path, []byte(genUnit(l.description, args)), 0644); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// connect 连接到用户的systemd实例
+func connect(ctx context.Context) (*sddbus.Conn, error) {
+	return sddbus.NewUserConnectionContext(ctx)
+}
+
+// waitJob 等待systemd job完成
+func waitJob(ctx context.Context, unit string, result <-chan string) error {
+	select {
+	case res := <-result:
+		if res != "done" {
+			return fmt.Errorf("systemd job for %s: %s", unit, res)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// install 写入unit文件，重新加载并启用服务
+func (l *Launcher) install(ctx context.Context, conn *sddbus.Conn) error {
+	path, err := l.writeUnit()
+	if err != nil {
+		return err
+	}
+
+	if err := conn.ReloadContext(ctx); err != nil {
+		return err
+	}
+	_, _, err = conn.EnableUnitFilesContext(ctx, []string{path}, false, true)
+	return err
+}
+
+// Start 启动并启用服务
+func (l *Launcher) Start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	defer cancel()
+
+	conn, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := l.install(ctx, conn); err != nil {
+		return err
+	}
+
+	result := make(chan string, 1)
+	if _, err := conn.StartUnitContext(ctx, l.unit, "replace", result); err != nil {
+		return err
+	}
+	return waitJob(ctx, l.unit, result)
+}
+
+// Restart 使用新的配置重新启动服务
+func (l *Launcher) Restart() error {
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	defer cancel()
+
+	conn, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := l.install(ctx, conn); err != nil {
+		return err
+	}
+
+	result := make(chan string, 1)
+	if _, err := conn.RestartUnitContext(ctx, l.unit, "replace", result); err != nil {
+		return err
+	}
+	return waitJob(ctx, l.unit, result)
+}
+
+// Stop 停止并禁用服务，登录时不再自动启动
+func (l *Launcher) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	defer cancel()
+
+	conn, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	result := make(chan string, 1)
+	if _, err := conn.StopUnitContext(ctx, l.unit, "replace", result); err != nil {
+		return err
+	}
+	if err := waitJob(ctx, l.unit, result); err != nil {
+		return err
+	}
+
+	_, err = conn.DisableUnitFilesContext(ctx, []string{l.unit}, false)
+	return err
+}
+
+// ActiveState 返回服务的ActiveState，例如active，inactive，failed
+func (l *Launcher) ActiveState() (string, error) {
+	return l.unitProperty("ActiveState")
+}
+
+// unitProperty 返回服务的字符串属性name
+func (l *Launcher) unitProperty(name string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	defer cancel()
+
+	conn, err := connect(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	prop, err := conn.GetUnitPropertyContext(ctx, l.unit, name)
+	if err != nil {
+		return "", err
+	}
+	value, ok := prop.Value.Value().(string)
+	if !ok {
+		return "", fmt.Errorf("wrong %s of %s: %v", name, l.unit, prop.Value)
+	}
+
+	return value, nil
+}
+
+// IsRunning 服务处于active状态时返回nil
+// 服务退出后正在等待Restart=重启时返回ssr.ErrRestarting
+func (l *Launcher) IsRunning() error {
+	state, err := l.ActiveState()
+	if err != nil {
+		return err
+	}
+	if state == "activating" {
+		if sub, err := l.unitProperty("SubState"); err == nil && sub == "auto-restart" {
+			return ssr.ErrRestarting
+		}
+	}
+	if state != "active" {
+		return fmt.Errorf("%s is %s", l.unit, state)
+	}
+
+	return nil
+}
+
+// ConnectionCheck 检查代理是否可用，不可用则返回error
+func (l *Launcher) ConnectionCheck(timeout time.Duration) error {
+	return ssr.CheckProxy(l.conf.LocalAddr(), l.conf.LocalPort(), timeout)
+}
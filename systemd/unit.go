@@ -0,0 +1,49 @@
+package systemd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// unitTemplate 用户服务的unit文件，ExecStart由genUnit填充
+const unitTemplate = `[Unit]
+Description=%s
+After=network-online.target
+
+[Service]
+Type=simple
+ExecStart=%s
+Restart=on-failure
+RestartSec=3
+
+[Install]
+WantedBy=default.target
+`
+
+// quoteArg 按照systemd的规则转义命令行参数
+func quoteArg(arg string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+		"%", "%%",
+		"$", "$$",
+	)
+	quoted := replacer.Replace(arg)
+	if quoted == "" || strings.ContainsAny(quoted, " \t'") || quoted != arg {
+		return `"` + quoted + `"`
+	}
+
+	return quoted
+}
+
+// genUnit 生成unit文件内容
+func genUnit(description string, args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, quoteArg(arg))
+	}
+
+	desc := strings.NewReplacer("\n", " ", "%", "%%").Replace(description)
+	return fmt.Sprintf(unitTemplate, desc, strings.Join(quoted, " "))
+}
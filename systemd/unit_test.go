@@ -0,0 +1,41 @@
+package systemd
+
+import (
+	"testing"
+
+	"strings"
+)
+
+func TestQuoteArg(t *testing.T) {
+	testData := []struct {
+		arg, res string
+	}{
+		{arg: "/usr/bin/python", res: "/usr/bin/python"},
+		{arg: "-c", res: "-c"},
+		{arg: "", res: `""`},
+		{arg: "/home/user/my ssr.json", res: `"/home/user/my ssr.json"`},
+		{arg: "100%", res: `"100%%"`},
+		{arg: "$HOME", res: `"$$HOME"`},
+		{arg: `a"b\c`, res: `"a\"b\\c"`},
+	}
+
+	for _, v := range testData {
+		if res := quoteArg(v.arg); res != v.res {
+			t.Errorf("wrong quote for %s:\n\twant: %s\n\thave: %s\n", v.arg, v.res, res)
+		}
+	}
+}
+
+func TestGenUnit(t *testing.T) {
+	unit := genUnit("schannel-qt5 ssr client (test)", []string{"/usr/bin/ssr-local", "-c", "/tmp/a b.json"})
+
+	for _, line := range []string{
+		"Description=schannel-qt5 ssr client (test)\n",
+		`ExecStart=/usr/bin/ssr-local -c "/tmp/a b.json"` + "\n",
+		"WantedBy=default.target\n",
+	} {
+		if !strings.Contains(unit, line) {
+			t.Errorf("unit doesn't contain %s:\n%s\n", line, unit)
+		}
+	}
+}
@@ -13,4 +13,6 @@ const (
 	InvoicePath = AccountPath + `?action=invoices`
 	// 测试代理的URL
 	ProxyTestPath = `https://golang.org`
+	// 返回请求方ip的URL，用于检查代理的出口ip
+	ExitIPPath = `https://api.ipify.org`
 )
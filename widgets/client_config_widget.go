@@ -1,12 +1,15 @@
 package widgets
 
 import (
+	"net/url"
 	"sort"
 	"strings"
 
+	"github.com/therecipe/qt/core"
 	"github.com/therecipe/qt/widgets"
 
 	"schannel-qt5/config"
+	"schannel-qt5/ssr"
 )
 
 var (
@@ -24,14 +27,38 @@ type ClientConfigWidget struct {
 
 	// 配置改变后发送通知
 	_ func() `signal:"valueChanged"`
+	// 选择的ssr客户端类型改变后发送通知
+	_ func(string) `signal:"clientTypeChanged"`
 
 	// client设置
 	logFile    *widgets.QLineEdit
 	logFileMsg *ColorLabel
 
 	// ssr设置
+	clientType                                      *widgets.QComboBox
 	nodeConfigPath, ssrConfigPath, binPath          *widgets.QLineEdit
 	nodeConfigPathMsg, ssrConfigPathMsg, binPathMsg *ColorLabel
+	autoPickPort                                    *widgets.QCheckBox
+	// 权限提升方式，对应当前选择的客户端类型
+	elevatorType       *widgets.QComboBox
+	elevatorCommand    *widgets.QLineEdit
+	elevatorCommandMsg *ColorLabel
+
+	// 内置http代理设置
+	httpProxyBox                 *widgets.QGroupBox
+	httpProxyAddr, httpProxyPort *widgets.QLineEdit
+	httpProxyMsg                 *ColorLabel
+
+	// 本地PAC服务设置
+	pacBox                        *widgets.QGroupBox
+	pacAddr, pacPort              *widgets.QLineEdit
+	pacMsg                        *ColorLabel
+	gfwListPath, gfwListURL       *widgets.QLineEdit
+	gfwListPathMsg, gfwListURLMsg *ColorLabel
+	pacRuleButton                 *widgets.QPushButton
+
+	// 系统代理设置
+	sysProxyBackend, sysProxyMode *widgets.QComboBox
 
 	// 代理设置
 	proxy     *widgets.QLineEdit
@@ -72,6 +99,34 @@ func (cw *ClientConfigWidget) InitUI() {
 	// ssr设置布局
 	ssrBox := widgets.NewQGroupBox2("ssr设置", nil)
 	ssrLayout := widgets.NewQFormLayout(nil)
+	cw.clientType = widgets.NewQComboBox(nil)
+	cw.clientType.AddItems(ssr.Launchers())
+	cw.clientType.SetCurrentText(cw.conf.ClientType())
+	cw.clientType.ConnectCurrentTextChanged(func(name string) {
+		cw.setElevator(name)
+		cw.ValueChanged()
+		cw.ClientTypeChanged(name)
+	})
+	ssrLayout.AddRow3("客户端类型：", cw.clientType)
+
+	cw.elevatorType = widgets.NewQComboBox(nil)
+	cw.elevatorType.AddItems(config.ElevatorTypes)
+	cw.elevatorType.ConnectCurrentTextChanged(func(elevator string) {
+		cw.elevatorCommand.SetEnabled(elevator == config.ElevatorCustom)
+		cw.ValueChanged()
+	})
+	cw.elevatorCommand = widgets.NewQLineEdit(nil)
+	cw.elevatorCommand.SetPlaceholderText("自定义命令，例如：doas -n")
+	cw.elevatorCommand.ConnectTextChanged(func(_ string) {
+		cw.ValueChanged()
+	})
+	cw.elevatorCommandMsg = NewColorLabelWithColor("自定义方式需要设置命令", "red")
+	cw.elevatorCommandMsg.Hide()
+	cw.setElevator(cw.conf.ClientType())
+	ssrLayout.AddRow3("权限提升方式：", cw.elevatorType)
+	ssrLayout.AddRow3("提权命令：", cw.elevatorCommand)
+	ssrLayout.AddRow5(cw.elevatorCommandMsg)
+
 	cw.ssrConfigPath = widgets.NewQLineEdit2(cw.conf.SSRClientConfigPath.String(), nil)
 	cw.ssrConfigPath.SetPlaceholderText("绝对路径")
 	cw.ssrConfigPath.ConnectTextChanged(func(_ string) {
@@ -101,8 +156,115 @@ func (cw *ClientConfigWidget) InitUI() {
 	cw.binPathMsg.Hide()
 	ssrLayout.AddRow3("程序路径：", cw.binPath)
 	ssrLayout.AddRow5(cw.binPathMsg)
+
+	cw.autoPickPort = widgets.NewQCheckBox2("端口被占用时自动选择空闲端口", nil)
+	cw.autoPickPort.SetChecked(cw.conf.SSRAutoPickPort)
+	cw.autoPickPort.ConnectStateChanged(func(_ int) {
+		cw.ValueChanged()
+	})
+	ssrLayout.AddRow5(cw.autoPickPort)
 	ssrBox.SetLayout(ssrLayout)
 
+	// 内置http代理设置，可选
+	cw.httpProxyBox = widgets.NewQGroupBox2("本地http代理", nil)
+	cw.httpProxyBox.SetCheckable(true)
+	cw.httpProxyBox.SetChecked(cw.conf.HTTPProxyEnabled())
+	cw.httpProxyBox.ConnectToggled(func(_ bool) {
+		cw.ValueChanged()
+	})
+	cw.httpProxyAddr = widgets.NewQLineEdit2(cw.conf.HTTPProxyAddr, nil)
+	cw.httpProxyAddr.SetPlaceholderText("127.0.0.1")
+	cw.httpProxyAddr.ConnectTextChanged(func(_ string) {
+		cw.ValueChanged()
+	})
+	cw.httpProxyPort = widgets.NewQLineEdit2(cw.conf.HTTPProxyPort, nil)
+	cw.httpProxyPort.SetPlaceholderText("8118")
+	cw.httpProxyPort.ConnectTextChanged(func(_ string) {
+		cw.ValueChanged()
+	})
+	cw.httpProxyMsg = NewColorLabelWithColor("端口需要在1-65535之间", "red")
+	cw.httpProxyMsg.Hide()
+	httpProxyLayout := widgets.NewQFormLayout(nil)
+	httpProxyLayout.AddRow3("监听地址：", cw.httpProxyAddr)
+	httpProxyLayout.AddRow3("监听端口：", cw.httpProxyPort)
+	httpProxyLayout.AddRow5(cw.httpProxyMsg)
+	cw.httpProxyBox.SetLayout(httpProxyLayout)
+
+	// 本地PAC服务设置，可选
+	cw.pacBox = widgets.NewQGroupBox2("本地PAC服务", nil)
+	cw.pacBox.SetCheckable(true)
+	cw.pacBox.SetChecked(cw.conf.PAC.Enabled())
+	cw.pacBox.ConnectToggled(func(_ bool) {
+		cw.ValueChanged()
+	})
+	cw.pacAddr = widgets.NewQLineEdit2(cw.conf.PAC.Addr, nil)
+	cw.pacAddr.SetPlaceholderText("127.0.0.1")
+	cw.pacAddr.ConnectTextChanged(func(_ string) {
+		cw.ValueChanged()
+	})
+	cw.pacPort = widgets.NewQLineEdit2(cw.conf.PAC.Port, nil)
+	cw.pacPort.SetPlaceholderText("1090")
+	cw.pacPort.ConnectTextChanged(func(_ string) {
+		cw.ValueChanged()
+	})
+	cw.pacMsg = NewColorLabelWithColor("端口需要在1-65535之间", "red")
+	cw.pacMsg.Hide()
+	cw.gfwListPath = widgets.NewQLineEdit2(cw.conf.PAC.GFWListPath.String(), nil)
+	cw.gfwListPath.SetPlaceholderText("绝对路径，可以为空")
+	cw.gfwListPath.ConnectTextChanged(func(_ string) {
+		cw.ValueChanged()
+	})
+	cw.gfwListPathMsg = NewColorLabelWithColor("路径需要为绝对路径且不能为目录", "red")
+	cw.gfwListPathMsg.Hide()
+	cw.gfwListURL = widgets.NewQLineEdit2(cw.conf.PAC.GFWListURL, nil)
+	cw.gfwListURL.SetPlaceholderText("GFWList下载地址，可以为空")
+	cw.gfwListURL.ConnectTextChanged(func(_ string) {
+		cw.ValueChanged()
+	})
+	cw.gfwListURLMsg = NewColorLabelWithColor("不是合法的URL", "red")
+	cw.gfwListURLMsg.Hide()
+	cw.pacRuleButton = widgets.NewQPushButton2("编辑规则", nil)
+	cw.pacRuleButton.ConnectClicked(func(_ bool) {
+		dialog := NewPACRuleDialog2(cw.conf, cw)
+		dialog.Show()
+	})
+	pacLayout := widgets.NewQFormLayout(nil)
+	pacLayout.AddRow3("监听地址：", cw.pacAddr)
+	pacLayout.AddRow3("监听端口：", cw.pacPort)
+	pacLayout.AddRow5(cw.pacMsg)
+	pacLayout.AddRow3("GFWList路径：", cw.gfwListPath)
+	pacLayout.AddRow5(cw.gfwListPathMsg)
+	pacLayout.AddRow3("GFWList地址：", cw.gfwListURL)
+	pacLayout.AddRow5(cw.gfwListURLMsg)
+	pacLayout.AddRow5(cw.pacRuleButton)
+	cw.pacBox.SetLayout(pacLayout)
+
+	// 系统代理设置，不设置时为空
+	sysProxyBox := widgets.NewQGroupBox2("系统代理", nil)
+	cw.sysProxyBackend = widgets.NewQComboBox(nil)
+	for _, backend := range config.SystemProxyBackends {
+		if backend == config.SystemProxyNone {
+			backend = "不设置"
+		}
+		cw.sysProxyBackend.AddItem(backend, core.NewQVariant())
+	}
+	cw.sysProxyBackend.SetCurrentIndex(indexOf(config.SystemProxyBackends, cw.conf.SystemProxy.Backend))
+	cw.sysProxyBackend.ConnectCurrentIndexChanged(func(index int) {
+		cw.sysProxyMode.SetEnabled(index != 0)
+		cw.ValueChanged()
+	})
+	cw.sysProxyMode = widgets.NewQComboBox(nil)
+	cw.sysProxyMode.AddItems(config.SystemProxyModes)
+	cw.sysProxyMode.SetCurrentText(cw.conf.SystemProxy.ProxyMode())
+	cw.sysProxyMode.SetEnabled(cw.conf.SystemProxy.Enabled())
+	cw.sysProxyMode.ConnectCurrentTextChanged(func(_ string) {
+		cw.ValueChanged()
+	})
+	sysProxyLayout := widgets.NewQFormLayout(nil)
+	sysProxyLayout.AddRow3("设置方式：", cw.sysProxyBackend)
+	sysProxyLayout.AddRow3("代理类型：", cw.sysProxyMode)
+	sysProxyBox.SetLayout(sysProxyLayout)
+
 	// 对协议列表排序，方便查找
 	sort.Strings(protocols)
 
@@ -149,6 +311,9 @@ func (cw *ClientConfigWidget) InitUI() {
 	mainLayout := widgets.NewQVBoxLayout()
 	mainLayout.AddWidget(userBox, 0, 0)
 	mainLayout.AddWidget(ssrBox, 0, 0)
+	mainLayout.AddWidget(cw.httpProxyBox, 0, 0)
+	mainLayout.AddWidget(cw.pacBox, 0, 0)
+	mainLayout.AddWidget(sysProxyBox, 0, 0)
 	mainLayout.AddWidget(cw.proxyBox, 0, 0)
 	cw.SetLayout(mainLayout)
 }
@@ -188,13 +353,81 @@ func (cw *ClientConfigWidget) UpdateClientConfig() error {
 	}
 
 	err = cw.validProxy()
-	if showErrorMsg(cw.proxyMsg, err) {
+	if showValidationError(cw.proxyMsg, err) {
+		errRes = err
+	}
+
+	err = cw.validHTTPProxy()
+	if showValidationError(cw.httpProxyMsg, err) {
+		errRes = err
+	}
+
+	err = cw.validPAC()
+	if showValidationError(cw.pacMsg, err) {
+		errRes = err
+	}
+
+	err = checkEmptyPath(cw.gfwListPath.Text())
+	if showErrorMsg(cw.gfwListPathMsg, err) {
+		errRes = err
+	}
+
+	err = cw.validGFWListURL()
+	if showValidationError(cw.gfwListURLMsg, err) {
 		errRes = err
 	}
 
+	elevator := cw.getElevator()
+	err = elevator.Valid()
+	if showErrorMsg(cw.elevatorCommandMsg, err) {
+		errRes = err
+	}
+
+	if errRes == nil {
+		cw.conf.SSRAutoPickPort = cw.autoPickPort.IsChecked()
+		cw.conf.SetElevator(cw.ClientType(), elevator)
+		cw.conf.HTTPProxyAddr = bindAddr(cw.httpProxyAddr.Text())
+		cw.conf.HTTPProxyPort = ""
+		if cw.httpProxyBox.IsChecked() {
+			cw.conf.HTTPProxyPort = cw.httpProxyPort.Text()
+		}
+		cw.conf.PAC.Addr = bindAddr(cw.pacAddr.Text())
+		cw.conf.PAC.Port = ""
+		if cw.pacBox.IsChecked() {
+			cw.conf.PAC.Port = cw.pacPort.Text()
+		}
+		cw.conf.PAC.GFWListPath.Data = cw.gfwListPath.Text()
+		cw.conf.PAC.GFWListURL = cw.gfwListURL.Text()
+		cw.conf.SystemProxy.Backend = config.SystemProxyBackends[cw.sysProxyBackend.CurrentIndex()]
+		cw.conf.SystemProxy.Mode = cw.sysProxyMode.CurrentText()
+	}
+
 	return errRes
 }
 
+// setElevator 显示客户端类型为name时使用的权限提升方式
+func (cw *ClientConfigWidget) setElevator(name string) {
+	elevator := cw.conf.Elevator(name)
+	cw.elevatorType.SetCurrentText(elevator.Type)
+	cw.elevatorCommand.SetText(strings.Join(elevator.Command, " "))
+	cw.elevatorCommand.SetEnabled(elevator.Type == config.ElevatorCustom)
+}
+
+// getElevator 返回界面上设置的权限提升方式
+func (cw *ClientConfigWidget) getElevator() config.ElevatorConfig {
+	elevator := config.ElevatorConfig{Type: cw.elevatorType.CurrentText()}
+	if elevator.Type == config.ElevatorCustom {
+		elevator.Command = strings.Fields(cw.elevatorCommand.Text())
+	}
+
+	return elevator
+}
+
+// ClientType 返回选择的ssr客户端类型
+func (cw *ClientConfigWidget) ClientType() string {
+	return cw.clientType.CurrentText()
+}
+
 // GetProxyURL 返回拼接了type后的URL
 func (cw *ClientConfigWidget) GetProxyUrl() string {
 	if !cw.proxyBox.IsChecked() {
@@ -207,12 +440,64 @@ func (cw *ClientConfigWidget) GetProxyUrl() string {
 
 // validProxy 验证proxy URL是否合法
 func (cw *ClientConfigWidget) validProxy() error {
-	url := cw.GetProxyUrl()
-	p := config.JSONProxy{Data: url}
-	if !p.IsURL() && p.String() != "" {
-		return config.ErrNotURL
+	proxyURL := cw.GetProxyUrl()
+	if proxyURL == "" {
+		return nil
 	}
 
+	p := config.JSONProxy{Data: proxyURL}
+	return p.Check()
+}
+
+// validHTTPProxy 启用内置http代理时验证监听地址和端口是否合法
+func (cw *ClientConfigWidget) validHTTPProxy() error {
+	if !cw.httpProxyBox.IsChecked() {
+		return nil
+	}
+
+	return validListenAddr(cw.httpProxyAddr.Text(), cw.httpProxyPort.Text())
+}
+
+// validPAC 启用PAC服务时验证监听地址和端口是否合法
+func (cw *ClientConfigWidget) validPAC() error {
+	if !cw.pacBox.IsChecked() {
+		return nil
+	}
+
+	return validListenAddr(cw.pacAddr.Text(), cw.pacPort.Text())
+}
+
+// validListenAddr 验证监听地址和端口，地址为空时使用默认值
+func validListenAddr(addr, port string) error {
+	if addr != "" {
+		if _, err := config.ParseBindAddr(addr); err != nil {
+			return err
+		}
+	}
+
+	return config.CheckPort(port)
+}
+
+// bindAddr 返回去除方括号的监听地址，地址不合法时原样返回
+func bindAddr(addr string) string {
+	if res, err := config.ParseBindAddr(addr); err == nil {
+		return res
+	}
+
+	return addr
+}
+
+// validGFWListURL 验证GFWList的下载地址，允许为空
+func (cw *ClientConfigWidget) validGFWListURL() error {
+	text := cw.gfwListURL.Text()
+	if text == "" {
+		return nil
+	}
+
+	u, err := url.Parse(text)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return config.ErrNotURL
+	}
 	return nil
 }
 
@@ -0,0 +1,41 @@
+package widgets
+
+import (
+	"github.com/therecipe/qt/widgets"
+
+	"schannel-qt5/config"
+)
+
+// configErrorMessages 启动时读取配置出错对应的提示信息
+var configErrorMessages = map[error]string{
+	config.ErrConfigVersion:   "配置文件由更新版本的schannel-qt5生成",
+	config.ErrClientType:      "配置文件中的ssr客户端类型不被支持",
+	config.ErrProfileNotFound: "配置方案不存在",
+	config.ErrProfileName:     "配置方案名称只能包含字母、数字、\"-\"和\"_\"",
+}
+
+// configErrorMessage 返回err对应的提示信息，没有对应的信息时返回err本身
+func configErrorMessage(err error) string {
+	if msg, ok := configErrorMessages[err]; ok {
+		return msg
+	}
+
+	return err.Error()
+}
+
+// AskSetupWizard 启动时无法读取配置，询问是否运行设置向导重新生成配置
+// 返回false时程序退出，此时主窗口还没有创建
+func AskSetupWizard(err error) bool {
+	info := "无法读取配置: " + configErrorMessage(err) +
+		"\n\n是否运行设置向导重新生成配置？已有的配置文件会先备份为.bak文件。\n选择否将退出程序。"
+	buttons := widgets.QMessageBox__Yes | widgets.QMessageBox__No
+	answer := widgets.QMessageBox_Question4(nil, "配置错误", info, buttons, widgets.QMessageBox__Yes)
+
+	return answer == int(widgets.QMessageBox__Yes)
+}
+
+// ShowConfigError 显示设置向导无法修复的配置错误，之后程序退出
+func ShowConfigError(err error) {
+	info := "无法读取配置: " + configErrorMessage(err) + "\n\n程序将退出。"
+	widgets.QMessageBox_Critical(nil, "配置错误", info, widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+}
@@ -1,9 +1,14 @@
 package widgets
 
 import (
+	"fmt"
+
+	"github.com/therecipe/qt/core"
 	"github.com/therecipe/qt/widgets"
 
 	"schannel-qt5/config"
+	"schannel-qt5/parser"
+	"schannel-qt5/ssr"
 )
 
 // ConfigWidget 显示和设置本客户端的配置
@@ -12,18 +17,32 @@ type ConfigWidget struct {
 
 	// 通知conf已经更新
 	_ func(*config.UserConfig) `signal:"configChanged"`
+	// 配置文件被其他程序修改，由watcher goroutine发送
+	_ func(path string) `signal:"fileChanged"`
+	// 载入了被其他程序修改的配置文件，正在运行的客户端需要使用新的配置重启
+	_ func(*config.UserConfig) `signal:"configReloaded"`
+	// 切换了配置方案，正在运行的客户端需要使用新的配置重启
+	_ func(*config.UserConfig) `signal:"profileChanged"`
 
 	// client设置
 	clientConfigWidget *ClientConfigWidget
 
 	// ssr client设置
 	ssrClientConfigWidget *SSRConfigWidget
+	// 切换客户端类型时替换ssrClientConfigWidget
+	topLayout *widgets.QHBoxLayout
 
 	// 配置数据和接口
 	conf *config.UserConfig
+	// 正在编辑的ssr client config，切换客户端类型后保存前与conf中的不同
+	clientConfig config.ClientConfig
 
 	// 变动的配置是否已经保存
 	saved bool
+	// 监视配置文件、客户端配置和节点配置
+	watcher *config.FileWatcher
+	// 有未保存的修改时文件被其他程序修改，保存前需要确认覆盖
+	diskChanged bool
 }
 
 // NewConfigWidget2 根据conf生成ConfigWidget
@@ -33,44 +52,84 @@ func NewConfigWidget2(conf *config.UserConfig) *ConfigWidget {
 	}
 	widget := NewConfigWidget(nil, 0)
 	widget.conf = conf
+	widget.clientConfig = conf.SSRClientConfig
 	widget.saved = true
 	widget.InitUI()
+	widget.ConnectFileChanged(widget.reloadConfig)
+	widget.watchFiles()
 
 	return widget
 }
 
 // InitUI 初始化并显示
 func (w *ConfigWidget) InitUI() {
-	w.clientConfigWidget = NewClientConfigWidget2(w.conf)
-	w.clientConfigWidget.ConnectValueChanged(func() {
-		w.setSaved(false)
-	})
-	w.ssrClientConfigWidget = NewSSRConfigWidget2(w.conf.SSRClientConfig)
-	w.ssrClientConfigWidget.ConnectValueChanged(func() {
-		w.setSaved(false)
-	})
+	w.clientConfigWidget = w.newClientConfigWidget()
+	w.ssrClientConfigWidget = w.newSSRConfigWidget(w.clientConfig)
 
 	saveButton := widgets.NewQPushButton2("保存", nil)
 	saveButton.ConnectClicked(func(_ bool) {
 		w.SaveConfig()
 	})
 
+	w.topLayout = widgets.NewQHBoxLayout()
+	w.topLayout.AddWidget(w.clientConfigWidget, 0, 0)
+	w.topLayout.AddWidget(w.ssrClientConfigWidget, 0, 0)
+	mainLayout := widgets.NewQVBoxLayout()
+	mainLayout.AddLayout(w.topLayout, 0)
+	mainLayout.AddWidget(saveButton, 0, 0)
+	w.SetLayout(mainLayout)
+}
+
+// newClientConfigWidget 根据w.conf创建ClientConfigWidget
+func (w *ConfigWidget) newClientConfigWidget() *ClientConfigWidget {
+	widget := NewClientConfigWidget2(w.conf)
+	widget.ConnectValueChanged(func() {
+		w.setSaved(false)
+	})
+	widget.ConnectClientTypeChanged(w.switchClientType)
+
 	// 大小策略，client和ssrClient大小2:1
-	clientConfigSizePolicy := w.clientConfigWidget.SizePolicy()
+	clientConfigSizePolicy := widget.SizePolicy()
 	clientConfigSizePolicy.SetHorizontalPolicy(widgets.QSizePolicy__Expanding)
 	clientConfigSizePolicy.SetHorizontalStretch(2)
-	w.clientConfigWidget.SetSizePolicy(clientConfigSizePolicy)
-	ssrSizePolicy := w.ssrClientConfigWidget.SizePolicy()
+	widget.SetSizePolicy(clientConfigSizePolicy)
+
+	return widget
+}
+
+// newSSRConfigWidget 创建conf对应的SSRConfigWidget
+func (w *ConfigWidget) newSSRConfigWidget(conf config.ClientConfig) *SSRConfigWidget {
+	widget := NewSSRConfigWidget2(conf)
+	widget.ConnectValueChanged(func() {
+		w.setSaved(false)
+	})
+
+	// 大小策略，client和ssrClient大小2:1
+	ssrSizePolicy := widget.SizePolicy()
 	ssrSizePolicy.SetHorizontalPolicy(widgets.QSizePolicy__Expanding)
 	ssrSizePolicy.SetHorizontalStretch(1)
-	w.ssrClientConfigWidget.SetSizePolicy(ssrSizePolicy)
-	topLayout := widgets.NewQHBoxLayout()
-	topLayout.AddWidget(w.clientConfigWidget, 0, 0)
-	topLayout.AddWidget(w.ssrClientConfigWidget, 0, 0)
-	mainLayout := widgets.NewQVBoxLayout()
-	mainLayout.AddLayout(topLayout, 0)
-	mainLayout.AddWidget(saveButton, 0, 0)
-	w.SetLayout(mainLayout)
+	widget.SetSizePolicy(ssrSizePolicy)
+
+	return widget
+}
+
+// switchClientType 切换ssr客户端类型，重新生成ClientConfig并迁移共有的设置
+// 保存之前不会修改conf
+func (w *ConfigWidget) switchClientType(name string) {
+	newConfig := ssr.NewClientConfig(name)
+	if newConfig == nil {
+		showErrorDialog("未注册的客户端类型："+name, w)
+		return
+	}
+	if err := ssr.MigrateClientConfig(newConfig, w.clientConfig); err != nil {
+		showErrorDialog("迁移客户端设置出错："+err.Error(), w)
+	}
+	w.clientConfig = newConfig
+
+	old := w.ssrClientConfigWidget
+	w.ssrClientConfigWidget = w.newSSRConfigWidget(w.clientConfig)
+	w.topLayout.ReplaceWidget(old, w.ssrClientConfigWidget, core.Qt__FindChildrenRecursively)
+	old.DeleteLater()
 }
 
 // saveConfig 验证并保存配置
@@ -79,6 +138,16 @@ func (w *ConfigWidget) SaveConfig() {
 	w.SetEnabled(false)
 	defer w.SetEnabled(true)
 
+	// 文件在编辑期间被其他程序修改，确认是否覆盖
+	if w.diskChanged {
+		info := "配置文件在编辑期间被其他程序修改，保存将覆盖这些修改，是否继续？"
+		buttons := widgets.QMessageBox__Yes | widgets.QMessageBox__No
+		answer := widgets.QMessageBox_Question4(w, "配置冲突", info, buttons, widgets.QMessageBox__No)
+		if answer != int(widgets.QMessageBox__Yes) {
+			return
+		}
+	}
+
 	var err error
 	// 更新ssr client config
 	err = w.ssrClientConfigWidget.UpdateSSRClientConfig()
@@ -91,6 +160,8 @@ func (w *ConfigWidget) SaveConfig() {
 	if err != nil {
 		return
 	}
+	w.conf.SSRClientType = w.clientConfigWidget.ClientType()
+	w.conf.SSRClientConfig = w.clientConfig
 
 	if err := w.conf.StoreConfig(); err != nil {
 		showErrorDialog("保存出错: " + err.Error(), w)
@@ -99,6 +170,9 @@ func (w *ConfigWidget) SaveConfig() {
 
 	// 设置状态为已保存
 	w.setSaved(true)
+	w.diskChanged = false
+	// 配置文件的路径可能已经改变
+	w.watchFiles()
 	// 显示配置保存成功信息
 	ShowNotification("配置保存", "配置保存成功", "", -1)
 	// 通知其他组件配置发生变化
@@ -114,3 +188,133 @@ func (w *ConfigWidget) Saved() bool {
 func (w *ConfigWidget) setSaved(saved bool) {
 	w.saved = saved
 }
+
+// watchFiles 重新监视当前配置中的各个配置文件
+func (w *ConfigWidget) watchFiles() {
+	if w.watcher != nil {
+		w.watcher.Close()
+		w.watcher = nil
+	}
+
+	paths, err := w.conf.WatchedFiles()
+	if err != nil {
+		ShowNotification("配置监视", "无法监视配置文件: "+err.Error(), "", -1)
+		return
+	}
+	watcher, err := config.NewFileWatcher()
+	if err != nil {
+		ShowNotification("配置监视", "无法监视配置文件: "+err.Error(), "", -1)
+		return
+	}
+	for _, path := range paths {
+		if err := watcher.Add(path); err != nil {
+			ShowNotification("配置监视", fmt.Sprintf("无法监视%s: %v", path, err), "", -1)
+		}
+	}
+	w.watcher = watcher
+
+	go func() {
+		for path := range watcher.Events() {
+			w.FileChanged(path)
+		}
+	}()
+}
+
+// reloadConfig 文件被其他程序修改后重新载入并验证配置
+// 节点配置只需要通知其他组件，配置文件和客户端配置与未保存的修改冲突时由用户选择
+func (w *ConfigWidget) reloadConfig(path string) {
+	conf := &config.UserConfig{Overrides: w.conf.Overrides}
+	if err := conf.LoadConfig(); err != nil {
+		ShowNotification("配置更新", fmt.Sprintf("%s 无效，未载入: %v", path, err), "", -1)
+		return
+	}
+	nodePath, err := conf.SSRNodeConfigPath.AbsPath()
+	if err != nil {
+		ShowNotification("配置更新", fmt.Sprintf("节点配置路径无效: %v", err), "", -1)
+		return
+	}
+
+	if path == nodePath {
+		if err := new(parser.SSRNode).Load(nodePath); err != nil {
+			ShowNotification("配置更新", fmt.Sprintf("%s 无效，未载入: %v", path, err), "", -1)
+			return
+		}
+		w.ConfigReloaded(w.conf)
+		return
+	}
+
+	// 与内存中的配置相同，通常是自身保存引起的修改
+	if conf.SameAs(w.conf) {
+		return
+	}
+
+	if !w.saved {
+		info := fmt.Sprintf("%s 已被其他程序修改，与未保存的设置冲突。\n是否载入文件中的配置并放弃未保存的修改？", path)
+		buttons := widgets.QMessageBox__Yes | widgets.QMessageBox__No
+		shade := NewShadeWidget2(w.QWidget_PTR().NativeParentWidget())
+		answer := widgets.QMessageBox_Question4(w, "配置冲突", info, buttons, widgets.QMessageBox__No)
+		shade.Close()
+		if answer != int(widgets.QMessageBox__Yes) {
+			w.diskChanged = true
+			return
+		}
+	}
+
+	*w.conf = *conf
+	w.clientConfig = w.conf.SSRClientConfig
+	w.replaceWidgets()
+	w.setSaved(true)
+	w.diskChanged = false
+	w.watchFiles()
+	w.ConfigReloaded(w.conf)
+}
+
+// SwitchProfile 切换到配置方案name并重新载入配置，成功时返回true
+// 有未保存的修改时由用户确认是否放弃
+func (w *ConfigWidget) SwitchProfile(name string) bool {
+	if !w.saved {
+		info := fmt.Sprintf("切换到配置方案%s将放弃未保存的设置，是否继续？", name)
+		buttons := widgets.QMessageBox__Yes | widgets.QMessageBox__No
+		shade := NewShadeWidget2(w.QWidget_PTR().NativeParentWidget())
+		answer := widgets.QMessageBox_Question4(w, "切换配置方案", info, buttons, widgets.QMessageBox__No)
+		shade.Close()
+		if answer != int(widgets.QMessageBox__Yes) {
+			return false
+		}
+	}
+
+	conf := &config.UserConfig{Overrides: w.conf.Overrides}
+	if err := conf.LoadProfile(name); err != nil {
+		showErrorDialog(fmt.Sprintf("载入配置方案%s出错: %v", name, err), w)
+		return false
+	}
+
+	*w.conf = *conf
+	w.clientConfig = w.conf.SSRClientConfig
+	w.replaceWidgets()
+	w.setSaved(true)
+	w.diskChanged = false
+	w.watchFiles()
+	w.ProfileChanged(w.conf)
+	return true
+}
+
+// UpdateLocalPort 本地端口冲突时自动选择了新的端口，同步显示在设置界面中
+// 新的端口已经写入配置文件，不影响其他未保存的修改
+func (w *ConfigWidget) UpdateLocalPort(port string) {
+	if err := w.clientConfig.SetLocalPort(port); err != nil {
+		return
+	}
+	w.ssrClientConfigWidget.SetLocalPort(port)
+}
+
+// replaceWidgets 根据重新载入的配置重新生成设置界面
+func (w *ConfigWidget) replaceWidgets() {
+	oldClient, oldSSR := w.clientConfigWidget, w.ssrClientConfigWidget
+	w.clientConfigWidget = w.newClientConfigWidget()
+	w.ssrClientConfigWidget = w.newSSRConfigWidget(w.clientConfig)
+	w.topLayout.ReplaceWidget(oldClient, w.clientConfigWidget, core.Qt__FindChildrenRecursively)
+	w.topLayout.ReplaceWidget(oldSSR, w.ssrClientConfigWidget, core.Qt__FindChildrenRecursively)
+	oldClient.DeleteLater()
+	oldSSR.DeleteLater()
+}
@@ -0,0 +1,88 @@
+package widgets
+
+import (
+	"log"
+	"os"
+
+	"github.com/therecipe/qt/widgets"
+
+	"schannel-qt5/config"
+	"schannel-qt5/credential"
+	"schannel-qt5/models"
+)
+
+// OpenCredentialStore 根据conf打开保存账号密码的后端
+// 自动选择时优先使用Secret Service，不可用时使用主密码加密的文件
+// 用户取消输入主密码时返回nil，此时不记住密码
+func OpenCredentialStore(conf *config.UserConfig, logger *log.Logger) (models.CredentialStore, error) {
+	storeType, err := conf.CredentialStoreType()
+	if err != nil {
+		return nil, err
+	}
+
+	if storeType != config.CredentialStoreFile {
+		service, err := credential.NewSecretService()
+		if err == nil {
+			return service, nil
+		}
+		if storeType == config.CredentialStoreSecretService {
+			return nil, err
+		}
+		logger.Println("secret service不可用，使用加密文件保存密码:", err)
+	}
+
+	path, err := credential.DefaultFilePath()
+	if err != nil {
+		return nil, err
+	}
+	return openCredentialFile(path)
+}
+
+// openCredentialFile 请求主密码并打开path处的加密文件，主密码错误时重新请求
+// 文件不存在时需要设置新的主密码
+func openCredentialFile(path string) (models.CredentialStore, error) {
+	_, err := os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	exists := err == nil
+
+	label := "输入主密码以读取记住的账号密码："
+	if !exists {
+		label = "设置用于加密记住的账号密码的主密码："
+	}
+	for {
+		var ok bool
+		password := widgets.QInputDialog_GetText(nil, "主密码", label, widgets.QLineEdit__Password, "", &ok, 0, 0)
+		if !ok {
+			return nil, nil
+		}
+		if !exists && password != "" {
+			confirm := widgets.QInputDialog_GetText(nil, "主密码", "再次输入主密码：", widgets.QLineEdit__Password, "", &ok, 0, 0)
+			if !ok {
+				return nil, nil
+			}
+			if confirm != password {
+				showCredentialWarning("两次输入的主密码不一致")
+				continue
+			}
+		}
+
+		store, err := credential.OpenFile(path, password)
+		switch err {
+		case nil:
+			return store, nil
+		case credential.ErrEmptyMasterPassword:
+			showCredentialWarning("主密码不能为空")
+		case credential.ErrMasterPassword:
+			showCredentialWarning("主密码错误")
+		default:
+			return nil, err
+		}
+	}
+}
+
+// showCredentialWarning 显示主密码的错误信息，此时主窗口还没有创建
+func showCredentialWarning(info string) {
+	widgets.QMessageBox_Warning(nil, "主密码", info, widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+}
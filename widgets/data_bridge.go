@@ -32,6 +32,8 @@ type UserDataBridge interface {
 	GetCookies() []*http.Cookie
 	// GetProxy 获取代理地址
 	GetProxy() string
+	// SetProxy 设置之后请求使用的代理地址
+	SetProxy(proxy string)
 }
 
 // accountDataProxy 用于获取和缓存用户数据的代理类
@@ -172,3 +174,11 @@ func (a *accountDataProxy) GetProxy() string {
 
 	return a.proxy
 }
+
+// SetProxy 设置之后请求使用的代理地址，用于切换配置方案
+func (a *accountDataProxy) SetProxy(proxy string) {
+	a.Lock()
+	defer a.Unlock()
+
+	a.proxy = proxy
+}
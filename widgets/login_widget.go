@@ -22,7 +22,10 @@ type LoginWidget struct {
 	// loginSuccess 将登录成功的用户名和cookies传递给父控件
 	_ func(string)                 `signal:"loginFailed,auto"`
 	_ func(string, []*http.Cookie) `signal:"loginSuccess"`
+	// profileSelected 用户选择了其他配置方案，由父控件完成切换
+	_ func(name string) `signal:"profileSelected"`
 
+	profile      *widgets.QComboBox
 	username     *widgets.QComboBox
 	password     *widgets.QLineEdit
 	loginStatus  *ColorLabel
@@ -53,6 +56,11 @@ func NewLoginWidget2(conf *config.UserConfig, logger *log.Logger, db orm.Ormer)
 }
 
 func (l *LoginWidget) InitUI() {
+	// 配置方案决定登录时使用的代理
+	l.profile = widgets.NewQComboBox(nil)
+	l.SetProfile()
+	l.profile.ConnectActivated2(l.ProfileSelected)
+
 	l.username = widgets.NewQComboBox(nil)
 	l.username.SetEditable(true)
 	l.username.LineEdit().SetClearButtonEnabled(true)
@@ -174,6 +182,7 @@ func (l *LoginWidget) InitUI() {
 
 	mainLayout := widgets.NewQFormLayout(nil)
 	mainLayout.AddRow5(l.loginStatus)
+	mainLayout.AddRow3("配置方案：", l.profile)
 	mainLayout.AddRow3("用户名：", l.username)
 	mainLayout.AddRow3("密码：", l.password)
 	mainLayout.AddRow5(l.showPassword)
@@ -197,6 +206,7 @@ func (l *LoginWidget) login(_ bool) {
 
 // 控制输入区是否可编辑，禁止用户在登录过程中影响输入信息
 func (l *LoginWidget) setEditAreaEnabled(enabled bool) {
+	l.profile.SetEnabled(enabled)
 	l.username.SetEnabled(enabled)
 	l.password.SetEnabled(enabled)
 	l.showPassword.SetEnabled(enabled)
@@ -225,7 +235,9 @@ func (l *LoginWidget) checkLogin() {
 
 	// 登陆成功，记住密码
 	if l.remember.IsChecked() {
-		if err := models.SetUserPassword(l.db, user, passwd); err != nil {
+		if err := models.SetUserPassword(l.db, user, passwd); err == models.ErrNoCredentialStore {
+			ShowNotification("记住密码", "没有可用的密码保存方式，本次不会记住密码", "", -1)
+		} else if err != nil {
 			l.logger.Println(err)
 		}
 	} else {
@@ -272,3 +284,19 @@ func (l *LoginWidget) setPassword(user string) {
 	l.password.SetText("")
 	l.remember.SetChecked(false)
 }
+
+// SetProfile 重新读取配置方案列表并选中当前使用的方案
+func (l *LoginWidget) SetProfile() {
+	names, err := config.Profiles()
+	if err != nil {
+		l.logger.Println(err)
+	}
+	active, err := config.ActiveProfile()
+	if err != nil {
+		l.logger.Println(err)
+	}
+
+	l.profile.Clear()
+	l.profile.AddItems(names)
+	l.profile.SetCurrentText(active)
+}
@@ -54,7 +54,9 @@ func (m *MainWidget) InitUI() {
 	m.login = NewLoginWidget2(m.conf, m.logger, m.db)
 	m.tab.AddTab(m.login, "登录")
 	m.login.ConnectLoginSuccess(m.finishLogin)
-	m.SetWindowTitle("schannel-qt5")
+	m.login.ConnectProfileSelected(m.switchProfile)
+	m.initProfileMenu()
+	m.updateTitle()
 	m.SetCentralWidget(m.tab)
 	m.SetWindowIcon(gui.NewQIcon5(":/image/icon.svg"))
 }
@@ -67,6 +69,14 @@ func (m *MainWidget) finishLogin(user string, cookies []*http.Cookie) {
 	m.tab.RemoveTab(0)
 
 	m.setting = NewConfigWidget2(m.conf)
+	// 新的配置方案可能使用不同的代理
+	m.setting.ConnectProfileChanged(func(conf *config.UserConfig) {
+		m.dataBridge.SetProxy(conf.Proxy.String())
+	})
+	// 其他程序修改的配置文件中代理也可能改变
+	m.setting.ConnectConfigReloaded(func(conf *config.UserConfig) {
+		m.dataBridge.SetProxy(conf.Proxy.String())
+	})
 	// 关闭时确认配置修改的保存
 	m.ConnectCloseEvent(func(event *gui.QCloseEvent) {
 		if m.setting.Saved() {
@@ -113,6 +123,10 @@ func (m *MainWidget) finishLogin(user string, cookies []*http.Cookie) {
 		})
 		// 处理配置更新
 		m.setting.ConnectConfigChanged(widget.UpdateConfig)
+		m.setting.ConnectConfigReloaded(widget.ReloadConfig)
+		m.setting.ConnectProfileChanged(widget.SwitchProfile)
+		// 自动选择的端口已经保存，设置界面需要显示新的端口
+		widget.ConnectLocalPortChanged(m.setting.UpdateLocalPort)
 
 		serviceTabName := fmt.Sprintf("服务%d：%s", i+1, service.Name)
 		m.tab.AddTab(widget, serviceTabName)
@@ -123,3 +137,112 @@ func (m *MainWidget) finishLogin(user string, cookies []*http.Cookie) {
 	// 移动到左上角，避免窗口因较长显示不完整
 	m.Move2(0, 0)
 }
+
+// initProfileMenu 创建配置方案菜单，每次显示时重新读取方案列表
+func (m *MainWidget) initProfileMenu() {
+	menu := m.MenuBar().AddMenu2("配置方案")
+	menu.ConnectAboutToShow(func() {
+		menu.Clear()
+		names, err := config.Profiles()
+		if err != nil {
+			m.logger.Println(err)
+		}
+		active, _ := config.ActiveProfile()
+
+		for _, name := range names {
+			action := menu.AddAction(name)
+			action.SetCheckable(true)
+			action.SetChecked(name == active)
+			profile := name
+			action.ConnectTriggered(func(_ bool) {
+				m.switchProfile(profile)
+			})
+		}
+		menu.AddSeparator()
+		menu.AddAction("以当前方案新建...").ConnectTriggered(func(_ bool) {
+			m.copyProfile(active)
+		})
+		menu.AddAction("删除方案...").ConnectTriggered(func(_ bool) {
+			m.deleteProfile(names, active)
+		})
+	})
+}
+
+// switchProfile 切换到配置方案name
+// 登录后由ConfigWidget重新载入配置并重启正在运行的客户端
+func (m *MainWidget) switchProfile(name string) {
+	if active, _ := config.ActiveProfile(); name == active {
+		return
+	}
+
+	if m.setting != nil {
+		if !m.setting.SwitchProfile(name) {
+			m.login.SetProfile()
+			return
+		}
+	} else {
+		conf := &config.UserConfig{Overrides: m.conf.Overrides}
+		if err := conf.LoadProfile(name); err != nil {
+			showErrorDialog(fmt.Sprintf("载入配置方案%s出错: %v", name, err), m)
+			m.login.SetProfile()
+			return
+		}
+		*m.conf = *conf
+	}
+
+	m.login.SetProfile()
+	m.updateTitle()
+	m.logger.Printf("已切换到配置方案：%s\n", name)
+	ShowNotification("配置方案", "已切换到"+name, "", -1)
+}
+
+// copyProfile 复制配置方案from已保存的配置，新方案需要切换后才会使用
+func (m *MainWidget) copyProfile(from string) {
+	var ok bool
+	name := widgets.QInputDialog_GetText(m, "新建配置方案", "方案名称（字母、数字、-和_）：",
+		widgets.QLineEdit__Normal, "", &ok, 0, 0)
+	if !ok || name == "" {
+		return
+	}
+
+	if err := config.CopyProfile(from, name); err != nil {
+		showErrorDialog(fmt.Sprintf("新建配置方案出错: %v", err), m)
+		return
+	}
+	m.login.SetProfile()
+	ShowNotification("配置方案", fmt.Sprintf("已从%s创建%s", from, name), "", -1)
+}
+
+// deleteProfile 选择并删除一个配置方案，默认方案和正在使用的方案不能被删除
+func (m *MainWidget) deleteProfile(names []string, active string) {
+	items := make([]string, 0, len(names))
+	for _, name := range names {
+		if name != config.DefaultProfile && name != active {
+			items = append(items, name)
+		}
+	}
+	if len(items) == 0 {
+		showErrorDialog("没有可以删除的配置方案", m)
+		return
+	}
+
+	var ok bool
+	name := widgets.QInputDialog_GetItem(m, "删除配置方案", "选择要删除的方案：", items, 0, false, &ok, 0, 0)
+	if !ok {
+		return
+	}
+	if err := config.DeleteProfile(name); err != nil {
+		showErrorDialog(fmt.Sprintf("删除配置方案出错: %v", err), m)
+		return
+	}
+	m.login.SetProfile()
+}
+
+// updateTitle 标题中显示非默认的配置方案
+func (m *MainWidget) updateTitle() {
+	title := "schannel-qt5"
+	if active, _ := config.ActiveProfile(); active != config.DefaultProfile {
+		title += " - " + active
+	}
+	m.SetWindowTitle(title)
+}
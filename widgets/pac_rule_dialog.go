@@ -0,0 +1,175 @@
+package widgets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/widgets"
+
+	"schannel-qt5/atomicfile"
+	"schannel-qt5/config"
+	"schannel-qt5/pac"
+	"schannel-qt5/ssr"
+)
+
+const (
+	// 下载规则列表的超时时间
+	pacDownloadTimeout = 60 * time.Second
+	// 用户规则文件为空时显示的说明
+	pacRuleHelp = `# 每行一个规则，可以是域名、IP或者CIDR
+# 域名规则同时匹配所有子域名，以@@开头的规则表示直连
+# example.com
+# @@cn.example.com
+# 10.0.0.0/8
+`
+)
+
+// PACRuleDialog 编辑PAC用户规则，更新GFWList
+type PACRuleDialog struct {
+	widgets.QDialog
+
+	// GFWList下载完成，err为空字符串时表示成功
+	_ func(count int, errInfo string) `signal:"downloadFinished"`
+
+	editor       *widgets.QPlainTextEdit
+	editorMsg    *ColorLabel
+	saveButton   *widgets.QPushButton
+	updateButton *widgets.QPushButton
+	updateMsg    *ColorLabel
+
+	conf     *config.UserConfig
+	rulePath string
+}
+
+// NewPACRuleDialog2 创建PAC规则编辑对话框
+func NewPACRuleDialog2(conf *config.UserConfig, parent widgets.QWidget_ITF) *PACRuleDialog {
+	dialog := NewPACRuleDialog(parent, 0)
+	dialog.conf = conf
+	dialog.InitUI()
+	dialog.ConnectDownloadFinished(dialog.downloadFinished)
+
+	return dialog
+}
+
+// InitUI 初始化界面，读取用户规则文件
+func (dialog *PACRuleDialog) InitUI() {
+	dialog.editor = widgets.NewQPlainTextEdit(nil)
+	dialog.editorMsg = NewColorLabelWithColor("", "red")
+	dialog.editorMsg.Hide()
+
+	var err error
+	dialog.rulePath, err = dialog.conf.PAC.UserRuleAbsPath()
+	if err == nil {
+		var data []byte
+		data, err = ioutil.ReadFile(dialog.rulePath)
+		if os.IsNotExist(err) {
+			data, err = []byte(pacRuleHelp), nil
+		}
+		dialog.editor.SetPlainText(string(data))
+	}
+	if err != nil {
+		dialog.editorMsg.SetColorText(fmt.Sprintf("读取用户规则出错: %v", err), "red")
+		dialog.editorMsg.Show()
+	}
+
+	dialog.saveButton = widgets.NewQPushButton2("保存规则", nil)
+	dialog.saveButton.SetEnabled(dialog.rulePath != "")
+	dialog.saveButton.ConnectClicked(func(_ bool) {
+		dialog.saveRules()
+	})
+
+	ruleBox := widgets.NewQGroupBox2("用户规则", nil)
+	ruleLayout := widgets.NewQVBoxLayout()
+	ruleLayout.AddWidget(widgets.NewQLabel2(dialog.rulePath, nil, 0), 0, 0)
+	ruleLayout.AddWidget(dialog.editor, 0, 0)
+	ruleLayout.AddWidget(dialog.editorMsg, 0, 0)
+	ruleLayout.AddWidget(dialog.saveButton, 0, core.Qt__AlignRight)
+	ruleBox.SetLayout(ruleLayout)
+
+	// 设置了下载地址和保存路径时才能更新GFWList
+	dialog.updateButton = widgets.NewQPushButton2("更新GFWList", nil)
+	dialog.updateMsg = NewColorLabelWithColor("", "gray")
+	pacConf := &dialog.conf.PAC
+	if pacConf.GFWListURL == "" || pacConf.GFWListPath.IsEmpty() {
+		dialog.updateButton.SetEnabled(false)
+		dialog.updateMsg.SetColorText("未设置GFWList的下载地址或保存路径", "gray")
+	} else {
+		dialog.updateMsg.SetColorText(pacConf.GFWListURL, "gray")
+	}
+	dialog.updateButton.ConnectClicked(func(_ bool) {
+		dialog.updateGFWList()
+	})
+
+	updateLayout := widgets.NewQHBoxLayout()
+	updateLayout.AddWidget(dialog.updateMsg, 1, 0)
+	updateLayout.AddWidget(dialog.updateButton, 0, 0)
+
+	mainLayout := widgets.NewQVBoxLayout()
+	mainLayout.AddWidget(ruleBox, 0, 0)
+	mainLayout.AddLayout(updateLayout, 0)
+	dialog.SetLayout(mainLayout)
+	dialog.SetMinimumWidth(500)
+	dialog.SetMinimumHeight(400)
+	// 非模态dialog，设置关闭后销毁dialog对象
+	dialog.SetAttribute(core.Qt__WA_DeleteOnClose, true)
+	dialog.SetWindowTitle("PAC规则")
+}
+
+// saveRules 验证并保存用户规则，规则无效时不保存
+// PAC服务会在下一次请求时读取新的规则
+func (dialog *PACRuleDialog) saveRules() {
+	text := dialog.editor.ToPlainText()
+	if _, err := pac.ParseRules(strings.NewReader(text)); err != nil {
+		dialog.editorMsg.SetColorText(fmt.Sprintf("规则错误: %v", err), "red")
+		dialog.editorMsg.Show()
+		return
+	}
+
+	if err := atomicfile.WriteFile(dialog.rulePath, []byte(text), 0644); err != nil {
+		dialog.editorMsg.SetColorText(fmt.Sprintf("保存规则出错: %v", err), "red")
+		dialog.editorMsg.Show()
+		return
+	}
+
+	dialog.editorMsg.Hide()
+	ShowNotification("PAC", "用户规则已保存", "", -1)
+}
+
+// updateGFWList 通过ssr客户端的代理下载GFWList
+func (dialog *PACRuleDialog) updateGFWList() {
+	pacConf := dialog.conf.PAC
+	dest, err := pacConf.GFWListPath.AbsPath()
+	if err != nil {
+		dialog.downloadFinished(0, err.Error())
+		return
+	}
+
+	dialog.updateButton.SetEnabled(false)
+	dialog.updateMsg.SetColorText("正在下载", "gray")
+	socksAddr := ssr.SocksAddr(dialog.conf.SSRClientConfig)
+	go func() {
+		rules, err := pac.DownloadGFWList(pacConf.GFWListURL, socksAddr, dest, pacDownloadTimeout)
+		if err != nil {
+			dialog.DownloadFinished(0, err.Error())
+			return
+		}
+		dialog.DownloadFinished(rules.Len(), "")
+	}()
+}
+
+// downloadFinished 显示GFWList的更新结果
+func (dialog *PACRuleDialog) downloadFinished(count int, errInfo string) {
+	dialog.updateButton.SetEnabled(true)
+	if errInfo != "" {
+		dialog.updateMsg.SetColorText("更新失败: "+errInfo, "red")
+		return
+	}
+
+	info := fmt.Sprintf("已更新，共%d条规则", count)
+	dialog.updateMsg.SetColorText(info, "green")
+	ShowNotification("PAC", "GFWList"+info, "", -1)
+}
@@ -0,0 +1,235 @@
+package widgets
+
+import (
+	"fmt"
+
+	"github.com/therecipe/qt/widgets"
+
+	"schannel-qt5/config"
+	"schannel-qt5/ssr"
+)
+
+// SetupWizard 首次运行时引导用户生成配置文件
+type SetupWizard struct {
+	widgets.QWizard
+
+	// 客户端页面
+	clientType *widgets.QComboBox
+	detectMsg  *ColorLabel
+	binPath    *widgets.QComboBox
+	binPathMsg *ColorLabel
+
+	// 路径页面
+	nodeConfigPath      *widgets.QLineEdit
+	nodeConfigPathMsg   *ColorLabel
+	clientConfigPath    *widgets.QLineEdit
+	clientConfigPathMsg *ColorLabel
+
+	// 代理页面
+	proxyBox  *widgets.QGroupBox
+	proxyType *widgets.QComboBox
+	proxy     *widgets.QLineEdit
+	proxyMsg  *ColorLabel
+	storeMsg  *ColorLabel
+
+	conf *config.UserConfig
+}
+
+// NewSetupWizard2 创建首次运行设置向导，完成后配置写入conf并保存到ConfigPath
+func NewSetupWizard2(conf *config.UserConfig) *SetupWizard {
+	wizard := NewSetupWizard(nil, 0)
+	wizard.conf = conf
+	wizard.InitUI()
+
+	return wizard
+}
+
+// InitUI 初始化向导的各个页面
+func (wizard *SetupWizard) InitUI() {
+	wizard.AddPage(wizard.clientPage())
+	wizard.AddPage(wizard.pathPage())
+	wizard.AddPage(wizard.proxyPage())
+	wizard.SetButtonText(widgets.QWizard__NextButton, "下一步")
+	wizard.SetButtonText(widgets.QWizard__BackButton, "上一步")
+	wizard.SetButtonText(widgets.QWizard__FinishButton, "完成")
+	wizard.SetButtonText(widgets.QWizard__CancelButton, "退出")
+	wizard.SetMinimumWidth(550)
+	wizard.SetWindowTitle("schannel-qt5 首次运行设置")
+}
+
+// clientPage 选择客户端类型和ssr_bin
+func (wizard *SetupWizard) clientPage() *widgets.QWizardPage {
+	page := widgets.NewQWizardPage(nil)
+	page.SetTitle("ssr客户端")
+	page.SetSubTitle("选择ssr客户端的类型，程序会在PATH和常用目录中查找客户端需要的程序")
+
+	wizard.clientType = widgets.NewQComboBox(nil)
+	wizard.clientType.AddItems(ssr.Launchers())
+	wizard.detectMsg = NewColorLabelWithColor("", "gray")
+	wizard.binPath = widgets.NewQComboBox(nil)
+	wizard.binPath.SetEditable(true)
+	wizard.binPath.LineEdit().SetPlaceholderText("绝对路径")
+	wizard.binPathMsg = NewColorLabelWithColor("路径需要为绝对路径且不能为目录", "red")
+	wizard.binPathMsg.Hide()
+	browseButton := widgets.NewQPushButton2("浏览", nil)
+	browseButton.ConnectClicked(func(_ bool) {
+		path := widgets.QFileDialog_GetOpenFileName(wizard, "选择程序", "", "", "", 0)
+		if path != "" {
+			wizard.binPath.SetCurrentText(path)
+		}
+	})
+
+	wizard.clientType.ConnectCurrentTextChanged(wizard.detectBins)
+	wizard.clientType.SetCurrentText(config.DefaultSSRClientType)
+	wizard.detectBins(wizard.clientType.CurrentText())
+
+	binLayout := widgets.NewQHBoxLayout()
+	binLayout.AddWidget(wizard.binPath, 1, 0)
+	binLayout.AddWidget(browseButton, 0, 0)
+	layout := widgets.NewQFormLayout(nil)
+	layout.AddRow3("客户端类型：", wizard.clientType)
+	layout.AddRow5(wizard.detectMsg)
+	layout.AddRow4("程序路径：", binLayout)
+	layout.AddRow5(wizard.binPathMsg)
+	page.SetLayout(layout)
+
+	page.ConnectValidatePage(func() bool {
+		return !showErrorMsg(wizard.binPathMsg, checkPath(wizard.binPath.CurrentText()))
+	})
+
+	return page
+}
+
+// detectBins 查找name客户端需要的程序并显示查找结果
+func (wizard *SetupWizard) detectBins(name string) {
+	wizard.binPath.Clear()
+	d, err := ssr.DetectBins(name)
+	switch {
+	case err == ssr.ErrNoRequirement:
+		wizard.detectMsg.SetColorText("无法自动查找此客户端需要的程序，请手动设置", "gray")
+		return
+	case err == ssr.ErrInterpreterNotFound:
+		wizard.detectMsg.SetColorText("PATH中找不到客户端需要的解释器，请先安装", "red")
+	case err != nil:
+		wizard.detectMsg.SetColorText(fmt.Sprintf("查找程序出错: %v", err), "red")
+		return
+	case len(d.Bins) == 0:
+		wizard.detectMsg.SetColorText("没有找到客户端程序，请手动设置", "red")
+	case d.Interpreter != "":
+		wizard.detectMsg.SetColorText(fmt.Sprintf("使用解释器%s，找到%d个客户端程序", d.Interpreter, len(d.Bins)), "green")
+	default:
+		wizard.detectMsg.SetColorText(fmt.Sprintf("找到%d个客户端程序", len(d.Bins)), "green")
+	}
+
+	if d != nil {
+		wizard.binPath.AddItems(d.Bins)
+	}
+}
+
+// pathPage 设置节点配置和客户端配置的保存路径
+func (wizard *SetupWizard) pathPage() *widgets.QWizardPage {
+	page := widgets.NewQWizardPage(nil)
+	page.SetTitle("配置文件")
+	page.SetSubTitle("节点配置和客户端配置的保存路径，可以使用~表示$HOME，默认遵循XDG目录规范")
+
+	nodePath, _ := config.DefaultSSRNodeConfigPath()
+	clientPath, _ := config.DefaultSSRClientConfigPath()
+	wizard.nodeConfigPath = widgets.NewQLineEdit2(nodePath, nil)
+	wizard.nodeConfigPath.SetPlaceholderText("绝对路径")
+	wizard.nodeConfigPathMsg = NewColorLabelWithColor("路径需要为绝对路径且不能为目录", "red")
+	wizard.nodeConfigPathMsg.Hide()
+	wizard.clientConfigPath = widgets.NewQLineEdit2(clientPath, nil)
+	wizard.clientConfigPath.SetPlaceholderText("绝对路径")
+	wizard.clientConfigPathMsg = NewColorLabelWithColor("路径需要为绝对路径且不能为目录", "red")
+	wizard.clientConfigPathMsg.Hide()
+
+	layout := widgets.NewQFormLayout(nil)
+	layout.AddRow3("节点配置路径：", wizard.nodeConfigPath)
+	layout.AddRow5(wizard.nodeConfigPathMsg)
+	layout.AddRow3("客户端配置路径：", wizard.clientConfigPath)
+	layout.AddRow5(wizard.clientConfigPathMsg)
+	page.SetLayout(layout)
+
+	page.ConnectValidatePage(func() bool {
+		nodeErr := showErrorMsg(wizard.nodeConfigPathMsg, checkPath(wizard.nodeConfigPath.Text()))
+		clientErr := showErrorMsg(wizard.clientConfigPathMsg, checkPath(wizard.clientConfigPath.Text()))
+		return !nodeErr && !clientErr
+	})
+
+	return page
+}
+
+// proxyPage 设置可选的代理，完成时保存配置
+func (wizard *SetupWizard) proxyPage() *widgets.QWizardPage {
+	page := widgets.NewQWizardPage(nil)
+	page.SetTitle("代理")
+	page.SetSubTitle("无法直接访问服务网站时，可以设置schannel-qt5使用的代理")
+
+	wizard.proxyBox = widgets.NewQGroupBox2("使用代理", nil)
+	wizard.proxyBox.SetCheckable(true)
+	wizard.proxyBox.SetChecked(false)
+	wizard.proxyType = widgets.NewQComboBox(nil)
+	wizard.proxyType.AddItems(protocols)
+	wizard.proxy = widgets.NewQLineEdit(nil)
+	wizard.proxy.SetPlaceholderText("例如：127.0.0.1:1080")
+	wizard.proxyMsg = NewColorLabelWithColor("", "red")
+	wizard.proxyMsg.Hide()
+	proxyLayout := widgets.NewQFormLayout(nil)
+	proxyLayout.AddRow3("协议类型:", wizard.proxyType)
+	proxyLayout.AddRow3("代理服务器地址:", wizard.proxy)
+	proxyLayout.AddRow5(wizard.proxyMsg)
+	wizard.proxyBox.SetLayout(proxyLayout)
+
+	wizard.storeMsg = NewColorLabelWithColor("", "red")
+	wizard.storeMsg.Hide()
+	layout := widgets.NewQVBoxLayout()
+	layout.AddWidget(wizard.proxyBox, 0, 0)
+	layout.AddWidget(wizard.storeMsg, 0, 0)
+	layout.AddStretch(1)
+	page.SetLayout(layout)
+
+	page.ConnectValidatePage(func() bool {
+		proxy := config.JSONProxy{}
+		if wizard.proxyBox.IsChecked() {
+			proxy.Data = wizard.proxyType.CurrentText() + "://" + wizard.proxy.Text()
+			if showValidationError(wizard.proxyMsg, proxy.Check()) {
+				return false
+			}
+		}
+		wizard.proxyMsg.Hide()
+
+		err := wizard.storeConfig(proxy)
+		if err != nil {
+			wizard.storeMsg.SetColorText(fmt.Sprintf("保存配置出错: %v", err), "red")
+		}
+		return !showErrorMsg(wizard.storeMsg, err)
+	})
+
+	return page
+}
+
+// storeConfig 根据向导中的设置生成配置并保存
+func (wizard *SetupWizard) storeConfig(proxy config.JSONProxy) error {
+	clientType := wizard.clientType.CurrentText()
+	clientConfig := ssr.NewClientConfig(clientType)
+	if clientConfig == nil {
+		return config.ErrClientType
+	}
+
+	conf := &config.UserConfig{
+		Proxy:           proxy,
+		SSRClientType:   clientType,
+		SSRClientConfig: clientConfig,
+	}
+	conf.SSRNodeConfigPath.Data = wizard.nodeConfigPath.Text()
+	conf.SSRClientConfigPath.Data = wizard.clientConfigPath.Text()
+	conf.SSRBin.Data = wizard.binPath.CurrentText()
+	if err := conf.StoreConfig(); err != nil {
+		return err
+	}
+
+	// 命令行和环境变量的覆盖在之后LoadConfig时生效
+	conf.Overrides = wizard.conf.Overrides
+	*wizard.conf = *conf
+	return nil
+}
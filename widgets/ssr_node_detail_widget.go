@@ -4,9 +4,16 @@ import (
 	"fmt"
 
 	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
 	"github.com/therecipe/qt/widgets"
 
 	"schannel-qt5/parser"
+	"schannel-qt5/qrcode"
+)
+
+const (
+	// 二维码图片的边长(px)
+	qrcodeSize = 200
 )
 
 // NodeDetailWidget 显示节点的详细信息
@@ -29,6 +36,12 @@ type NodeDetailWidget struct {
 	proto *widgets.QLabel
 	// 服务器地理信息
 	geo *widgets.QLabel
+	// ssr://链接的二维码
+	qrcode *widgets.QLabel
+	// 导出二维码为PNG图片
+	exportButton *widgets.QPushButton
+
+	node *parser.SSRNode
 }
 
 // NewNodeDetailWidgetWithNode 根据参数给出的节点显示其详细信息
@@ -93,6 +106,13 @@ func (n *NodeDetailWidget) InitUI() {
 	mainLayout.AddWidget(geoLabel, 9, 0, 0)
 	mainLayout.AddWidget(n.geo, 9, 1, 0)
 
+	n.qrcode = widgets.NewQLabel(nil, 0)
+	n.qrcode.SetFixedSize2(qrcodeSize, qrcodeSize)
+	mainLayout.AddWidget3(n.qrcode, 10, 0, 1, 2, core.Qt__AlignHCenter)
+	n.exportButton = widgets.NewQPushButton2("导出二维码", nil)
+	n.exportButton.ConnectClicked(n.exportQRCode)
+	mainLayout.AddWidget3(n.exportButton, 11, 0, 1, 2, core.Qt__AlignHCenter)
+
 	n.SetLayout(mainLayout)
 }
 
@@ -101,6 +121,7 @@ func (n *NodeDetailWidget) SetNodeDetail(node *parser.SSRNode) {
 	if node == nil {
 		return
 	}
+	n.node = node
 
 	n.name.SetText(node.NodeName)
 	n.proxyType.SetText(node.Type)
@@ -112,4 +133,44 @@ func (n *NodeDetailWidget) SetNodeDetail(node *parser.SSRNode) {
 	n.mixin.SetText(node.Minx)
 	n.proto.SetText(node.Proto)
 	n.geo.SetText(getGeoName(node.IP))
+	n.setQRCode()
+}
+
+// setQRCode 显示当前节点ssr://链接的二维码
+func (n *NodeDetailWidget) setQRCode() {
+	data, err := qrcode.Encode(n.node.SSRLink(), qrcodeSize)
+	if err != nil {
+		n.qrcode.SetText("二维码生成失败")
+		n.exportButton.SetEnabled(false)
+		return
+	}
+
+	pixmap := gui.NewQPixmap()
+	pixmap.LoadFromData2(core.NewQByteArray2(string(data), len(data)), "PNG", core.Qt__AutoColor)
+	n.qrcode.SetPixmap(pixmap)
+	n.exportButton.SetEnabled(true)
+}
+
+// exportQRCode 将当前节点的二维码保存为PNG图片
+func (n *NodeDetailWidget) exportQRCode(_ bool) {
+	if n.node == nil {
+		return
+	}
+
+	pngFileFilter := "PNG Files(*.png)"
+	fileName := fmt.Sprintf("%s.png", n.node.NodeName)
+	savePath, err := getFileSavePath("qrcode", fileName, pngFileFilter, n)
+	if err == ErrCanceled {
+		return
+	} else if err != nil {
+		showErrorDialog("保存路径获取失败："+err.Error(), n)
+		return
+	}
+
+	if err := qrcode.WriteFile(n.node.SSRLink(), savePath, qrcodeSize); err != nil {
+		showErrorDialog("二维码保存失败："+err.Error(), n)
+		return
+	}
+
+	ShowNotification("节点", savePath+"保存成功", "", -1)
 }
@@ -1,14 +1,13 @@
 package widgets
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
 
 	"github.com/therecipe/qt/core"
 	"github.com/therecipe/qt/widgets"
 
 	"schannel-qt5/parser"
+	"schannel-qt5/qrcode"
 )
 
 // NodeSelectDialog 显示所有节点信息，并选择设置节点
@@ -78,6 +77,8 @@ func (dialog *NodeSelectDialog) InitUI() {
 	})
 	saveNodeButton := widgets.NewQPushButton2("保存至文件", nil)
 	saveNodeButton.ConnectClicked(dialog.saveNode)
+	importNodeButton := widgets.NewQPushButton2("从图片导入", nil)
+	importNodeButton.ConnectClicked(dialog.importNode)
 
 	mainLayout := widgets.NewQGridLayout2()
 	contentLayout := widgets.NewQHBoxLayout()
@@ -88,6 +89,7 @@ func (dialog *NodeSelectDialog) InitUI() {
 	hFrame := widgets.NewQFrame(nil, 0)
 	hFrame.SetFrameStyle(int(widgets.QFrame__HLine) | int(widgets.QFrame__Sunken))
 	mainLayout.AddWidget3(hFrame, 1, 0, 1, 4, 0)
+	mainLayout.AddWidget(importNodeButton, 2, 0, 0)
 	mainLayout.AddWidget(saveNodeButton, 2, 1, 0)
 	mainLayout.AddWidget(dialog.cancelButton, 2, 2, 0)
 	mainLayout.AddWidget(dialog.okButton, 2, 3, 0)
@@ -107,22 +109,45 @@ func (dialog *NodeSelectDialog) saveNode(_ bool) {
 		return
 	}
 
-	data, err := json.MarshalIndent(dialog.CurrentNode, "", "\t")
+	if err := dialog.CurrentNode.Store(savePath); err != nil {
+		showErrorDialog("写入配置失败："+err.Error(), dialog)
+		return
+	}
+
+	ShowNotification("节点", savePath+"保存成功", "", -1)
+}
+
+// importNode 从二维码图片中导入节点，导入的节点将作为当前选择的节点
+func (dialog *NodeSelectDialog) importNode(_ bool) {
+	imageFileFilter := "Images(*.png *.jpg *.jpeg)"
+	defaultPath, err := defaultSavePath("qrcode", "")
 	if err != nil {
-		showErrorDialog("配置解析失败："+err.Error(), dialog)
+		showErrorDialog("路径获取失败："+err.Error(), dialog)
+		return
+	}
+	imagePath := widgets.QFileDialog_GetOpenFileName(dialog,
+		"打开",
+		defaultPath,
+		imageFileFilter,
+		"",
+		0)
+	if imagePath == "" {
 		return
 	}
-	f, err := os.OpenFile(savePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+
+	link, err := qrcode.DecodeFile(imagePath)
 	if err != nil {
-		showErrorDialog("文件创建失败："+err.Error(), dialog)
+		showErrorDialog("二维码识别失败："+err.Error(), dialog)
 		return
 	}
-	defer f.Close()
-	_, err = f.Write(data)
+	node, err := parser.ParseSSRLink(link)
 	if err != nil {
-		showErrorDialog("写入配置失败："+err.Error(), dialog)
+		showErrorDialog("节点解析失败："+err.Error(), dialog)
 		return
 	}
 
-	ShowNotification("节点", savePath+"保存成功", "", -1)
+	dialog.tree.ClearSelection()
+	dialog.CurrentNode = node
+	dialog.detail.SetNodeDetail(node)
+	ShowNotification("节点", "已导入节点"+node.NodeName, "", -1)
 }
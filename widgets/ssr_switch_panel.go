@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/therecipe/qt/widgets"
@@ -18,13 +19,20 @@ import (
 type SSRSwitchPanel struct {
 	widgets.QWidget
 
+	// 客户端状态改变，eventType为ssr.EventType，detail为延迟和出口信息
+	_ func(eventType int, errInfo, detail string) `signal:"stateChanged"`
+	// 本地端口冲突时自动选择了新的端口，并已写入客户端配置文件
+	_ func(port string) `signal:"localPortChanged"`
+
 	// node缩略信息
 	nodeInfo *NodeInfoPanel
 
 	// 链接是否可用
 	// 未打开客户端为"未开启"(gray)
-	// 链接可用为"OK"(green)
+	// 正在启动为"正在启动"(gray)
+	// 链接可用为"OK 延迟: [latency] 出口: [country] ([ip])"(green)
 	// 不可用为"error: [error info]"(red)
+	// 客户端意外退出为"客户端意外退出: [error info]"(red)
 	connStat *ColorLabel
 
 	// ssr开关
@@ -36,7 +44,9 @@ type SSRSwitchPanel struct {
 	currentNode *parser.SSRNode
 	// ssr client程序和配置文件
 	ssrClient ssr.Launcher
-	conf      *config.UserConfig
+	// 监视ssrClient的状态，switchButton和connStat根据事件更新
+	watcher *ssr.StateWatcher
+	conf    *config.UserConfig
 	// 可用节点信息
 	nodes []*parser.SSRNode
 
@@ -56,7 +66,7 @@ func NewSSRSwitchPanel2(conf *config.UserConfig, nodes []*parser.SSRNode, logger
 	panel.SortNode()
 	panel.logger = logger
 
-	panel.ssrClient = ssr.NewLauncher("python", panel.conf)
+	panel.ssrClient = ssr.NewLauncher(panel.conf.ClientType(), panel.conf)
 	if panel.ssrClient == nil {
 		panel.logger.Println("ssr client created failed")
 		return nil
@@ -71,6 +81,8 @@ func NewSSRSwitchPanel2(conf *config.UserConfig, nodes []*parser.SSRNode, logger
 	panel.currentNode.Load(nodePath)
 
 	panel.InitUI()
+	panel.ConnectStateChanged(panel.updateState)
+	panel.watchClient()
 	return panel
 }
 
@@ -93,35 +105,36 @@ func (s *SSRSwitchPanel) InitUI() {
 	// 设置自动换行
 	s.connStat.AdjustSize()
 	s.connStat.SetWordWrap(true)
-	s.setConnStat()
+	s.connStat.SetColorText("未开启客户端", "gray")
 	connStatLabel := widgets.NewQLabel2("连接状态:", nil, 0)
 	componentLayout.AddWidget(connStatLabel, 1, 0, 0)
 	componentLayout.AddWidget3(s.connStat, 1, 1, 1, 2, 0)
 
 	s.switchButton = NewSwitchButton2(s.ssrClient.IsRunning() == nil)
 	s.switchButton.ConnectClicked(func(checked bool) {
-		info := ""
+		// 按钮状态由客户端状态事件更新
+		s.switchButton.SetChecked(!checked)
 		switch checked {
 		case true:
-			if err := s.ssrClient.Start(); err != nil {
+			if !s.resolvePortConflict() {
+				return
+			}
+			if err := s.watcher.Start(); err != nil {
 				errInfo := fmt.Sprintf("启动客户端错误: %v", err)
 				showErrorDialog(errInfo, s)
 				return
 			}
 
-			info = "已打开"
+			ShowNotification("SSR客户端", "已打开", "", -1)
 		case false:
-			if err := s.ssrClient.Stop(); err != nil {
+			if err := s.watcher.Stop(); err != nil {
 				errInfo := fmt.Sprintf("关闭客户端错误: %v", err)
 				showErrorDialog(errInfo, s)
 				return
 			}
 
-			info = "已关闭"
+			ShowNotification("SSR客户端", "已关闭", "", -1)
 		}
-
-		ShowNotification("SSR客户端", info, "", -1)
-		s.setConnStat()
 	})
 	switchLabel := widgets.NewQLabel2("ssr开关：", nil, 0)
 	componentLayout.AddWidget(switchLabel, 2, 0, 0)
@@ -145,40 +158,141 @@ func (s *SSRSwitchPanel) InitUI() {
 	s.SetLayout(componentLayout)
 }
 
-// setConnStat 设置代理节点是否可用的信息
-func (s *SSRSwitchPanel) setConnStat() {
-	if err := s.ssrClient.IsRunning(); err != nil {
-		s.connStat.SetColorText("未开启客户端", "gray")
-		return
+// resolvePortConflict 启动前检查本地端口是否被占用，无法启动时返回false
+// 设置了自动选择端口时保存新的端口并重新生成客户端
+func (s *SSRSwitchPanel) resolvePortConflict() bool {
+	oldPort := s.conf.SSRClientConfig.LocalPort()
+	newPort, err := ssr.ResolvePortConflict(s.conf.SSRClientConfig, s.conf.SSRAutoPickPort)
+	if err != nil {
+		errInfo := fmt.Sprintf("本地端口冲突: %v", err)
+		s.logger.Println(errInfo)
+		showErrorDialog(errInfo, s)
+		return false
+	} else if newPort == "" {
+		return true
 	}
 
-	if err := s.ssrClient.ConnectionCheck(5 * time.Second); err != nil {
-		errInfo := fmt.Sprintf("error: %v", err)
-		s.connStat.SetColorText(errInfo, "red")
-		s.logger.Println(errInfo)
-		ShowNotification("SSR连接测试失败", errInfo, "", -1)
-		return
+	clientConfigPath, err := s.conf.SSRClientConfigPath.AbsPath()
+	if err == nil {
+		err = s.conf.SSRClientConfig.Store(clientConfigPath)
+	}
+	if err != nil {
+		s.logger.Println("保存客户端配置出错: ", err)
+	}
+
+	// 客户端可能在生成时就使用了端口信息
+	s.ssrClient = ssr.NewLauncher(s.conf.ClientType(), s.conf)
+	if s.ssrClient == nil {
+		showErrorDialog("初始化ssr客户端错误", s)
+		return false
+	}
+	s.watchClient()
+
+	info := fmt.Sprintf("端口%s已被占用，已改用端口%s", oldPort, newPort)
+	s.logger.Println(info)
+	ShowNotification("SSR客户端", info, "", -1)
+	s.LocalPortChanged(newPort)
+	return true
+}
+
+// watchClient 开始监视ssrClient，停止对旧客户端的监视
+func (s *SSRSwitchPanel) watchClient() {
+	if s.watcher != nil {
+		s.watcher.Close()
+	}
+
+	s.watcher = ssr.NewStateWatcher(s.ssrClient)
+	conf := s.conf
+	s.watcher.Checker = func(timeout time.Duration) *ssr.CheckResult {
+		opts := ssr.CheckOptions{
+			Endpoints:      conf.CheckEndpoints,
+			ExitIPEndpoint: conf.ExitIPURL(),
+			Timeout:        timeout,
+		}
+		clientConf := conf.SSRClientConfig
+		return ssr.CheckProxyDetail(clientConf.LocalAddr(), clientConf.LocalPort(), opts)
+	}
+	events := s.watcher.Events()
+	go func() {
+		for e := range events {
+			errInfo := ""
+			if e.Err != nil {
+				errInfo = e.Err.Error()
+			}
+			s.StateChanged(int(e.Type), errInfo, checkDetail(e.Result))
+		}
+	}()
+	s.watcher.Watch()
+}
+
+// checkDetail 返回代理的延迟和出口信息，没有检查结果时返回空字符串
+func checkDetail(result *ssr.CheckResult) string {
+	if result == nil || result.Err() != nil {
+		return ""
+	}
+
+	detail := fmt.Sprintf("延迟: %v", result.Latency().Round(time.Millisecond))
+	if result.ExitErr == nil && result.ExitIP != "" {
+		detail += fmt.Sprintf(" 出口: %s (%s)", result.ExitCountry, result.ExitIP)
 	}
+	for _, e := range result.Endpoints {
+		if e.Err != nil {
+			detail += fmt.Sprintf("\n%s: %v", e.URL, e.Err)
+		}
+	}
+
+	return detail
+}
 
-	s.connStat.SetColorText("OK", "green")
+// updateState 根据客户端状态事件更新开关和连接状态
+func (s *SSRSwitchPanel) updateState(eventType int, errInfo, detail string) {
+	switch ssr.EventType(eventType) {
+	case ssr.EventStarting:
+		s.switchButton.SetChecked(true)
+		s.connStat.SetColorText("正在启动", "gray")
+	case ssr.EventRunning:
+		s.switchButton.SetChecked(true)
+		s.connStat.SetColorText(strings.TrimSpace("OK "+detail), "green")
+	case ssr.EventDegraded:
+		s.switchButton.SetChecked(true)
+		if errInfo == ssr.ErrRestarting.Error() {
+			// supervisor或systemd会重新启动客户端
+			s.connStat.SetColorText("客户端已退出，正在等待重启", "gray")
+			s.logger.Println(errInfo)
+			return
+		}
+		info := fmt.Sprintf("error: %v", errInfo)
+		s.connStat.SetColorText(info, "red")
+		s.logger.Println(info)
+		ShowNotification("SSR连接测试失败", info, "", -1)
+	case ssr.EventStopped:
+		s.switchButton.SetChecked(false)
+		s.connStat.SetColorText("未开启客户端", "gray")
+	case ssr.EventCrashed:
+		s.switchButton.SetChecked(false)
+		info := fmt.Sprintf("客户端意外退出: %v", errInfo)
+		s.connStat.SetColorText(info, "red")
+		s.logger.Println(info)
+		ShowNotification("SSR客户端", info, "", -1)
+	}
 }
 
 // DataRefresh 更新config和nodes
 func (s *SSRSwitchPanel) DataRefresh(conf *config.UserConfig, nodes []*parser.SSRNode) {
 	// 停止旧的客户端运行
 	if running := s.ssrClient.IsRunning(); running == nil {
-		s.ssrClient.Stop()
-		s.switchButton.SetChecked(false)
+		s.watcher.Stop()
 		ShowNotification("SSR客户端", "已关闭", "", -1)
 	}
 	s.conf = conf
-	s.ssrClient = ssr.NewLauncher("python", s.conf)
+	s.ssrClient = ssr.NewLauncher(s.conf.ClientType(), s.conf)
 	if s.ssrClient == nil {
 		s.logger.Println("ssr switch DataRefresh: 初始化ssr客户端错误")
 		// TODO 更详细的错误信息
 		showErrorDialog("初始化ssr客户端错误", s)
 		return
 	}
+	s.watchClient()
 
 	s.nodes = make([]*parser.SSRNode, len(nodes))
 	copy(s.nodes, nodes)
@@ -194,5 +308,34 @@ func (s *SSRSwitchPanel) DataRefresh(conf *config.UserConfig, nodes []*parser.SS
 	}
 	s.currentNode.Load(nodeConfigPath)
 	s.nodeInfo.DataRefresh(s.currentNode)
-	s.setConnStat()
+}
+
+// SwitchConfig 切换配置方案后更新config和nodes，正在运行的客户端使用新的配置重新启动
+func (s *SSRSwitchPanel) SwitchConfig(conf *config.UserConfig, nodes []*parser.SSRNode) {
+	s.restartWith(conf, nodes, "已使用新的配置方案重新启动")
+}
+
+// ReloadConfig 配置文件被其他程序修改后更新config和nodes，正在运行的客户端使用修改后的配置重新启动
+func (s *SSRSwitchPanel) ReloadConfig(conf *config.UserConfig, nodes []*parser.SSRNode) {
+	s.restartWith(conf, nodes, "已使用修改后的配置重新启动")
+}
+
+// restartWith 更新config和nodes，之前正在运行的客户端重新启动后显示info
+func (s *SSRSwitchPanel) restartWith(conf *config.UserConfig, nodes []*parser.SSRNode, info string) {
+	running := s.ssrClient.IsRunning() == nil
+	s.DataRefresh(conf, nodes)
+	if !running || s.ssrClient == nil {
+		return
+	}
+
+	if !s.resolvePortConflict() {
+		return
+	}
+	if err := s.watcher.Start(); err != nil {
+		errInfo := fmt.Sprintf("重启客户端错误: %v", err)
+		s.logger.Println(errInfo)
+		showErrorDialog(errInfo, s)
+		return
+	}
+	ShowNotification("SSR客户端", info, "", -1)
 }
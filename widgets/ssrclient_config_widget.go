@@ -29,6 +29,14 @@ type SSRConfigWidget struct {
 	// 是否使用fast-open
 	fastOpen *widgets.QCheckBox
 
+	// 客户端日志文件路径，可以为空
+	logFilePath    *widgets.QLineEdit
+	logFilePathMsg *ColorLabel
+	// 连接超时时间
+	timeout  *widgets.QSpinBox
+	udpRelay *widgets.QCheckBox
+	verbose  *widgets.QCheckBox
+
 	conf config.ClientConfig
 }
 
@@ -47,7 +55,7 @@ func (s *SSRConfigWidget) InitUI() {
 	groupLayout := widgets.NewQFormLayout(nil)
 
 	s.localAddr = widgets.NewQLineEdit(nil)
-	s.localAddr.SetPlaceholderText("绑定本地ip地址")
+	s.localAddr.SetPlaceholderText("本地ip地址或主机名，例如127.0.0.1、::1")
 	s.localAddr.SetText(s.conf.LocalAddr())
 	s.localAddr.ConnectTextChanged(func(_ string) {
 		s.ValueChanged()
@@ -81,6 +89,40 @@ func (s *SSRConfigWidget) InitUI() {
 	groupLayout.AddRow3("pid-file路径：", s.pidFilePath)
 	groupLayout.AddRow5(s.pidFilePathMsg)
 
+	s.logFilePath = widgets.NewQLineEdit(nil)
+	s.logFilePath.SetPlaceholderText("绝对路径，为空时使用默认值")
+	s.logFilePath.SetText(s.conf.LogFilePath())
+	s.logFilePath.ConnectTextChanged(func(_ string) {
+		s.ValueChanged()
+	})
+	s.logFilePathMsg = NewColorLabelWithColor("不是合法的路径", "red")
+	s.logFilePathMsg.Hide()
+	groupLayout.AddRow3("日志文件路径：", s.logFilePath)
+	groupLayout.AddRow5(s.logFilePathMsg)
+
+	s.timeout = widgets.NewQSpinBox(nil)
+	s.timeout.SetRange(1, config.MaxTimeout)
+	s.timeout.SetSuffix(" 秒")
+	s.timeout.SetValue(s.conf.Timeout())
+	s.timeout.ConnectValueChanged(func(_ int) {
+		s.ValueChanged()
+	})
+	groupLayout.AddRow3("连接超时：", s.timeout)
+
+	s.udpRelay = widgets.NewQCheckBox2("启用udp转发", nil)
+	s.udpRelay.SetChecked(s.conf.UDPRelay())
+	s.udpRelay.ConnectClicked(func(_ bool) {
+		s.ValueChanged()
+	})
+	groupLayout.AddRow5(s.udpRelay)
+
+	s.verbose = widgets.NewQCheckBox2("输出详细日志", nil)
+	s.verbose.SetChecked(s.conf.Verbose())
+	s.verbose.ConnectClicked(func(_ bool) {
+		s.ValueChanged()
+	})
+	groupLayout.AddRow5(s.verbose)
+
 	// 检查内核版本
 	versionInfo := widgets.NewQLabel(nil, 0)
 	s.fastOpen = widgets.NewQCheckBox2("启用fast-open", nil)
@@ -107,6 +149,17 @@ func (s *SSRConfigWidget) InitUI() {
 	s.SetLayout(mainLayout)
 }
 
+// SetLocalPort 显示新的本地端口，不发送ValueChanged信号
+func (s *SSRConfigWidget) SetLocalPort(port string) {
+	value, err := strconv.Atoi(port)
+	if err != nil {
+		return
+	}
+	s.localPort.BlockSignals(true)
+	s.localPort.SetValue(value)
+	s.localPort.BlockSignals(false)
+}
+
 // UpdateSSRClientConfig 更新config，如果数据不合法则返回error
 // 因为传递了引用类型，所以直接修改config对象
 func (s *SSRConfigWidget) UpdateSSRClientConfig() error {
@@ -119,12 +172,12 @@ func (s *SSRConfigWidget) UpdateSSRClientConfig() error {
 	var err error
 	var errRes error
 	err = s.conf.SetLocalPort(strconv.Itoa(s.localPort.Value()))
-	if showErrorMsg(s.localPortMsg, err) {
+	if showValidationError(s.localPortMsg, err) {
 		errRes = err
 	}
 
 	err = s.conf.SetLocalAddr(s.localAddr.Text())
-	if showErrorMsg(s.localAddrMsg, err) {
+	if showValidationError(s.localAddrMsg, err) {
 		errRes = err
 	}
 
@@ -133,5 +186,17 @@ func (s *SSRConfigWidget) UpdateSSRClientConfig() error {
 		errRes = err
 	}
 
+	err = s.conf.SetLogFilePath(s.logFilePath.Text())
+	if showErrorMsg(s.logFilePathMsg, err) {
+		errRes = err
+	}
+
+	// spinbox已经限制了取值范围
+	if err = s.conf.SetTimeout(s.timeout.Value()); err != nil {
+		errRes = err
+	}
+	s.conf.SetUDPRelay(s.udpRelay.IsChecked())
+	s.conf.SetVerbose(s.verbose.IsChecked())
+
 	return errRes
 }
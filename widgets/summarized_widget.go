@@ -26,6 +26,8 @@ type SummarizedWidget struct {
 	// 发出数据变动，让上层控件更新service
 	// 上层控件完成service的更新后发送DataRefresh信号，int值为当前的index
 	_ func(int) `signal:"serviceNeedUpdate"`
+	// 转发switchPanel自动选择的新端口，让设置界面同步显示
+	_ func(port string) `signal:"localPortChanged"`
 
 	// 用户数据接口
 	dataBridge UserDataBridge
@@ -80,6 +82,7 @@ func (sw *SummarizedWidget) InitUI() {
 	sw.servicePanel = NewServicePanel2(sw.user, ssrInfo)
 	sw.invoicePanel = NewInvoicePanelWithData(sw.dataBridge)
 	sw.switchPanel = NewSSRSwitchPanel2(sw.conf, ssrInfo.Nodes, logger)
+	sw.switchPanel.ConnectLocalPortChanged(sw.LocalPortChanged)
 	sw.usedPanel = NewUsedPanelWithInfo(sw.user, ssrInfo, logger)
 
 	updateButton := widgets.NewQPushButton2("刷新", nil)
@@ -150,6 +153,22 @@ func (sw *SummarizedWidget) UpdateConfig(conf *config.UserConfig) {
 	ShowNotification("配置更新", "配置更新成功", "", -1)
 }
 
+// ReloadConfig 配置文件被其他程序修改后刷新switchPanel，正在运行的客户端会使用新的配置重启
+// 一般用作ConfigWidget的ConfigReloaded信号处理函数
+func (sw *SummarizedWidget) ReloadConfig(conf *config.UserConfig) {
+	sw.conf = conf
+	nodes := sw.dataBridge.SSRInfos(sw.service).Nodes
+	sw.switchPanel.ReloadConfig(sw.conf, nodes)
+}
+
+// SwitchProfile 切换配置方案后刷新switchPanel，正在运行的客户端会使用新的配置重启
+// 一般用作ConfigWidget的ProfileChanged信号处理函数
+func (sw *SummarizedWidget) SwitchProfile(conf *config.UserConfig) {
+	sw.conf = conf
+	nodes := sw.dataBridge.SSRInfos(sw.service).Nodes
+	sw.switchPanel.SwitchConfig(sw.conf, nodes)
+}
+
 // 下载GeoIP数据库的回调函数
 func (sw *SummarizedWidget) downloadGeoIPDatabase(_ bool) {
 	geoPath, err := geoip.GetGeoIPSavePath()
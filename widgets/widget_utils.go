@@ -5,8 +5,35 @@ import (
 
 	"github.com/therecipe/qt/core"
 	"github.com/therecipe/qt/widgets"
+
+	"schannel-qt5/config"
 )
 
+// validationMessages 配置验证错误对应的提示信息
+var validationMessages = map[error]string{
+	config.ErrEmptyHost:   "地址不能为空",
+	config.ErrInvalidHost: "不是合法的ip地址或主机名，IPv6地址需要使用[::1]的形式",
+	config.ErrInvalidPort: "端口需要在1-65535之间",
+	config.ErrNotURL:      "不是合法的URL",
+	config.ErrProxyScheme: "代理协议需要为http、https或socks5",
+	config.ErrNotAbs:      "路径需要为绝对路径",
+	config.ErrTimeout:     "超时时间需要在1-3600秒之间",
+}
+
+// showValidationError 在label中显示err对应的提示信息，没有对应的信息时显示err本身
+// 返回值与showErrorMsg相同
+func showValidationError(label *ColorLabel, err error) bool {
+	if err != nil {
+		msg, ok := validationMessages[err]
+		if !ok {
+			msg = err.Error()
+		}
+		label.SetColorText(msg, "red")
+	}
+
+	return showErrorMsg(label, err)
+}
+
 // showErrorMsg 控制error label的显示
 // err为nil则代表没有错误发生，如果label可见则设为隐藏
 // err不为nil时设置label可见
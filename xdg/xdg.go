@@ -0,0 +1,140 @@
+package xdg
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// 程序文件在各个基础目录中使用的子目录
+const appDir = "schannel-qt5"
+
+// ErrHOME 无法查找$HOME
+var ErrHOME = errors.New("can't find $HOME in your environments")
+
+// baseDir 返回环境变量env指定的基础目录
+// 未设置或者不是绝对路径时使用$HOME下的fallback
+func baseDir(env, fallback string) (string, error) {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+
+	return HomeFile(fallback)
+}
+
+// HomeFile 返回$HOME下的路径
+func HomeFile(path string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", ErrHOME
+	}
+
+	return filepath.Join(home, path), nil
+}
+
+// ConfigHome 返回$XDG_CONFIG_HOME，默认为~/.config
+func ConfigHome() (string, error) {
+	return baseDir("XDG_CONFIG_HOME", ".config")
+}
+
+// DataHome 返回$XDG_DATA_HOME，默认为~/.local/share
+func DataHome() (string, error) {
+	return baseDir("XDG_DATA_HOME", ".local/share")
+}
+
+// CacheHome 返回$XDG_CACHE_HOME，默认为~/.cache
+func CacheHome() (string, error) {
+	return baseDir("XDG_CACHE_HOME", ".cache")
+}
+
+// StateHome 返回$XDG_STATE_HOME，默认为~/.local/state
+func StateHome() (string, error) {
+	return baseDir("XDG_STATE_HOME", ".local/state")
+}
+
+// appFile 返回基础目录下schannel-qt5子目录中的路径
+func appFile(base func() (string, error), name string) (string, error) {
+	dir, err := base()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, appDir, name), nil
+}
+
+// ConfigFile 返回配置文件的路径：$XDG_CONFIG_HOME/schannel-qt5/name
+func ConfigFile(name string) (string, error) {
+	return appFile(ConfigHome, name)
+}
+
+// DataFile 返回数据文件的路径：$XDG_DATA_HOME/schannel-qt5/name
+func DataFile(name string) (string, error) {
+	return appFile(DataHome, name)
+}
+
+// CacheFile 返回可以重新生成的缓存文件的路径：$XDG_CACHE_HOME/schannel-qt5/name
+func CacheFile(name string) (string, error) {
+	return appFile(CacheHome, name)
+}
+
+// StateFile 返回状态文件的路径：$XDG_STATE_HOME/schannel-qt5/name
+func StateFile(name string) (string, error) {
+	return appFile(StateHome, name)
+}
+
+// Migrate 将旧位置legacy的文件或目录移动到path
+// path已经存在或者legacy不存在时不做任何操作，返回是否进行了移动
+func Migrate(legacy, path string) (bool, error) {
+	if _, err := os.Lstat(path); err == nil {
+		return false, nil
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	info, err := os.Lstat(legacy)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+	err = os.Rename(legacy, path)
+	// 不在同一个文件系统时复制普通文件，目录需要用户手动移动
+	if linkErr, ok := err.(*os.LinkError); ok && linkErr.Err == syscall.EXDEV && info.Mode().IsRegular() {
+		err = moveFile(legacy, path, info.Mode())
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// moveFile 复制src到dst后删除src，复制失败时删除不完整的dst
+func moveFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode.Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	return os.Remove(src)
+}
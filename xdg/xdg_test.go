@@ -0,0 +1,80 @@
+package xdg
+
+import (
+	"testing"
+
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func TestBaseDirs(t *testing.T) {
+	envs := []string{"HOME", "XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_CACHE_HOME", "XDG_STATE_HOME"}
+	for _, env := range envs {
+		defer os.Setenv(env, os.Getenv(env))
+	}
+
+	os.Setenv("HOME", "/home/test")
+	os.Setenv("XDG_CONFIG_HOME", "/tmp/config")
+	os.Setenv("XDG_DATA_HOME", "")
+	// 相对路径需要被忽略
+	os.Setenv("XDG_CACHE_HOME", "cache")
+	os.Setenv("XDG_STATE_HOME", "/tmp/state")
+
+	testData := []*struct {
+		get  func(string) (string, error)
+		name string
+		res  string
+	}{
+		{ConfigFile, "a.json", "/tmp/config/schannel-qt5/a.json"},
+		{DataFile, "a.db", "/home/test/.local/share/schannel-qt5/a.db"},
+		{CacheFile, "GeoIP", "/home/test/.cache/schannel-qt5/GeoIP"},
+		{StateFile, "a.log", "/tmp/state/schannel-qt5/a.log"},
+	}
+	for _, v := range testData {
+		res, err := v.get(v.name)
+		if err != nil || res != v.res {
+			t.Errorf("want: %s, have: %s, %v\n", v.res, res, err)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schannel-qt5-xdg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	legacy := filepath.Join(dir, "old.json")
+	path := filepath.Join(dir, "new", "schannel-qt5", "new.json")
+	if moved, err := Migrate(legacy, path); err != nil || moved {
+		t.Errorf("旧文件不存在时不应该移动: %v, %v\n", moved, err)
+	}
+
+	if err := ioutil.WriteFile(legacy, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if moved, err := Migrate(legacy, path); err != nil || !moved {
+		t.Fatalf("移动失败: %v, %v\n", moved, err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil || string(data) != "old" {
+		t.Errorf("移动后的内容错误: %s, %v\n", data, err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("旧文件没有被移除\n")
+	}
+
+	// 新位置已经存在时保留两者
+	if err := ioutil.WriteFile(legacy, []byte("older"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if moved, err := Migrate(legacy, path); err != nil || moved {
+		t.Errorf("新文件存在时不应该移动: %v, %v\n", moved, err)
+	}
+	data, _ = ioutil.ReadFile(path)
+	if string(data) != "old" {
+		t.Errorf("新文件被覆盖: %s\n", data)
+	}
+}